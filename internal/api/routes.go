@@ -15,10 +15,16 @@ func SetupRoutes(router *gin.RouterGroup, db *gorm.DB) {
 	scriptHandler := handlers.NewScriptHandler(db)
 	jobHandler := handlers.NewJobHandler(db)
 	jobExecutionHandler := handlers.NewJobExecutionHandler(db)
+	jobScheduleHandler := handlers.NewJobScheduleHandler(db)
+	workflowHandler := handlers.NewWorkflowHandler(db)
 	dashboardHandler := handlers.NewDashboardHandler(db)
 	topologyHandler := handlers.NewTopologyHandler(db)
 	systemHandler := handlers.NewSystemHandler()
 	fileHandler := handlers.NewFileHandler(db)
+	interpreterHandler := handlers.NewInterpreterHandler(db)
+	approvalHandler := handlers.NewApprovalHandler(db)
+	hostKeyHandler := handlers.NewHostKeyHandler(db)
+	bastionHandler := handlers.NewBastionHandler(db)
 
 	// 公开路由
 	public := router.Group("/")
@@ -42,6 +48,8 @@ func SetupRoutes(router *gin.RouterGroup, db *gorm.DB) {
 		// 主机管理 - 查看权限对所有用户开放
 		protected.GET("/hosts", hostHandler.GetHosts)
 		protected.GET("/hosts/:id", hostHandler.GetHost)
+		protected.GET("/bastions", bastionHandler.GetBastions)
+		protected.GET("/bastions/:id", bastionHandler.GetBastion)
 
 		// 脚本管理
 		scripts := protected.Group("/scripts")
@@ -57,8 +65,16 @@ func SetupRoutes(router *gin.RouterGroup, db *gorm.DB) {
 			scripts.GET("/import/template", scriptHandler.DownloadImportTemplate)
 		}
 
+		// 脚本类型（解释器）
+		protected.GET("/interpreters", interpreterHandler.GetInterpreters)
+
 		// 作业执行
 		protected.POST("/jobs/:id/execute", jobExecutionHandler.ExecuteJob)
+		protected.POST("/jobs/:id/rerun", jobExecutionHandler.RerunJob)
+		// 执行申请（需要审批的作业）
+		protected.GET("/execution-requests", approvalHandler.GetExecutionRequests)
+		protected.GET("/execution-requests/:id", approvalHandler.GetExecutionRequest)
+		protected.POST("/execution-requests/:id/cancel", approvalHandler.CancelExecutionRequest)
 		protected.POST("/scripts/quick-execute", jobExecutionHandler.QuickExecuteScript)
 		// 脚本文件关联功能
 		protected.POST("/job-executions/save-result", jobExecutionHandler.SaveExecutionResult)
@@ -72,10 +88,38 @@ func SetupRoutes(router *gin.RouterGroup, db *gorm.DB) {
 		protected.POST("/jobs/batch/delete", jobHandler.BatchDeleteJobs)
 		protected.GET("/jobs/export", jobHandler.ExportJobs)
 		protected.GET("/jobs/:id/executions", jobHandler.GetJobExecutions)
+		protected.GET("/jobs/:id/runs", jobHandler.GetJobRuns)
+		protected.GET("/jobs/:id/runs/:runId", jobHandler.GetJobRunDetail)
+		protected.POST("/jobs/:id/runs/:runId/cancel", jobHandler.CancelJobRun)
+		protected.GET("/jobs/:id/dependencies", jobHandler.GetJobDependencies)
+		protected.POST("/jobs/:id/dependencies", jobHandler.CreateJobDependency)
+		protected.DELETE("/jobs/:id/dependencies/:depId", jobHandler.DeleteJobDependency)
+
+		// 定时调度
+		protected.GET("/schedules", jobScheduleHandler.GetSchedules)
+		protected.POST("/schedules", jobScheduleHandler.CreateSchedule)
+		protected.GET("/schedules/preview", jobScheduleHandler.PreviewSchedule)
+		protected.GET("/schedules/:id", jobScheduleHandler.GetSchedule)
+		protected.PUT("/schedules/:id", jobScheduleHandler.UpdateSchedule)
+		protected.DELETE("/schedules/:id", jobScheduleHandler.DeleteSchedule)
+		protected.POST("/schedules/:id/enable", jobScheduleHandler.EnableSchedule)
+		protected.POST("/schedules/:id/disable", jobScheduleHandler.DisableSchedule)
+
+		// 工作流管理
+		protected.GET("/workflows", workflowHandler.GetWorkflows)
+		protected.POST("/workflows", workflowHandler.CreateWorkflow)
+		protected.GET("/workflows/:id", workflowHandler.GetWorkflow)
+		protected.PUT("/workflows/:id", workflowHandler.UpdateWorkflow)
+		protected.DELETE("/workflows/:id", workflowHandler.DeleteWorkflow)
+		protected.POST("/workflows/:id/run", workflowHandler.RunWorkflow)
+		protected.GET("/workflows/:id/runs", workflowHandler.GetWorkflowRuns)
+		protected.GET("/workflow-runs/:id", workflowHandler.GetWorkflowRunDetail)
 
 		// 执行记录管理
 		protected.GET("/executions", jobHandler.GetAllExecutions)
 		protected.GET("/executions/:id", jobHandler.GetExecutionDetail)
+		protected.GET("/executions/:id/stream", jobHandler.StreamExecution)
+		protected.POST("/executions/:id/cancel", jobHandler.CancelExecution)
 
 		// 仪表盘API
 		protected.GET("/dashboard/stats", dashboardHandler.GetDashboardStats)
@@ -140,14 +184,38 @@ func SetupRoutes(router *gin.RouterGroup, db *gorm.DB) {
 		admin.PUT("/hosts/schedule/config", hostHandler.UpdateScheduleConfig)
 		admin.PUT("/hosts/:id/auth", hostHandler.UpdateHostAuth)
 
+		// 主机密钥管理（仅管理员）
+		admin.GET("/host-keys", hostKeyHandler.GetHostKeys)
+		admin.POST("/host-keys/import", hostKeyHandler.ImportKnownHosts)
+		admin.GET("/hosts/:id/host-key", hostKeyHandler.GetHostKey)
+		admin.POST("/hosts/:id/host-key/approve", hostKeyHandler.ApproveHostKey)
+		admin.POST("/hosts/:id/host-key/reset", hostKeyHandler.ResetHostKey)
+
+		// 跳板机管理（仅管理员）
+		admin.POST("/bastions", bastionHandler.CreateBastion)
+		admin.PUT("/bastions/:id", bastionHandler.UpdateBastion)
+		admin.DELETE("/bastions/:id", bastionHandler.DeleteBastion)
+		admin.POST("/bastions/:id/test", bastionHandler.TestBastionConnection)
+		admin.POST("/bastions/:id/host-key/approve", bastionHandler.ApproveBastionHostKey)
+		admin.POST("/bastions/:id/host-key/reset", bastionHandler.ResetBastionHostKey)
+
 		// 批量主机操作（仅管理员）
 		admin.POST("/hosts/batch/import", hostHandler.BatchImportHosts)
 		admin.POST("/hosts/batch/import-csv", hostHandler.BatchImportHostsFromCSV)
 		admin.GET("/hosts/csv-template", hostHandler.DownloadCSVTemplate)
 		admin.POST("/hosts/batch/operation", hostHandler.BatchHostOperation)
 
+		// 自定义脚本类型（仅管理员）
+		admin.POST("/interpreters", interpreterHandler.CreateInterpreter)
+		admin.PUT("/interpreters/:id", interpreterHandler.UpdateInterpreter)
+		admin.DELETE("/interpreters/:id", interpreterHandler.DeleteInterpreter)
+
 		// 作业执行记录管理（仅管理员）
 		admin.DELETE("/executions/:id", jobExecutionHandler.DeleteJobExecution)
 		admin.POST("/executions/batch/delete", jobExecutionHandler.BatchDeleteJobExecutions)
+
+		// 执行申请审批（仅管理员）
+		admin.POST("/execution-requests/:id/approve", approvalHandler.ApproveExecutionRequest)
+		admin.POST("/execution-requests/:id/reject", approvalHandler.RejectExecutionRequest)
 	}
 }
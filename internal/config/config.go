@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
@@ -49,6 +50,31 @@ type Config struct {
 		Enabled           bool   `yaml:"enabled"`
 		HostCheckInterval string `yaml:"host_check_interval"`
 	} `yaml:"scheduler"`
+
+	Execution struct {
+		WorkdirBase          string `yaml:"workdir_base"`            // 远程执行工作目录的基础路径，每次执行使用其下的独立子目录
+		KeepWorkdirOnFailure bool   `yaml:"keep_workdir_on_failure"` // 执行失败时保留工作目录
+	} `yaml:"execution"`
+
+	SSH struct {
+		MaxSessions       int    `yaml:"max_sessions"`        // 单个连接的最大并发会话数，超出时对同一主机建立新连接，默认8
+		IdleTimeout       string `yaml:"idle_timeout"`        // 空闲连接的关闭时间，默认5m
+		KeepAliveInterval string `yaml:"keep_alive_interval"` // 连接保活间隔，默认30s
+		HostKeyPolicy     string `yaml:"host_key_policy"`     // 主机密钥校验策略：tofu（首次连接时信任）、strict（需管理员确认），默认tofu
+		AgentSocket       string `yaml:"agent_socket"`        // agent认证使用的ssh-agent套接字，为空时使用环境变量 SSH_AUTH_SOCK
+		CAKeyFile         string `yaml:"ca_key_file"`         // certificate认证签发用户证书的CA私钥文件
+		CAKeyPassphrase   string `yaml:"ca_key_passphrase"`   // CA私钥的密码短语
+		CertValidity      string `yaml:"cert_validity"`       // 签发证书的有效期，默认5m
+	} `yaml:"ssh"`
+
+	Approval struct {
+		ExpireHours int `yaml:"expire_hours"` // 执行申请的有效期（小时），超时未批准则过期，默认24
+	} `yaml:"approval"`
+
+	Security struct {
+		SecretKey          string   `yaml:"secret_key"`           // 敏感字段（SSH凭据、提权密码）的主密钥，必须配置且不能与JWT密钥相同
+		PreviousSecretKeys []string `yaml:"previous_secret_keys"` // 轮换前的主密钥，只用于解密尚未重新加密的数据
+	} `yaml:"security"`
 }
 
 func Load() (*Config, error) {
@@ -98,6 +124,37 @@ func Load() (*Config, error) {
 	if jwtSecret := os.Getenv("JWT_SECRET"); jwtSecret != "" {
 		config.JWT.Secret = jwtSecret
 	}
+	if workdirBase := os.Getenv("DEVOPS_WORKDIR_BASE"); workdirBase != "" {
+		config.Execution.WorkdirBase = workdirBase
+	}
+	if keepWorkdir := os.Getenv("DEVOPS_KEEP_WORKDIR_ON_FAILURE"); keepWorkdir != "" {
+		if kw, err := strconv.ParseBool(keepWorkdir); err == nil {
+			config.Execution.KeepWorkdirOnFailure = kw
+		}
+	}
+	if secretKey := os.Getenv("DEVOPS_SECRET_KEY"); secretKey != "" {
+		config.Security.SecretKey = secretKey
+	}
+	if previousKeys := os.Getenv("DEVOPS_PREVIOUS_SECRET_KEYS"); previousKeys != "" {
+		config.Security.PreviousSecretKeys = strings.Split(previousKeys, ",")
+	}
+	if hostKeyPolicy := os.Getenv("DEVOPS_SSH_HOST_KEY_POLICY"); hostKeyPolicy != "" {
+		config.SSH.HostKeyPolicy = hostKeyPolicy
+	}
+	if agentSocket := os.Getenv("DEVOPS_SSH_AGENT_SOCKET"); agentSocket != "" {
+		config.SSH.AgentSocket = agentSocket
+	}
+	if caKeyFile := os.Getenv("DEVOPS_SSH_CA_KEY_FILE"); caKeyFile != "" {
+		config.SSH.CAKeyFile = caKeyFile
+	}
+	if caKeyPassphrase := os.Getenv("DEVOPS_SSH_CA_KEY_PASSPHRASE"); caKeyPassphrase != "" {
+		config.SSH.CAKeyPassphrase = caKeyPassphrase
+	}
+	if expireHours := os.Getenv("DEVOPS_APPROVAL_EXPIRE_HOURS"); expireHours != "" {
+		if h, err := strconv.Atoi(expireHours); err == nil {
+			config.Approval.ExpireHours = h
+		}
+	}
 	if logLevel := os.Getenv("LOG_LEVEL"); logLevel != "" {
 		config.Logging.Level = logLevel
 	}
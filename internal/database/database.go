@@ -54,9 +54,17 @@ func Init(cfg *config.Config) (*gorm.DB, error) {
 	err = db.AutoMigrate(
 		&models.User{},
 		&models.Host{},
+		&models.Bastion{},
 		&models.Job{},
 		&models.Script{},
+		&models.ScriptInterpreter{},
+		&models.JobRun{},
+		&models.JobSchedule{},
+		&models.JobDependency{},
+		&models.ExecutionRequest{},
+		&models.ExecutionApproval{},
 		&models.JobExecution{},
+		&models.JobExecutionAttempt{},
 		&models.Business{},
 		&models.Environment{},
 		&models.Cluster{},
@@ -65,6 +73,10 @@ func Init(cfg *config.Config) (*gorm.DB, error) {
 		&models.File{},
 		&models.FileDistribution{},
 		&models.FileDistributionDetail{},
+		&models.Workflow{},
+		&models.WorkflowStep{},
+		&models.WorkflowRun{},
+		&models.WorkflowStepRun{},
 	)
 	if err != nil {
 		logger.Errorf("数据库表迁移失败: %v", err)
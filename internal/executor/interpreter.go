@@ -0,0 +1,101 @@
+package executor
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"go-devops/internal/ssh"
+)
+
+// 默认启动模板
+const defaultLaunchTemplate = "{interpreter} {script} {args}"
+
+// 内置解释器，shell 和 python2 为兼容历史脚本保留
+var builtinInterpreters = []ssh.Interpreter{
+	{Type: "shell", Extension: ".sh", Probes: []string{"bash", "sh"}, Template: defaultLaunchTemplate},
+	{Type: "bash", Extension: ".sh", Probes: []string{"bash"}, Template: defaultLaunchTemplate},
+	{Type: "sh", Extension: ".sh", Probes: []string{"sh"}, Template: defaultLaunchTemplate},
+	{Type: "python3", Extension: ".py", Probes: []string{"python3", "/usr/bin/python3", "python"}, Template: "{interpreter} -u {script} {args}"},
+	{Type: "python2", Extension: ".py", Probes: []string{"python2", "/usr/bin/python2", "python"}, Template: "{interpreter} -u {script} {args}"},
+	{Type: "perl", Extension: ".pl", Probes: []string{"perl"}, Template: defaultLaunchTemplate},
+	{Type: "powershell", Extension: ".ps1", Probes: []string{"pwsh", "powershell"}, Template: "{interpreter} -NoProfile -NonInteractive -File {script} {args}"},
+	{Type: "ruby", Extension: ".rb", Probes: []string{"ruby"}, Template: defaultLaunchTemplate},
+}
+
+// interpreterRegistry 脚本类型到解释器的注册表，包含内置和管理员注册的自定义解释器
+var interpreterRegistry = struct {
+	sync.RWMutex
+	interpreters map[string]ssh.Interpreter
+	builtin      map[string]bool
+}{
+	interpreters: make(map[string]ssh.Interpreter),
+	builtin:      make(map[string]bool),
+}
+
+func init() {
+	for _, interpreter := range builtinInterpreters {
+		interpreterRegistry.interpreters[interpreter.Type] = interpreter
+		interpreterRegistry.builtin[interpreter.Type] = true
+	}
+}
+
+// LookupInterpreter 返回脚本类型对应的解释器
+func LookupInterpreter(scriptType string) (ssh.Interpreter, bool) {
+	interpreterRegistry.RLock()
+	defer interpreterRegistry.RUnlock()
+	interpreter, ok := interpreterRegistry.interpreters[scriptType]
+	return interpreter, ok
+}
+
+// IsBuiltinInterpreter 判断是否为内置脚本类型
+func IsBuiltinInterpreter(scriptType string) bool {
+	interpreterRegistry.RLock()
+	defer interpreterRegistry.RUnlock()
+	return interpreterRegistry.builtin[scriptType]
+}
+
+// RegisterInterpreter 注册或替换自定义解释器，不能覆盖内置类型
+func RegisterInterpreter(interpreter ssh.Interpreter) error {
+	if err := interpreter.Validate(); err != nil {
+		return err
+	}
+	interpreterRegistry.Lock()
+	defer interpreterRegistry.Unlock()
+	if interpreterRegistry.builtin[interpreter.Type] {
+		return fmt.Errorf("脚本类型 '%s' 为内置类型，不能修改", interpreter.Type)
+	}
+	interpreterRegistry.interpreters[interpreter.Type] = interpreter
+	return nil
+}
+
+// UnregisterInterpreter 移除自定义解释器，内置类型不受影响
+func UnregisterInterpreter(scriptType string) {
+	interpreterRegistry.Lock()
+	defer interpreterRegistry.Unlock()
+	if !interpreterRegistry.builtin[scriptType] {
+		delete(interpreterRegistry.interpreters, scriptType)
+	}
+}
+
+// Interpreters 返回所有已注册的解释器，按类型排序
+func Interpreters() []ssh.Interpreter {
+	interpreterRegistry.RLock()
+	defer interpreterRegistry.RUnlock()
+	interpreters := make([]ssh.Interpreter, 0, len(interpreterRegistry.interpreters))
+	for _, interpreter := range interpreterRegistry.interpreters {
+		interpreters = append(interpreters, interpreter)
+	}
+	sort.Slice(interpreters, func(i, j int) bool {
+		return interpreters[i].Type < interpreters[j].Type
+	})
+	return interpreters
+}
+
+// ValidateScriptType 校验脚本类型是否已注册
+func ValidateScriptType(scriptType string) error {
+	if _, ok := LookupInterpreter(scriptType); !ok {
+		return fmt.Errorf("不支持的脚本类型: %s", scriptType)
+	}
+	return nil
+}
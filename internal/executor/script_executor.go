@@ -1,8 +1,11 @@
 package executor
 
 import (
+	"context"
 	"crypto/md5"
 	"fmt"
+	"io"
+	"mime"
 	"os"
 	"path/filepath"
 	"strings"
@@ -24,55 +27,19 @@ func NewScriptExecutor(db *gorm.DB) *ScriptExecutor {
 }
 
 // ExecuteScript 执行脚本的统一入口
-func (e *ScriptExecutor) ExecuteScript(host *models.Host, script *models.Script) (string, string, error) {
-	return e.ExecuteScriptWithFiles(host, script, nil)
+func (e *ScriptExecutor) ExecuteScript(ctx context.Context, host *models.Host, script *models.Script) (string, string, error) {
+	return e.ExecuteScriptWithFiles(ctx, host, script, ssh.ScriptOptions{})
 }
 
-// ExecuteScriptWithFiles 执行脚本并传递输入文件
-func (e *ScriptExecutor) ExecuteScriptWithFiles(host *models.Host, script *models.Script, inputFiles []models.File) (string, string, error) {
-	switch script.Type {
-	case "shell":
-		return e.executeShellScriptWithFiles(host, script, inputFiles)
-	case "python2":
-		return e.executePython2ScriptWithFiles(host, script, inputFiles)
-	case "python3":
-		return e.executePython3ScriptWithFiles(host, script, inputFiles)
-	default:
+// ExecuteScriptWithFiles 执行脚本并传递输入文件和参数，ctx用于控制超时和中断
+// 脚本类型通过解释器注册表确定写入的文件扩展名、解释器和启动方式
+func (e *ScriptExecutor) ExecuteScriptWithFiles(ctx context.Context, host *models.Host, script *models.Script, opts ssh.ScriptOptions) (string, string, error) {
+	interpreter, ok := LookupInterpreter(script.Type)
+	if !ok {
 		return "", "", fmt.Errorf("不支持的脚本类型: %s", script.Type)
 	}
-}
-
-// Shell脚本执行 - 优化后的实现
-func (e *ScriptExecutor) executeShellScript(host *models.Host, script *models.Script) (string, string, error) {
-	// 直接执行原始脚本内容，不需要额外包装
-	return ssh.ExecuteScript(host, script)
-}
-
-// Shell脚本执行（带文件）
-func (e *ScriptExecutor) executeShellScriptWithFiles(host *models.Host, script *models.Script, inputFiles []models.File) (string, string, error) {
-	return ssh.ExecuteScriptWithFiles(host, script, inputFiles)
-}
-
-// Python2脚本执行
-func (e *ScriptExecutor) executePython2Script(host *models.Host, script *models.Script) (string, string, error) {
-	// 直接传递给SSH模块处理，避免双重包装
-	return ssh.ExecuteScript(host, script)
-}
-
-// Python2脚本执行（带文件）
-func (e *ScriptExecutor) executePython2ScriptWithFiles(host *models.Host, script *models.Script, inputFiles []models.File) (string, string, error) {
-	return ssh.ExecuteScriptWithFiles(host, script, inputFiles)
-}
-
-// Python3脚本执行
-func (e *ScriptExecutor) executePython3Script(host *models.Host, script *models.Script) (string, string, error) {
-	// 直接传递给SSH模块处理，避免双重包装
-	return ssh.ExecuteScript(host, script)
-}
-
-// Python3脚本执行（带文件）
-func (e *ScriptExecutor) executePython3ScriptWithFiles(host *models.Host, script *models.Script, inputFiles []models.File) (string, string, error) {
-	return ssh.ExecuteScriptWithFiles(host, script, inputFiles)
+	opts.Interpreter = &interpreter
+	return ssh.ExecuteScriptWithFiles(ctx, host, script, opts)
 }
 
 // SaveExecutionResultAsFile 将执行结果保存为文件
@@ -173,3 +140,61 @@ func (e *ScriptExecutor) saveContentAsFile(filename, content, category, descript
 	return file, nil
 }
 
+
+// SaveArtifacts 将从主机收集的产物登记为文件，并关联到执行记录
+func (e *ScriptExecutor) SaveArtifacts(execution *models.JobExecution, host *models.Host, artifacts []ssh.Artifact) error {
+	jobName := execution.JobName
+	if jobName == "" {
+		jobName = execution.ScriptName
+	}
+
+	var failed []string
+	for _, artifact := range artifacts {
+		md5Hash, err := fileMD5(artifact.LocalPath)
+		if err != nil {
+			failed = append(failed, artifact.Name)
+			continue
+		}
+		mimeType := mime.TypeByExtension(filepath.Ext(artifact.Name))
+		if mimeType == "" {
+			mimeType = "application/octet-stream"
+		}
+
+		executionID := execution.ID
+		file := &models.File{
+			Name:         filepath.Base(artifact.LocalPath),
+			OriginalName: artifact.Name,
+			Path:         artifact.LocalPath,
+			Size:         artifact.Size,
+			MimeType:     mimeType,
+			MD5Hash:      md5Hash,
+			Category:     "artifact",
+			Description:  fmt.Sprintf("作业产物 - %s (%s)", jobName, host.Name),
+			UploadedBy:   execution.ExecutedBy,
+			ExecutionID:  &executionID,
+		}
+		if err := e.db.Create(file).Error; err != nil {
+			failed = append(failed, artifact.Name)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("以下产物登记失败: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// fileMD5 计算本地文件的MD5哈希
+func fileMD5(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := md5.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
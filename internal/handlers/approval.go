@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"go-devops/internal/models"
+	"go-devops/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ApprovalHandler 执行审批处理器
+type ApprovalHandler struct {
+	approvalService *services.ApprovalService
+	activityService *services.ActivityService
+}
+
+// NewApprovalHandler 创建执行审批处理器
+func NewApprovalHandler(db *gorm.DB) *ApprovalHandler {
+	return &ApprovalHandler{
+		approvalService: services.NewApprovalService(db),
+		activityService: services.NewActivityService(db),
+	}
+}
+
+// GetExecutionRequests 获取执行申请列表
+func (h *ApprovalHandler) GetExecutionRequests(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 || size > 100 {
+		size = 20
+	}
+	jobID, _ := strconv.ParseUint(c.Query("job_id"), 10, 32)
+
+	requests, total, err := h.approvalService.ListRequests(c.Query("status"), uint(jobID), page, size)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"data":  requests,
+		"total": total,
+		"page":  page,
+		"size":  size,
+	})
+}
+
+// GetExecutionRequest 获取执行申请详情
+func (h *ApprovalHandler) GetExecutionRequest(c *gin.Context) {
+	id, ok := parseExecutionRequestID(c)
+	if !ok {
+		return
+	}
+	request, err := h.approvalService.GetRequest(id)
+	if err != nil {
+		respondApprovalError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, request)
+}
+
+// ApproveExecutionRequest 批准执行申请，达到所需批准人数时启动运行
+func (h *ApprovalHandler) ApproveExecutionRequest(c *gin.Context) {
+	id, ok := parseExecutionRequestID(c)
+	if !ok {
+		return
+	}
+	var body models.ExecutionApprovalRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+			return
+		}
+	}
+
+	userID := c.GetUint("user_id")
+	request, err := h.approvalService.Approve(id, userID, body.Comment)
+	if err != nil {
+		respondApprovalError(c, err)
+		return
+	}
+
+	description := fmt.Sprintf("批准作业 %d 的执行申请（%d/%d）", request.JobID, request.ApprovalCount, request.RequiredApprovals)
+	switch {
+	case request.RunID != nil:
+		description += fmt.Sprintf("，已启动运行 %d", *request.RunID)
+	case request.StartError != "":
+		description += "，启动运行失败: " + request.StartError
+	}
+	h.activityService.LogSuccess(c, userID, "approve", "execution_request", &request.ID, description)
+
+	message := "已批准，等待其他管理员审批"
+	if request.Status == services.ApprovalApproved {
+		message = "执行申请已批准，作业运行已启动"
+		if request.RunID == nil {
+			message = "执行申请已批准，但启动运行失败"
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"message": message, "request": request})
+}
+
+// RejectExecutionRequest 驳回执行申请
+func (h *ApprovalHandler) RejectExecutionRequest(c *gin.Context) {
+	id, ok := parseExecutionRequestID(c)
+	if !ok {
+		return
+	}
+	var body models.ExecutionApprovalRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+			return
+		}
+	}
+
+	userID := c.GetUint("user_id")
+	request, err := h.approvalService.Reject(id, userID, body.Comment)
+	if err != nil {
+		respondApprovalError(c, err)
+		return
+	}
+
+	h.activityService.LogSuccess(c, userID, "reject", "execution_request", &request.ID,
+		fmt.Sprintf("驳回作业 %d 的执行申请", request.JobID))
+	c.JSON(http.StatusOK, gin.H{"message": "执行申请已驳回", "request": request})
+}
+
+// CancelExecutionRequest 撤销待审批的执行申请
+func (h *ApprovalHandler) CancelExecutionRequest(c *gin.Context) {
+	id, ok := parseExecutionRequestID(c)
+	if !ok {
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	request, err := h.approvalService.Cancel(id, userID, c.GetString("role") == "admin")
+	if err != nil {
+		respondApprovalError(c, err)
+		return
+	}
+
+	h.activityService.LogSuccess(c, userID, "cancel", "execution_request", &request.ID,
+		fmt.Sprintf("撤销作业 %d 的执行申请", request.JobID))
+	c.JSON(http.StatusOK, gin.H{"message": "执行申请已撤销", "request": request})
+}
+
+// parseExecutionRequestID 解析路径中的执行申请ID
+func parseExecutionRequestID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的执行申请ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// respondApprovalError 按错误类型返回状态码
+func respondApprovalError(c *gin.Context, err error) {
+	switch err {
+	case services.ErrExecutionRequestNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case services.ErrSelfApproval, services.ErrCancelNotAllowed:
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case services.ErrExecutionRequestClosed, services.ErrExecutionRequestExpired, services.ErrAlreadyReviewed:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"go-devops/internal/models"
+	"go-devops/internal/services"
+	"go-devops/internal/ssh"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// BastionHandler 跳板机处理器
+type BastionHandler struct {
+	bastionService  *services.BastionService
+	activityService *services.ActivityService
+}
+
+// NewBastionHandler 创建跳板机处理器
+func NewBastionHandler(db *gorm.DB) *BastionHandler {
+	return &BastionHandler{
+		bastionService:  services.NewBastionService(db),
+		activityService: services.NewActivityService(db),
+	}
+}
+
+// GetBastions 获取跳板机列表
+func (h *BastionHandler) GetBastions(c *gin.Context) {
+	bastions, err := h.bastionService.ListBastions()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, bastions)
+}
+
+// GetBastion 获取跳板机详情
+func (h *BastionHandler) GetBastion(c *gin.Context) {
+	id, ok := parseBastionID(c)
+	if !ok {
+		return
+	}
+	bastion, err := h.bastionService.GetBastion(id)
+	if err != nil {
+		respondBastionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, bastion)
+}
+
+// CreateBastion 创建跳板机
+func (h *BastionHandler) CreateBastion(c *gin.Context) {
+	var req models.BastionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	bastion, err := h.bastionService.CreateBastion(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	h.activityService.LogSuccess(c, userID, "create", "bastion", &bastion.ID,
+		fmt.Sprintf("创建跳板机 '%s' (%s)", bastion.Name, bastion.IP))
+	c.JSON(http.StatusCreated, bastion)
+}
+
+// UpdateBastion 更新跳板机
+func (h *BastionHandler) UpdateBastion(c *gin.Context) {
+	id, ok := parseBastionID(c)
+	if !ok {
+		return
+	}
+	var req models.BastionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	bastion, err := h.bastionService.UpdateBastion(id, req)
+	if err != nil {
+		if err == services.ErrBastionNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	h.activityService.LogSuccess(c, userID, "update", "bastion", &bastion.ID,
+		fmt.Sprintf("更新跳板机 '%s' (%s)", bastion.Name, bastion.IP))
+	c.JSON(http.StatusOK, bastion)
+}
+
+// DeleteBastion 删除跳板机
+func (h *BastionHandler) DeleteBastion(c *gin.Context) {
+	id, ok := parseBastionID(c)
+	if !ok {
+		return
+	}
+	bastion, err := h.bastionService.DeleteBastion(id)
+	if err != nil {
+		respondBastionError(c, err)
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	h.activityService.LogSuccess(c, userID, "delete", "bastion", &bastion.ID,
+		fmt.Sprintf("删除跳板机 '%s' (%s)", bastion.Name, bastion.IP))
+	c.JSON(http.StatusOK, gin.H{"message": "跳板机删除成功"})
+}
+
+// TestBastionConnection 测试经由跳板机链到跳板机的连接
+func (h *BastionHandler) TestBastionConnection(c *gin.Context) {
+	id, ok := parseBastionID(c)
+	if !ok {
+		return
+	}
+	if _, err := h.bastionService.GetBastion(id); err != nil {
+		respondBastionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, ssh.TestBastionConnection(id))
+}
+
+// ApproveBastionHostKey 信任跳板机的待确认密钥
+func (h *BastionHandler) ApproveBastionHostKey(c *gin.Context) {
+	id, ok := parseBastionID(c)
+	if !ok {
+		return
+	}
+	var req models.HostKeyApproveRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+			return
+		}
+	}
+
+	bastion, err := h.bastionService.ApproveHostKey(id, req.Fingerprint)
+	if err != nil {
+		respondBastionError(c, err)
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	h.activityService.LogSuccess(c, userID, "approve_host_key", "bastion", &bastion.ID,
+		fmt.Sprintf("信任跳板机 '%s' (%s) 的密钥 %s", bastion.Name, bastion.IP, bastion.HostKeyFingerprint))
+	c.JSON(http.StatusOK, gin.H{"message": "跳板机密钥已信任", "bastion": bastion})
+}
+
+// ResetBastionHostKey 清除跳板机已记录的密钥
+func (h *BastionHandler) ResetBastionHostKey(c *gin.Context) {
+	id, ok := parseBastionID(c)
+	if !ok {
+		return
+	}
+	bastion, err := h.bastionService.ResetHostKey(id)
+	if err != nil {
+		respondBastionError(c, err)
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	h.activityService.LogSuccess(c, userID, "reset_host_key", "bastion", &bastion.ID,
+		fmt.Sprintf("重置跳板机 '%s' (%s) 的密钥", bastion.Name, bastion.IP))
+	c.JSON(http.StatusOK, gin.H{"message": "跳板机密钥已重置", "bastion": bastion})
+}
+
+// parseBastionID 解析路径中的跳板机ID
+func parseBastionID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的跳板机ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// respondBastionError 按错误类型返回状态码
+func respondBastionError(c *gin.Context, err error) {
+	switch {
+	case err == services.ErrBastionNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrBastionInUse),
+		err == services.ErrNoPendingHostKey, err == services.ErrPendingHostKeyChanged:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"go-devops/internal/logger"
 	"go-devops/internal/models"
 	"go-devops/internal/services"
+	"go-devops/internal/ssh"
 )
 
 type DashboardHandler struct {
@@ -58,6 +59,10 @@ type JobTrendData struct {
 	Date    string `json:"date"`
 	Success int    `json:"success"`
 	Failed  int    `json:"failed"`
+	// 失败按类型细分
+	ExitCodeFailed   int `json:"exit_code_failed"`  // 脚本非零退出
+	ConnectionFailed int `json:"connection_failed"` // 连接失败
+	Timeout          int `json:"timeout"`           // 超时终止
 }
 
 // 获取最近活动
@@ -133,16 +138,33 @@ func (h *DashboardHandler) GetJobTrend(c *gin.Context) {
 			Where("status = ? AND created_at >= ? AND created_at < ?", "completed", startOfDay, endOfDay).
 			Count(&successCount)
 
-		// 统计失败的作业执行
+		// 统计失败的作业执行（包含超时），并按失败类型细分
+		var failureStats []struct {
+			FailureType string
+			Count       int64
+		}
 		h.db.Model(&models.JobExecution{}).
-			Where("status = ? AND created_at >= ? AND created_at < ?", "failed", startOfDay, endOfDay).
-			Count(&failedCount)
+			Select("failure_type, count(*) as count").
+			Where("status IN ? AND created_at >= ? AND created_at < ?", []string{"failed", "timeout"}, startOfDay, endOfDay).
+			Group("failure_type").
+			Find(&failureStats)
+
+		item := JobTrendData{Date: date.Format("01-02")}
+		for _, stat := range failureStats {
+			failedCount += stat.Count
+			switch stat.FailureType {
+			case ssh.FailureExitCode:
+				item.ExitCodeFailed += int(stat.Count)
+			case ssh.FailureTimeout:
+				item.Timeout += int(stat.Count)
+			case ssh.FailureConnection:
+				item.ConnectionFailed += int(stat.Count)
+			}
+		}
 
-		trendData = append(trendData, JobTrendData{
-			Date:    date.Format("01-02"),
-			Success: int(successCount),
-			Failed:  int(failedCount),
-		})
+		item.Success = int(successCount)
+		item.Failed = int(failedCount)
+		trendData = append(trendData, item)
 	}
 
 	c.JSON(http.StatusOK, trendData)
@@ -2,7 +2,6 @@ package handlers
 
 import (
 	"crypto/md5"
-	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
@@ -11,7 +10,6 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -20,13 +18,13 @@ import (
 	"go-devops/internal/logger"
 	"go-devops/internal/models"
 	"go-devops/internal/services"
-	"go-devops/internal/ssh"
 )
 
 type FileHandler struct {
-	db              *gorm.DB
-	activityService *services.ActivityService
-	uploadPath      string
+	db                  *gorm.DB
+	activityService     *services.ActivityService
+	distributionService *services.FileDistributionService
+	uploadPath          string
 }
 
 func NewFileHandler(db *gorm.DB) *FileHandler {
@@ -37,9 +35,10 @@ func NewFileHandler(db *gorm.DB) *FileHandler {
 	}
 
 	return &FileHandler{
-		db:              db,
-		activityService: services.NewActivityService(db),
-		uploadPath:      uploadPath,
+		db:                  db,
+		activityService:     services.NewActivityService(db),
+		distributionService: services.NewFileDistributionService(db),
+		uploadPath:          uploadPath,
 	}
 }
 
@@ -532,39 +531,19 @@ func (h *FileHandler) DistributeFile(c *gin.Context) {
 		return
 	}
 
-	// 将主机ID列表转换为JSON字符串
-	hostIDsJSON, _ := json.Marshal(req.HostIDs)
-
 	// 创建分发记录
-	distribution := models.FileDistribution{
-		FileID:     req.FileID,
-		HostIDs:    string(hostIDsJSON),
-		TargetPath: req.TargetPath,
-		Status:     "pending",
-		CreatedBy:  userID,
-	}
-
-	if err := h.db.Create(&distribution).Error; err != nil {
+	distribution, err := h.distributionService.CreateDistribution(&file, req.HostIDs, req.TargetPath, userID)
+	if err != nil {
 		logger.Errorf("创建文件分发记录失败: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建分发任务失败"})
 		return
 	}
 
-	// 创建分发详情记录
-	for _, hostID := range req.HostIDs {
-		detail := models.FileDistributionDetail{
-			DistributionID: distribution.ID,
-			HostID:         hostID,
-			Status:         "pending",
-		}
-		h.db.Create(&detail)
-	}
-
 	// 异步执行分发任务
-	go h.executeFileDistribution(&distribution, &file, validHosts)
+	go h.distributionService.ExecuteDistribution(distribution, &file, validHosts)
 
 	// 预加载相关信息
-	h.db.Preload("File").Preload("User").First(&distribution, distribution.ID)
+	h.db.Preload("File").Preload("User").First(distribution, distribution.ID)
 
 	// 记录活动
 	h.activityService.LogSuccess(c, userID, "distribute", "file", &file.ID,
@@ -576,160 +555,6 @@ func (h *FileHandler) DistributeFile(c *gin.Context) {
 	})
 }
 
-// 执行文件分发（支持并发和重试）
-func (h *FileHandler) executeFileDistribution(distribution *models.FileDistribution, file *models.File, hosts []models.Host) {
-	logger.Infof("开始执行文件分发任务: %d，目标主机数: %d", distribution.ID, len(hosts))
-
-	// 更新分发状态为运行中
-	startTime := time.Now()
-	h.db.Model(distribution).Updates(map[string]interface{}{
-		"status":     "running",
-		"start_time": &startTime,
-	})
-
-	// 并发控制：最多同时分发到3台主机
-	const maxConcurrency = 3
-	const maxRetries = 3
-
-	semaphore := make(chan struct{}, maxConcurrency)
-	var wg sync.WaitGroup
-	var mu sync.Mutex
-
-	successCount := 0
-	totalCount := len(hosts)
-	completedCount := 0
-
-	for _, host := range hosts {
-		wg.Add(1)
-		go func(currentHost models.Host) {
-			defer wg.Done()
-
-			// 获取并发许可
-			semaphore <- struct{}{}
-			defer func() { <-semaphore }()
-
-			// 执行单个主机的分发任务
-			success := h.distributeToSingleHost(distribution, file, &currentHost, maxRetries)
-
-			// 更新计数器（需要加锁）
-			mu.Lock()
-			completedCount++
-			if success {
-				successCount++
-			}
-
-			// 更新总体进度
-			progress := (completedCount * 100) / totalCount
-			h.db.Model(distribution).UpdateColumn("progress", progress)
-
-			logger.Infof("分发进度: %d/%d (%.1f%%), 成功: %d",
-				completedCount, totalCount, float64(progress), successCount)
-			mu.Unlock()
-		}(host)
-	}
-
-	// 等待所有分发任务完成
-	wg.Wait()
-
-	// 更新最终状态
-	endTime := time.Now()
-	finalStatus := "completed"
-	if successCount == 0 {
-		finalStatus = "failed"
-	} else if successCount < totalCount {
-		finalStatus = "partial"
-	}
-
-	h.db.Model(distribution).Updates(map[string]interface{}{
-		"status":   finalStatus,
-		"progress": 100,
-		"end_time": &endTime,
-	})
-
-	logger.Infof("文件分发任务完成: %d, 成功: %d/%d, 用时: %v",
-		distribution.ID, successCount, totalCount, endTime.Sub(startTime))
-}
-
-// 分发文件到单个主机（支持重试）
-func (h *FileHandler) distributeToSingleHost(distribution *models.FileDistribution, file *models.File, host *models.Host, maxRetries int) bool {
-	// 获取分发详情记录
-	var detail models.FileDistributionDetail
-	h.db.Where("distribution_id = ? AND host_id = ?", distribution.ID, host.ID).First(&detail)
-
-	detailStartTime := time.Now()
-	h.db.Model(&detail).Updates(map[string]interface{}{
-		"status":     "running",
-		"start_time": &detailStartTime,
-	})
-
-	// 重试逻辑
-	var lastErr error
-	for attempt := 1; attempt <= maxRetries; attempt++ {
-		logger.Infof("尝试分发文件到主机 %s (第%d次，共%d次)", host.Name, attempt, maxRetries)
-
-		err := h.transferFileToHost(file, host, distribution.TargetPath)
-		if err == nil {
-			// 分发成功
-			detailEndTime := time.Now()
-			h.db.Model(&detail).Updates(map[string]interface{}{
-				"status":   "completed",
-				"output":   fmt.Sprintf("文件传输成功 (尝试%d次)", attempt),
-				"end_time": &detailEndTime,
-			})
-
-			logger.Infof("文件分发到主机 %s 成功 (尝试%d次)", host.Name, attempt)
-			return true
-		}
-
-		lastErr = err
-		logger.Warnf("文件分发到主机 %s 失败 (第%d次): %v", host.Name, attempt, err)
-
-		// 如果不是最后一次尝试，等待一段时间再重试
-		if attempt < maxRetries {
-			retryDelay := time.Duration(attempt) * time.Second
-			logger.Infof("等待 %v 后重试...", retryDelay)
-			time.Sleep(retryDelay)
-		}
-	}
-
-	// 所有重试都失败了
-	detailEndTime := time.Now()
-	h.db.Model(&detail).Updates(map[string]interface{}{
-		"status":   "failed",
-		"error":    fmt.Sprintf("重试%d次后仍然失败: %v", maxRetries, lastErr),
-		"end_time": &detailEndTime,
-	})
-
-	logger.Errorf("文件分发到主机 %s 最终失败，已重试%d次: %v", host.Name, maxRetries, lastErr)
-	return false
-}
-
-// 传输文件到主机
-func (h *FileHandler) transferFileToHost(file *models.File, host *models.Host, targetPath string) error {
-	// 检查主机SSH配置
-	if host.Username == "" {
-		return fmt.Errorf("主机未配置SSH用户名")
-	}
-
-	if host.AuthType == "password" && host.Password == "" {
-		return fmt.Errorf("主机未配置SSH密码")
-	}
-
-	if host.AuthType == "key" && host.PrivateKey == "" {
-		return fmt.Errorf("主机未配置SSH私钥")
-	}
-
-	// 创建SSH客户端
-	sshClient, err := ssh.NewSSHClient(host)
-	if err != nil {
-		return fmt.Errorf("创建SSH连接失败: %v", err)
-	}
-	defer sshClient.Close()
-
-	// 上传文件到目标主机
-	return sshClient.UploadFile(file.Path, targetPath)
-}
-
 // 获取分发记录列表
 func (h *FileHandler) GetDistributions(c *gin.Context) {
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
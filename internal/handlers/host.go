@@ -24,12 +24,14 @@ import (
 type HostHandler struct {
 	db              *gorm.DB
 	activityService *services.ActivityService
+	bastionService  *services.BastionService
 }
 
 func NewHostHandler(db *gorm.DB) *HostHandler {
 	return &HostHandler{
 		db:              db,
 		activityService: services.NewActivityService(db),
+		bastionService:  services.NewBastionService(db),
 	}
 }
 
@@ -71,6 +73,7 @@ func (h *HostHandler) CreateHost(c *gin.Context) {
 		Password:    req.Password,
 		PrivateKey:  req.PrivateKey,
 		Passphrase:  req.Passphrase,
+		Certificate: req.Certificate,
 	}
 
 	// 设置默认值
@@ -81,6 +84,16 @@ func (h *HostHandler) CreateHost(c *gin.Context) {
 		host.AuthType = "password"
 	}
 
+	if err := applyHostBecome(&host, req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.bastionService.ValidateHostBastion(req.BastionID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	host.BastionID = req.BastionID
+
 	if err := h.db.Create(&host).Error; err != nil {
 		logger.Errorf("创建主机失败: %v", err)
 		logger.LogDBOperation("create", "hosts", false, err.Error())
@@ -147,6 +160,7 @@ func (h *HostHandler) GetHost(c *gin.Context) {
 		"password":    host.Password,
 		"private_key": host.PrivateKey,
 		"passphrase":  host.Passphrase,
+		"certificate": host.Certificate,
 		"created_at":  host.CreatedAt,
 		"updated_at":  host.UpdatedAt,
 		"topology":    topologyInfo,
@@ -199,6 +213,35 @@ func (h *HostHandler) UpdateHost(c *gin.Context) {
 	if req.Passphrase != "" {
 		updateData["passphrase"] = req.Passphrase
 	}
+	if req.Certificate != "" {
+		updateData["certificate"] = req.Certificate
+	}
+
+	// 提权设置：密码只在提供时更新，不提权时清除
+	if err := services.ValidateHostBecome(req.BecomeMethod, req.BecomeUser); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	updateData["become_method"] = req.BecomeMethod
+	updateData["become_user"] = req.BecomeUser
+	if req.BecomeMethod == "" {
+		updateData["become_password"] = ""
+	} else if req.BecomePassword != "" {
+		updateData["become_password"] = req.BecomePassword
+	}
+
+	if err := h.bastionService.ValidateHostBastion(req.BastionID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	updateData["bastion_id"] = req.BastionID
+
+	// 地址变更后原主机密钥不再适用，下次连接时按策略重新记录
+	if req.IP != host.IP || req.Port != host.Port {
+		for field, value := range services.ResetHostKeyFields() {
+			updateData[field] = value
+		}
+	}
 
 	if err := h.db.Model(&host).Updates(updateData).Error; err != nil {
 		logger.Errorf("更新主机失败: %v", err)
@@ -582,6 +625,7 @@ func (h *HostHandler) BatchImportHosts(c *gin.Context) {
 			Password:    hostReq.Password,
 			PrivateKey:  hostReq.PrivateKey,
 			Passphrase:  hostReq.Passphrase,
+			Certificate: hostReq.Certificate,
 		}
 
 		// 设置默认值
@@ -615,6 +659,26 @@ func (h *HostHandler) BatchImportHosts(c *gin.Context) {
 			continue
 		}
 
+		if err := applyHostBecome(&host, hostReq); err != nil {
+			failedHosts = append(failedHosts, models.BatchImportError{
+				Index: i,
+				Host:  hostReq,
+				Error: err.Error(),
+			})
+			failedCount++
+			continue
+		}
+		if err := h.bastionService.ValidateHostBastion(hostReq.BastionID); err != nil {
+			failedHosts = append(failedHosts, models.BatchImportError{
+				Index: i,
+				Host:  hostReq,
+				Error: err.Error(),
+			})
+			failedCount++
+			continue
+		}
+		host.BastionID = hostReq.BastionID
+
 		// 创建主机
 		if err := h.db.Create(&host).Error; err != nil {
 			logger.Errorf("批量导入主机失败: %v, IP: %s", err, host.IP)
@@ -683,6 +747,9 @@ func (h *HostHandler) UpdateHostAuth(c *gin.Context) {
 	if req.Passphrase != "" {
 		updateData["passphrase"] = req.Passphrase
 	}
+	if req.Certificate != "" {
+		updateData["certificate"] = req.Certificate
+	}
 
 	if len(updateData) == 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "没有提供要更新的认证信息"})
@@ -954,6 +1021,7 @@ func (h *HostHandler) BatchImportHostsFromCSV(c *gin.Context) {
 			Password:    hostReq.Password,
 			PrivateKey:  hostReq.PrivateKey,
 			Passphrase:  hostReq.Passphrase,
+			Certificate: hostReq.Certificate,
 		}
 
 		// 设置默认值
@@ -1128,9 +1196,12 @@ func (h *HostHandler) parseCSVRecord(record []string, headerMap map[string]int,
 	}
 
 	// 验证认证类型
-	validAuthTypes := map[string]bool{"password": true, "key": true}
+	validAuthTypes := make(map[string]bool)
+	for _, authType := range ssh.AuthTypes() {
+		validAuthTypes[authType] = true
+	}
 	if !validAuthTypes[host.AuthType] {
-		return host, fmt.Errorf("无效的认证类型: %s，支持的类型: password, key", host.AuthType)
+		return host, fmt.Errorf("无效的认证类型: %s，支持的类型: %s", host.AuthType, strings.Join(ssh.AuthTypes(), ", "))
 	}
 
 	return host, nil
@@ -1190,3 +1261,17 @@ Web服务器-01,192.168.100.10,22,Ubuntu 20.04,生产环境Web服务器,web;prod
 	logger.Infof("用户下载CSV导入模板")
 	logger.LogUserAction(c.GetUint("user_id"), c.GetString("username"), "download_csv_template", "hosts", true, "下载CSV导入模板")
 }
+
+// applyHostBecome 校验主机的提权设置，提权密码由模型钩子加密存储
+func applyHostBecome(host *models.Host, req models.HostRequest) error {
+	if err := services.ValidateHostBecome(req.BecomeMethod, req.BecomeUser); err != nil {
+		return err
+	}
+	host.BecomeMethod = req.BecomeMethod
+	host.BecomeUser = req.BecomeUser
+	host.BecomePassword = ""
+	if req.BecomeMethod != "" {
+		host.BecomePassword = req.BecomePassword
+	}
+	return nil
+}
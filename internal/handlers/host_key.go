@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go-devops/internal/models"
+	"go-devops/internal/services"
+	"go-devops/internal/ssh"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// known_hosts 上传文件的大小上限
+const maxKnownHostsSize = 4 << 20
+
+// HostKeyHandler 主机密钥管理处理器
+type HostKeyHandler struct {
+	hostKeyService  *services.HostKeyService
+	activityService *services.ActivityService
+}
+
+// NewHostKeyHandler 创建主机密钥管理处理器
+func NewHostKeyHandler(db *gorm.DB) *HostKeyHandler {
+	return &HostKeyHandler{
+		hostKeyService:  services.NewHostKeyService(db),
+		activityService: services.NewActivityService(db),
+	}
+}
+
+// GetHostKeys 获取所有主机的密钥状态
+func (h *HostKeyHandler) GetHostKeys(c *gin.Context) {
+	status := c.Query("status")
+	switch status {
+	case "", services.HostKeyUnknown, services.HostKeyTrusted, services.HostKeyPending, services.HostKeyMismatch:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的密钥状态，支持: unknown, trusted, pending, mismatch"})
+		return
+	}
+
+	infos, err := h.hostKeyService.ListHostKeys(status)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"policy": ssh.HostKeyPolicy(),
+		"data":   infos,
+		"total":  len(infos),
+	})
+}
+
+// GetHostKey 获取主机的密钥信息
+func (h *HostKeyHandler) GetHostKey(c *gin.Context) {
+	id, ok := parseHostKeyHostID(c)
+	if !ok {
+		return
+	}
+	info, err := h.hostKeyService.GetHostKey(id)
+	if err != nil {
+		respondHostKeyError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, info)
+}
+
+// ApproveHostKey 信任主机的待确认密钥
+func (h *HostKeyHandler) ApproveHostKey(c *gin.Context) {
+	id, ok := parseHostKeyHostID(c)
+	if !ok {
+		return
+	}
+	var req models.HostKeyApproveRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+			return
+		}
+	}
+
+	info, err := h.hostKeyService.ApproveHostKey(id, req.Fingerprint)
+	if err != nil {
+		respondHostKeyError(c, err)
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	h.activityService.LogSuccess(c, userID, "approve_host_key", "host", &info.HostID,
+		fmt.Sprintf("信任主机 '%s' (%s) 的密钥 %s", info.HostName, info.IP, info.Fingerprint))
+	c.JSON(http.StatusOK, gin.H{"message": "主机密钥已信任", "host_key": info})
+}
+
+// ResetHostKey 清除主机已记录的密钥
+func (h *HostKeyHandler) ResetHostKey(c *gin.Context) {
+	id, ok := parseHostKeyHostID(c)
+	if !ok {
+		return
+	}
+	info, err := h.hostKeyService.ResetHostKey(id)
+	if err != nil {
+		respondHostKeyError(c, err)
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	h.activityService.LogSuccess(c, userID, "reset_host_key", "host", &info.HostID,
+		fmt.Sprintf("重置主机 '%s' (%s) 的密钥", info.HostName, info.IP))
+	c.JSON(http.StatusOK, gin.H{"message": "主机密钥已重置", "host_key": info})
+}
+
+// ImportKnownHosts 从 OpenSSH known_hosts 导入主机密钥，支持上传文件（file字段）或JSON内容
+func (h *HostKeyHandler) ImportKnownHosts(c *gin.Context) {
+	var req models.KnownHostsImportRequest
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		file, _, err := c.Request.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "请选择要上传的 known_hosts 文件"})
+			return
+		}
+		defer file.Close()
+		content, err := io.ReadAll(io.LimitReader(file, maxKnownHostsSize+1))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("读取文件失败: %v", err)})
+			return
+		}
+		if len(content) > maxKnownHostsSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "known_hosts 文件过大"})
+			return
+		}
+		req.Content = string(content)
+		req.Overwrite, _ = strconv.ParseBool(c.PostForm("overwrite"))
+	} else if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	result, err := h.hostKeyService.ImportKnownHosts(req.Content, req.Overwrite)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	h.activityService.LogSuccess(c, userID, "import_known_hosts", "host", nil,
+		fmt.Sprintf("导入 known_hosts：更新 %d 台主机，%d 台冲突，%d 条未匹配",
+			len(result.Imported), len(result.Conflicts), result.Unmatched))
+	c.JSON(http.StatusOK, gin.H{"message": "known_hosts 导入完成", "result": result})
+}
+
+// parseHostKeyHostID 解析路径中的主机ID
+func parseHostKeyHostID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的主机ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// respondHostKeyError 按错误类型返回状态码
+func respondHostKeyError(c *gin.Context, err error) {
+	switch err {
+	case services.ErrHostNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case services.ErrNoPendingHostKey, services.ErrPendingHostKeyChanged:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"go-devops/internal/models"
+	"go-devops/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// InterpreterHandler 脚本解释器处理器
+type InterpreterHandler struct {
+	interpreterService *services.InterpreterService
+	activityService    *services.ActivityService
+}
+
+// NewInterpreterHandler 创建脚本解释器处理器
+func NewInterpreterHandler(db *gorm.DB) *InterpreterHandler {
+	return &InterpreterHandler{
+		interpreterService: services.NewInterpreterService(db),
+		activityService:    services.NewActivityService(db),
+	}
+}
+
+// GetInterpreters 获取所有可用的脚本类型及其解释器
+func (h *InterpreterHandler) GetInterpreters(c *gin.Context) {
+	interpreters, err := h.interpreterService.ListInterpreters()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"interpreters": interpreters})
+}
+
+// CreateInterpreter 注册自定义脚本类型
+func (h *InterpreterHandler) CreateInterpreter(c *gin.Context) {
+	var req models.ScriptInterpreterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	record, err := h.interpreterService.CreateInterpreter(req, userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.activityService.LogSuccess(c, userID, "create", "script_interpreter", &record.ID,
+		fmt.Sprintf("注册脚本类型 '%s'", record.Type))
+	c.JSON(http.StatusCreated, record)
+}
+
+// UpdateInterpreter 更新自定义脚本类型
+func (h *InterpreterHandler) UpdateInterpreter(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的解释器ID"})
+		return
+	}
+
+	var req models.ScriptInterpreterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	record, err := h.interpreterService.UpdateInterpreter(uint(id), req)
+	if err != nil {
+		if err == services.ErrInterpreterNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	h.activityService.LogSuccess(c, userID, "update", "script_interpreter", &record.ID,
+		fmt.Sprintf("更新脚本类型 '%s'", record.Type))
+	c.JSON(http.StatusOK, record)
+}
+
+// DeleteInterpreter 删除自定义脚本类型
+func (h *InterpreterHandler) DeleteInterpreter(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的解释器ID"})
+		return
+	}
+
+	record, err := h.interpreterService.DeleteInterpreter(uint(id))
+	if err != nil {
+		if err == services.ErrInterpreterNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	h.activityService.LogSuccess(c, userID, "delete", "script_interpreter", &record.ID,
+		fmt.Sprintf("删除脚本类型 '%s'", record.Type))
+	c.JSON(http.StatusOK, gin.H{"message": "解释器删除成功"})
+}
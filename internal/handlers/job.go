@@ -9,6 +9,7 @@ type JobHandler struct {
 	db          *gorm.DB
 	crudHandler *JobCRUDHandler
 	execHandler *JobExecutionHandler
+	depHandler  *JobDependencyHandler
 }
 
 func NewJobHandler(db *gorm.DB) *JobHandler {
@@ -16,6 +17,7 @@ func NewJobHandler(db *gorm.DB) *JobHandler {
 		db:          db,
 		crudHandler: NewJobCRUDHandler(db),
 		execHandler: NewJobExecutionHandler(db),
+		depHandler:  NewJobDependencyHandler(db),
 	}
 }
 
@@ -45,6 +47,10 @@ func (h *JobHandler) ExecuteJob(c *gin.Context) {
 	h.execHandler.ExecuteJob(c)
 }
 
+func (h *JobHandler) RerunJob(c *gin.Context) {
+	h.execHandler.RerunJob(c)
+}
+
 func (h *JobHandler) QuickExecuteScript(c *gin.Context) {
 	h.execHandler.QuickExecuteScript(c)
 }
@@ -70,3 +76,36 @@ func (h *JobHandler) GetAllExecutions(c *gin.Context) {
 func (h *JobHandler) GetExecutionDetail(c *gin.Context) {
 	h.execHandler.GetExecutionDetail(c)
 }
+
+func (h *JobHandler) StreamExecution(c *gin.Context) {
+	h.execHandler.StreamExecution(c)
+}
+
+func (h *JobHandler) CancelExecution(c *gin.Context) {
+	h.execHandler.CancelExecution(c)
+}
+
+func (h *JobHandler) GetJobRuns(c *gin.Context) {
+	h.execHandler.GetJobRuns(c)
+}
+
+func (h *JobHandler) GetJobRunDetail(c *gin.Context) {
+	h.execHandler.GetJobRunDetail(c)
+}
+
+func (h *JobHandler) CancelJobRun(c *gin.Context) {
+	h.execHandler.CancelJobRun(c)
+}
+
+// 委托给依赖处理器的方法
+func (h *JobHandler) GetJobDependencies(c *gin.Context) {
+	h.depHandler.GetJobDependencies(c)
+}
+
+func (h *JobHandler) CreateJobDependency(c *gin.Context) {
+	h.depHandler.CreateJobDependency(c)
+}
+
+func (h *JobHandler) DeleteJobDependency(c *gin.Context) {
+	h.depHandler.DeleteJobDependency(c)
+}
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"go-devops/internal/models"
+	"go-devops/internal/services"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -82,6 +83,40 @@ func (h *JobCRUDHandler) CreateJob(c *gin.Context) {
 		return
 	}
 
+	// 校验参数格式
+	if _, err := services.ParseParameterValues(job.Parameters); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := services.DispatchOptionsFromJob(&job).Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := services.RetryPolicyFromJob(&job).Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := services.ArtifactPolicyFromJob(&job).Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := services.ValidateJobBecome(job.BecomeMethod, job.BecomeUser); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if job.RequireApproval && c.GetString("role") != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "只有管理员可以设置执行审批"})
+		return
+	}
+	if job.RequiredApprovals < 1 {
+		job.RequiredApprovals = 1
+	}
+	// 提权密码由模型钩子加密存储
+	job.BecomePassword = ""
+	if job.BecomeMethod != "" && job.BecomeMethod != services.BecomeNone {
+		job.BecomePassword = job.BecomePasswordInput
+	}
+
 	// 设置创建者
 	job.CreatedBy = c.GetUint("user_id")
 
@@ -141,10 +176,71 @@ func (h *JobCRUDHandler) UpdateJob(c *gin.Context) {
 		return
 	}
 
+	if _, err := services.ParseParameterValues(updateData.Parameters); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := services.DispatchOptionsFromJob(&updateData).Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := services.RetryPolicyFromJob(&updateData).Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := services.ArtifactPolicyFromJob(&updateData).Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := services.ValidateJobBecome(updateData.BecomeMethod, updateData.BecomeUser); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 审批设置只有管理员可以修改，避免通过修改作业绕过审批
+	if updateData.RequiredApprovals < 1 {
+		updateData.RequiredApprovals = 1
+	}
+	if (updateData.RequireApproval != job.RequireApproval || updateData.RequiredApprovals != job.RequiredApprovals) &&
+		c.GetString("role") != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "只有管理员可以修改执行审批设置"})
+		return
+	}
+
 	// 更新字段
 	job.Name = updateData.Name
+	job.Parameters = updateData.Parameters
 	job.ScriptID = updateData.ScriptID
 	job.HostIDs = updateData.HostIDs
+	if updateData.Timeout > 0 {
+		job.Timeout = updateData.Timeout
+	}
+	job.Concurrency = updateData.Concurrency
+	job.BatchSize = updateData.BatchSize
+	job.StopOnFailure = updateData.StopOnFailure
+	job.FailureThreshold = updateData.FailureThreshold
+	if updateData.RetryMaxAttempts > 0 {
+		job.RetryMaxAttempts = updateData.RetryMaxAttempts
+	}
+	if updateData.RetryBackoff != "" {
+		job.RetryBackoff = updateData.RetryBackoff
+	}
+	job.RetryInterval = updateData.RetryInterval
+	job.RetryOn = updateData.RetryOn
+	job.ArtifactPatterns = updateData.ArtifactPatterns
+	if updateData.ArtifactSizeLimit > 0 {
+		job.ArtifactSizeLimit = updateData.ArtifactSizeLimit
+	}
+	job.RequireApproval = updateData.RequireApproval
+	job.RequiredApprovals = updateData.RequiredApprovals
+	// 提权密码只在提供时更新，不提权时清除
+	job.BecomeMethod = updateData.BecomeMethod
+	job.BecomeUser = updateData.BecomeUser
+	if job.BecomeMethod == "" || job.BecomeMethod == services.BecomeNone {
+		job.BecomePassword = ""
+	} else if updateData.BecomePasswordInput != "" {
+		job.BecomePassword = updateData.BecomePasswordInput
+	}
 
 	if err := h.db.Save(&job).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新作业失败"})
@@ -178,14 +274,19 @@ func (h *JobCRUDHandler) DeleteJob(c *gin.Context) {
 
 	// 检查是否有正在运行的执行
 	var runningCount int64
-	h.db.Model(&models.JobExecution{}).Where("job_id = ? AND status = ?", id, "running").Count(&runningCount)
+	h.db.Model(&models.JobExecution{}).Where("job_id = ? AND status IN ?", id, []string{"pending", "running"}).Count(&runningCount)
 	if runningCount > 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "作业正在执行中，无法删除"})
 		return
 	}
 
-	// 删除相关的执行记录
+	// 删除相关的执行记录、运行记录、定时调度和依赖关系
+	h.db.Where("execution_id IN (?)", h.db.Model(&models.JobExecution{}).Select("id").Where("job_id = ?", id)).
+		Delete(&models.JobExecutionAttempt{})
 	h.db.Where("job_id = ?", id).Delete(&models.JobExecution{})
+	h.db.Where("job_id = ?", id).Delete(&models.JobRun{})
+	h.db.Where("job_id = ?", id).Delete(&models.JobSchedule{})
+	h.db.Where("upstream_job_id = ? OR downstream_job_id = ?", id, id).Delete(&models.JobDependency{})
 
 	// 删除作业
 	if err := h.db.Delete(&job).Error; err != nil {
@@ -231,7 +332,7 @@ func (h *JobCRUDHandler) BatchDeleteJobs(c *gin.Context) {
 		
 		// 检查是否有正在运行的执行
 		var execCount int64
-		h.db.Model(&models.JobExecution{}).Where("job_id = ? AND status = ?", job.ID, "running").Count(&execCount)
+		h.db.Model(&models.JobExecution{}).Where("job_id = ? AND status IN ?", job.ID, []string{"pending", "running"}).Count(&execCount)
 		if execCount > 0 {
 			runningCount++
 			continue
@@ -252,8 +353,13 @@ func (h *JobCRUDHandler) BatchDeleteJobs(c *gin.Context) {
 		return
 	}
 
-	// 删除相关的执行记录
+	// 删除相关的执行记录、运行记录、定时调度和依赖关系
+	h.db.Where("execution_id IN (?)", h.db.Model(&models.JobExecution{}).Select("id").Where("job_id IN ?", deletableIDs)).
+		Delete(&models.JobExecutionAttempt{})
 	h.db.Where("job_id IN ?", deletableIDs).Delete(&models.JobExecution{})
+	h.db.Where("job_id IN ?", deletableIDs).Delete(&models.JobRun{})
+	h.db.Where("job_id IN ?", deletableIDs).Delete(&models.JobSchedule{})
+	h.db.Where("upstream_job_id IN ? OR downstream_job_id IN ?", deletableIDs, deletableIDs).Delete(&models.JobDependency{})
 
 	// 执行批量删除
 	if err := h.db.Where("id IN ?", deletableIDs).Delete(&models.Job{}).Error; err != nil {
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"go-devops/internal/models"
+	"go-devops/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// JobDependencyHandler 作业依赖处理器
+type JobDependencyHandler struct {
+	db               *gorm.DB
+	executionService *services.ExecutionService
+	activityService  *services.ActivityService
+}
+
+// NewJobDependencyHandler 创建作业依赖处理器
+func NewJobDependencyHandler(db *gorm.DB) *JobDependencyHandler {
+	return &JobDependencyHandler{
+		db:               db,
+		executionService: services.NewExecutionService(db),
+		activityService:  services.NewActivityService(db),
+	}
+}
+
+// GetJobDependencies 获取作业的依赖图（所有上游、下游作业及依赖边）
+func (h *JobDependencyHandler) GetJobDependencies(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的作业ID"})
+		return
+	}
+
+	if err := h.db.First(&models.Job{}, uint(id)).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "作业不存在"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "获取作业信息失败"})
+		}
+		return
+	}
+
+	graph, err := h.executionService.JobDependencyGraph(uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, graph)
+}
+
+// CreateJobDependency 为作业添加上游依赖
+func (h *JobDependencyHandler) CreateJobDependency(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的作业ID"})
+		return
+	}
+
+	var req models.JobDependencyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	dependency, err := h.executionService.AddJobDependency(req.UpstreamJobID, uint(id), req.Condition, userID)
+	if err != nil {
+		switch err {
+		case services.ErrJobNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case services.ErrDependencyCycle:
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	h.activityService.LogSuccess(c, userID, "create", "job_dependency", &dependency.ID,
+		fmt.Sprintf("作业 %d 依赖作业 %d（条件: %s）", dependency.DownstreamJobID, dependency.UpstreamJobID, dependency.Condition))
+
+	h.db.Preload("UpstreamJob").Preload("DownstreamJob").First(dependency, dependency.ID)
+	c.JSON(http.StatusCreated, dependency)
+}
+
+// DeleteJobDependency 删除作业的依赖关系（作业为上游或下游均可）
+func (h *JobDependencyHandler) DeleteJobDependency(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的作业ID"})
+		return
+	}
+	depID, err := strconv.ParseUint(c.Param("depId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的依赖ID"})
+		return
+	}
+
+	var dependency models.JobDependency
+	if err := h.db.Where("id = ? AND (upstream_job_id = ? OR downstream_job_id = ?)", depID, id, id).
+		First(&dependency).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "依赖关系不存在"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "获取依赖关系失败"})
+		}
+		return
+	}
+
+	if err := h.db.Delete(&dependency).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除依赖关系失败"})
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	h.activityService.LogSuccess(c, userID, "delete", "job_dependency", &dependency.ID,
+		fmt.Sprintf("删除作业 %d 对作业 %d 的依赖", dependency.DownstreamJobID, dependency.UpstreamJobID))
+
+	c.JSON(http.StatusOK, gin.H{"message": "依赖关系删除成功"})
+}
@@ -1,12 +1,14 @@
 package handlers
 
 import (
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
+	"go-devops/internal/executor"
 	"go-devops/internal/logger"
 	"go-devops/internal/models"
 	"go-devops/internal/services"
@@ -19,6 +21,7 @@ import (
 type JobExecutionHandler struct {
 	db               *gorm.DB
 	executionService *services.ExecutionService
+	approvalService  *services.ApprovalService
 	activityService  *services.ActivityService
 }
 
@@ -27,6 +30,7 @@ func NewJobExecutionHandler(db *gorm.DB) *JobExecutionHandler {
 	return &JobExecutionHandler{
 		db:               db,
 		executionService: services.NewExecutionService(db),
+		approvalService:  services.NewApprovalService(db),
 		activityService:  services.NewActivityService(db),
 	}
 }
@@ -39,108 +43,169 @@ func (h *JobExecutionHandler) ExecuteJob(c *gin.Context) {
 		return
 	}
 
-	// 获取作业信息
-	var job models.Job
-	if err := h.db.Preload("Script").First(&job, uint(id)).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
+	// 本次执行可以覆盖作业中保存的参数值
+	var request struct {
+		Parameters map[string]interface{} `json:"parameters"`
+		Comment    string                 `json:"comment"` // 需要审批时的申请说明
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+			return
+		}
+	}
+
+	// 加载作业和主机，校验脚本参数
+	userID := c.GetUint("user_id")
+	runRequest, err := h.executionService.NewJobRunRequest(uint(id), request.Parameters, services.TriggerManual, userID)
+	if err != nil {
+		if err == services.ErrJobNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "作业不存在"})
 		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "获取作业信息失败"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		}
 		return
 	}
-
-	// 解析主机ID列表
-	var hostIDs []uint
-	if err := json.Unmarshal([]byte(job.HostIDs), &hostIDs); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "主机配置错误"})
+	job, hosts := runRequest.Job, runRequest.Hosts
+
+	// 预检模式：只检查目标主机能否执行，不创建运行记录也不执行脚本
+	if dryRun, _ := strconv.ParseBool(c.Query("dry_run")); dryRun {
+		report := h.executionService.PreflightJobRun(runRequest)
+		h.activityService.LogSuccess(c, userID, "dry_run", "job", &job.ID,
+			fmt.Sprintf("预检作业 '%s' 在 %d 台主机上，%d 台未通过", job.Name, len(hosts), report.FailedHosts))
+		c.JSON(http.StatusOK, gin.H{
+			"message": "作业预检完成",
+			"dry_run": true,
+			"report":  report,
+		})
 		return
 	}
 
-	if len(hostIDs) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "未配置执行主机"})
+	// 作业或目标主机所在环境要求审批时，先提交执行申请，批准后再启动运行
+	payload := services.ExecutionRequestPayload{Parameters: request.Parameters}
+	if h.requestApproval(c, runRequest, payload, request.Comment) {
 		return
 	}
 
-	// 获取主机信息
-	var hosts []models.Host
-	if err := h.db.Where("id IN ?", hostIDs).Find(&hosts).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取主机信息失败"})
+	// 创建运行批次并在各主机上启动执行
+	run, executions, err := h.executionService.StartJobRun(runRequest)
+	if err != nil {
+		response := gin.H{"error": err.Error()}
+		if run != nil {
+			response["run_id"] = run.ID
+		}
+		c.JSON(http.StatusInternalServerError, response)
 		return
 	}
 
-	if len(hosts) != len(hostIDs) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "部分主机不存在"})
-		return
-	}
+	// 记录作业执行活动
+	h.activityService.LogSuccess(c, userID, "execute", "job", &job.ID,
+		fmt.Sprintf("执行作业 '%s' 在 %d 台主机上", job.Name, len(hosts)))
 
-	// 更新作业状态为运行中
-	job.Status = "running"
-	h.db.Save(&job)
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "作业执行已启动",
+		"job_id":     job.ID,
+		"run_id":     run.ID,
+		"executions": executions,
+	})
+}
 
-	executedBy := c.GetUint("user_id")
-	var executions []models.JobExecution
+// RerunJob 重新运行作业某次运行中的主机，复用该次运行的脚本快照和参数
+func (h *JobExecutionHandler) RerunJob(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的作业ID"})
+		return
+	}
 
-	// 为每个主机创建执行记录并启动执行
-	for _, host := range hosts {
-		jobID := uint(id)
-		execution, err := h.executionService.CreateJobExecution(
-			&jobID,
-			host.ID,
-			job.Script.Content,
-			job.Script.Type,
-			false, // 不是快速执行
-			executedBy,
-			job.Name,        // 作业名称
-			job.Script.Name, // 脚本名称
-		)
-		if err != nil {
-			logger.Logger.WithFields(map[string]interface{}{
-				"job_id":  job.ID,
-				"host_id": host.ID,
-				"error":   err.Error(),
-			}).Error("创建执行记录失败")
-			continue
+	var request models.JobRerunRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+			return
 		}
+	}
 
-		executions = append(executions, *execution)
-
-		// 获取输入文件（如果有）
-		var inputFiles []models.File
-		if job.InputFileIDs != "" {
-			var fileIDs []uint
-			if err := json.Unmarshal([]byte(job.InputFileIDs), &fileIDs); err == nil {
-				h.db.Where("id IN ?", fileIDs).Find(&inputFiles)
-			}
+	userID := c.GetUint("user_id")
+	runRequest, err := h.executionService.NewRerunRequest(uint(id), request.RunID, request.Mode, request.HostIDs, userID)
+	if err != nil {
+		if err == services.ErrJobNotFound || err == services.ErrJobRunNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		}
+		return
+	}
+	job, hosts := runRequest.Job, runRequest.Hosts
 
-		// 启动异步执行（支持文件功能）
-		go h.executionService.ExecuteScriptOnHostWithOptions(
-			execution,
-			&job.Script,
-			&host,
-			inputFiles,
-			job.SaveOutput,
-			job.SaveError,
-			job.OutputCategory,
-		)
+	// 源运行批次已解析为具体ID，批准后重新运行同一批次
+	payload := services.ExecutionRequestPayload{RunID: *runRequest.ParentRunID, Mode: request.Mode, HostIDs: request.HostIDs}
+	if h.requestApproval(c, runRequest, payload, request.Comment) {
+		return
 	}
 
-	// 启动作业状态监控
-	go h.monitorJobCompletion(job.ID)
+	run, executions, err := h.executionService.StartJobRun(runRequest)
+	if err != nil {
+		response := gin.H{"error": err.Error()}
+		if run != nil {
+			response["run_id"] = run.ID
+		}
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
 
-	// 记录作业执行活动
-	userID := c.GetUint("user_id")
-	h.activityService.LogSuccess(c, userID, "execute", "job", &job.ID,
-		fmt.Sprintf("执行作业 '%s' 在 %d 台主机上", job.Name, len(hosts)))
+	h.activityService.LogSuccess(c, userID, "rerun", "job", &job.ID,
+		fmt.Sprintf("重新运行作业 '%s'（源运行ID: %d）在 %d 台主机上", job.Name, *run.ParentRunID, len(hosts)))
 
 	c.JSON(http.StatusOK, gin.H{
-		"message":    "作业执行已启动",
-		"job_id":     job.ID,
-		"executions": executions,
+		"message":       "作业重新运行已启动",
+		"job_id":        job.ID,
+		"run_id":        run.ID,
+		"parent_run_id": run.ParentRunID,
+		"executions":    executions,
 	})
 }
 
+// requestApproval 运行需要审批时创建执行申请并返回202，返回true表示请求已处理
+func (h *JobExecutionHandler) requestApproval(c *gin.Context, runRequest services.JobRunRequest, payload services.ExecutionRequestPayload, comment string) bool {
+	job := runRequest.Job
+	hostIDs := make([]uint, 0, len(runRequest.Hosts))
+	for _, host := range runRequest.Hosts {
+		hostIDs = append(hostIDs, host.ID)
+	}
+	reasons, err := h.approvalService.ApprovalReasons(job, hostIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return true
+	}
+	if len(reasons) == 0 {
+		return false
+	}
+
+	approval, err := h.approvalService.CreateRequest(runRequest, payload, reasons, comment)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return true
+	}
+
+	h.activityService.LogSuccess(c, runRequest.TriggeredBy, "request_approval", "execution_request", &approval.ID,
+		fmt.Sprintf("申请%s作业 '%s' 在 %d 台主机上（%s）", triggerActionName(runRequest.TriggerType), job.Name, len(hostIDs), approval.Reasons))
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":           "作业需要审批，已提交执行申请",
+		"approval_required": true,
+		"request":           approval,
+	})
+	return true
+}
+
+// triggerActionName 执行申请中操作的名称
+func triggerActionName(triggerType string) string {
+	if triggerType == services.TriggerRerun {
+		return "重新运行"
+	}
+	return "执行"
+}
+
 // SaveExecutionResult 保存执行结果为文件
 func (h *JobExecutionHandler) SaveExecutionResult(c *gin.Context) {
 	var request models.SaveExecutionResultRequest
@@ -199,6 +264,15 @@ func (h *JobExecutionHandler) QuickExecuteScript(c *gin.Context) {
 		HostIDs       []uint `json:"host_ids" binding:"required,min=1"`
 		InputFileIDs  []uint `json:"input_file_ids"`
 		Description   string `json:"description"`
+		Timeout       int    `json:"timeout"` // 超时时间（秒），不传则使用默认值
+		// 脚本参数定义与本次执行的参数值
+		ParameterDefs []models.ScriptParameter `json:"parameter_defs"`
+		Parameters    map[string]interface{}   `json:"parameters"`
+		// 多主机执行调度
+		Concurrency      int    `json:"concurrency"`
+		BatchSize        string `json:"batch_size"`
+		StopOnFailure    bool   `json:"stop_on_failure"`
+		FailureThreshold int    `json:"failure_threshold"`
 	}
 
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -206,6 +280,11 @@ func (h *JobExecutionHandler) QuickExecuteScript(c *gin.Context) {
 		return
 	}
 
+	if err := executor.ValidateScriptType(request.ScriptType); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// 获取主机信息
 	var hosts []models.Host
 	if err := h.db.Where("id IN ?", request.HostIDs).Find(&hosts).Error; err != nil {
@@ -218,6 +297,36 @@ func (h *JobExecutionHandler) QuickExecuteScript(c *gin.Context) {
 		return
 	}
 
+	// 快速执行没有审批流程，要求审批的环境中的主机只允许管理员快速执行
+	reasons, err := h.approvalService.ApprovalReasons(nil, request.HostIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(reasons) > 0 && c.GetString("role") != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": strings.Join(reasons, "；") + "，请通过作业执行并申请审批"})
+		return
+	}
+
+	// 校验脚本参数
+	params, err := services.ResolveScriptParameters(request.ParameterDefs, services.MergeParameterValues(nil, request.Parameters))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 校验调度设置
+	dispatch := services.DispatchOptions{
+		Concurrency:      request.Concurrency,
+		BatchSize:        request.BatchSize,
+		StopOnFailure:    request.StopOnFailure,
+		FailureThreshold: request.FailureThreshold,
+	}
+	if err := dispatch.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// 获取输入文件信息
 	var inputFiles []models.File
 	if len(request.InputFileIDs) > 0 {
@@ -265,6 +374,7 @@ func (h *JobExecutionHandler) QuickExecuteScript(c *gin.Context) {
 
 	executedBy := c.GetUint("user_id")
 	var executions []models.JobExecution
+	var targets []services.ExecutionTarget
 
 	// 创建临时脚本对象
 	script := &models.Script{
@@ -273,7 +383,7 @@ func (h *JobExecutionHandler) QuickExecuteScript(c *gin.Context) {
 		Content: request.ScriptContent,
 	}
 
-	// 为每个主机创建执行记录并启动执行
+	// 为每个主机创建执行记录
 	for _, host := range hosts {
 		execution, err := h.executionService.CreateJobExecution(
 			nil, // 快速执行没有关联的作业ID
@@ -294,11 +404,16 @@ func (h *JobExecutionHandler) QuickExecuteScript(c *gin.Context) {
 		}
 
 		executions = append(executions, *execution)
-
-		// 启动异步执行，传递输入文件
-		go h.executionService.ExecuteScriptOnHostWithOptions(execution, script, &host, inputFiles, false, false, "")
+		targets = append(targets, services.ExecutionTarget{Execution: execution, Host: host})
 	}
 
+	// 按调度设置启动异步执行，传递输入文件
+	h.executionService.DispatchExecutions(targets, script, services.ExecutionOptions{
+		InputFiles: inputFiles,
+		Timeout:    request.Timeout,
+		Parameters: params,
+	}, dispatch)
+
 	// 记录快速执行日志
 	logger.Logger.WithFields(map[string]interface{}{
 		"action":          "quick_execute_script",
@@ -327,9 +442,14 @@ func (h *JobExecutionHandler) GetJobExecutions(c *gin.Context) {
 		return
 	}
 
+	query := h.db.Preload("Host").Where("job_id = ?", id)
+	// 运行批次过滤
+	if runID := c.Query("run_id"); runID != "" {
+		query = query.Where("run_id = ?", runID)
+	}
+
 	var executions []models.JobExecution
-	if err := h.db.Preload("Host").Where("job_id = ?", id).
-		Order("created_at DESC").Find(&executions).Error; err != nil {
+	if err := query.Order("created_at DESC").Find(&executions).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取执行记录失败"})
 		return
 	}
@@ -337,6 +457,110 @@ func (h *JobExecutionHandler) GetJobExecutions(c *gin.Context) {
 	c.JSON(http.StatusOK, executions)
 }
 
+// GetJobRuns 获取作业的运行历史
+func (h *JobExecutionHandler) GetJobRuns(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的作业ID"})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 || size > 100 {
+		size = 20
+	}
+
+	offset := (page - 1) * size
+
+	var runs []models.JobRun
+	var total int64
+
+	query := h.db.Model(&models.JobRun{}).Where("job_id = ?", id)
+
+	// 状态过滤
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	// 获取总数
+	query.Count(&total)
+
+	// 获取数据
+	if err := query.Preload("TriggeredUser").
+		Offset(offset).Limit(size).
+		Order("id DESC").
+		Find(&runs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取运行记录失败"})
+		return
+	}
+
+	// 成功/失败数量按执行记录实时统计
+	if err := h.executionService.FillJobRunCounts(runs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "统计运行结果失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  runs,
+		"total": total,
+		"page":  page,
+		"size":  size,
+	})
+}
+
+// GetJobRunDetail 获取作业运行详情（包含各主机的执行记录）
+func (h *JobExecutionHandler) GetJobRunDetail(c *gin.Context) {
+	run, ok := h.findJobRun(c)
+	if !ok {
+		return
+	}
+
+	if err := h.db.Preload("Host").Where("run_id = ?", run.ID).
+		Order("id ASC").Find(&run.Executions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取执行记录失败"})
+		return
+	}
+
+	runs := []models.JobRun{*run}
+	if err := h.executionService.FillJobRunCounts(runs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "统计运行结果失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, runs[0])
+}
+
+// findJobRun 根据路径参数获取作业运行记录，失败时已写入响应
+func (h *JobExecutionHandler) findJobRun(c *gin.Context) (*models.JobRun, bool) {
+	jobID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的作业ID"})
+		return nil, false
+	}
+	runID, err := strconv.ParseUint(c.Param("runId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的运行ID"})
+		return nil, false
+	}
+
+	var run models.JobRun
+	if err := h.db.Preload("TriggeredUser").
+		Where("id = ? AND job_id = ?", runID, jobID).First(&run).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "运行记录不存在"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "获取运行记录失败"})
+		}
+		return nil, false
+	}
+	return &run, true
+}
+
 // GetAllExecutions 获取所有执行记录
 func (h *JobExecutionHandler) GetAllExecutions(c *gin.Context) {
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
@@ -372,6 +596,11 @@ func (h *JobExecutionHandler) GetAllExecutions(c *gin.Context) {
 		query = query.Where("script_type = ?", scriptType)
 	}
 
+	// 失败类型过滤：exit_code, connection, timeout
+	if failureType := c.Query("failure_type"); failureType != "" {
+		query = query.Where("failure_type = ?", failureType)
+	}
+
 	// 获取总数
 	query.Count(&total)
 
@@ -402,6 +631,10 @@ func (h *JobExecutionHandler) GetExecutionDetail(c *gin.Context) {
 
 	var execution models.JobExecution
 	if err := h.db.Preload("Host").Preload("Job").Preload("Job.Script").Preload("ExecutedUser").
+		Preload("Attempts", func(db *gorm.DB) *gorm.DB {
+			return db.Order("attempt ASC")
+		}).
+		Preload("Artifacts").
 		First(&execution, uint(id)).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "执行记录不存在"})
@@ -414,6 +647,176 @@ func (h *JobExecutionHandler) GetExecutionDetail(c *gin.Context) {
 	c.JSON(http.StatusOK, execution)
 }
 
+// StreamExecution 以SSE方式推送执行的实时输出，支持通过stdout_offset/stderr_offset从指定字节偏移处续读
+func (h *JobExecutionHandler) StreamExecution(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的执行ID"})
+		return
+	}
+	offsets := make(map[string]int)
+	for _, stream := range []string{services.StreamStdout, services.StreamStderr} {
+		if offset, err := strconv.Atoi(c.DefaultQuery(stream+"_offset", "0")); err == nil && offset > 0 {
+			offsets[stream] = offset
+		}
+	}
+
+	var execution models.JobExecution
+	if err := h.db.First(&execution, uint(id)).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "执行记录不存在"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "获取执行记录失败"})
+		}
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	ctx := c.Request.Context()
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+
+	for {
+		backlog, events, unsubscribe, running := services.SubscribeExecutionOutput(execution.ID, offsets)
+		if running {
+			defer unsubscribe()
+			for _, event := range backlog {
+				c.SSEvent(event.Type, event)
+			}
+			c.Writer.Flush()
+
+			for {
+				select {
+				case event, ok := <-events:
+					if !ok {
+						// 订阅被关闭（消费过慢），客户端可按最后的偏移量重连
+						return
+					}
+					c.SSEvent(event.Type, event)
+					c.Writer.Flush()
+					if event.Type == "status" {
+						return
+					}
+				case <-heartbeat.C:
+					c.SSEvent("ping", gin.H{"time": time.Now().Unix()})
+					c.Writer.Flush()
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+
+		// 执行未在本实例运行：已结束时直接从数据库返回输出和最终状态
+		if err := h.db.First(&execution, execution.ID).Error; err != nil {
+			c.SSEvent("error", gin.H{"error": "获取执行记录失败"})
+			return
+		}
+		if services.IsExecutionFinished(execution.Status) {
+			contents := []struct{ stream, content string }{
+				{services.StreamStdout, execution.Output},
+				{services.StreamStderr, execution.Error},
+			}
+			for _, item := range contents {
+				if offset := offsets[item.stream]; offset < len(item.content) {
+					c.SSEvent("output", services.OutputEvent{Type: "output", Stream: item.stream, Data: item.content[offset:], Offset: offset})
+				}
+			}
+			c.SSEvent("status", services.OutputEvent{Type: "status", Status: execution.Status})
+			c.Writer.Flush()
+			return
+		}
+
+		// 尚未开始执行，等待后重试
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// CancelExecution 取消执行（执行者本人或管理员）
+func (h *JobExecutionHandler) CancelExecution(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的执行ID"})
+		return
+	}
+
+	var execution models.JobExecution
+	if err := h.db.First(&execution, uint(id)).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "执行记录不存在"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "获取执行记录失败"})
+		}
+		return
+	}
+
+	// 检查权限
+	userID := c.GetUint("user_id")
+	if execution.ExecutedBy != userID && c.GetString("role") != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权限取消此执行"})
+		return
+	}
+
+	if err := h.executionService.CancelExecution(execution.ID, userID); err != nil {
+		if err == services.ErrExecutionNotCancellable {
+			c.JSON(http.StatusConflict, gin.H{"error": "执行已结束，无法取消"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	h.activityService.LogSuccess(c, userID, "cancel", "job_execution", &execution.ID,
+		fmt.Sprintf("取消执行 - 执行ID: %d", execution.ID))
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "已取消执行",
+		"execution_id": execution.ID,
+	})
+}
+
+// CancelJobRun 取消作业某次运行中的所有执行
+func (h *JobExecutionHandler) CancelJobRun(c *gin.Context) {
+	run, ok := h.findJobRun(c)
+	if !ok {
+		return
+	}
+
+	// 检查权限：运行的触发者或管理员
+	userID := c.GetUint("user_id")
+	if run.TriggeredBy != userID && c.GetString("role") != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权限取消此运行"})
+		return
+	}
+
+	cancelled, err := h.executionService.CancelJobRun(run.JobID, run.ID, userID)
+	if err != nil {
+		if err == services.ErrExecutionNotCancellable {
+			c.JSON(http.StatusConflict, gin.H{"error": "该次运行没有正在执行的任务"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	h.activityService.LogSuccess(c, userID, "cancel", "job", &run.JobID,
+		fmt.Sprintf("取消作业运行 %d，共 %d 个执行", run.ID, len(cancelled)))
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "已取消作业运行",
+		"job_id":     run.JobID,
+		"run_id":     run.ID,
+		"executions": cancelled,
+	})
+}
+
 // DeleteJobExecution 删除作业执行记录（仅限admin）
 func (h *JobExecutionHandler) DeleteJobExecution(c *gin.Context) {
 	// 检查admin权限
@@ -453,7 +856,8 @@ func (h *JobExecutionHandler) DeleteJobExecution(c *gin.Context) {
 		}
 	}
 
-	// 删除执行记录
+	// 删除执行记录及尝试记录
+	h.db.Where("execution_id = ?", execution.ID).Delete(&models.JobExecutionAttempt{})
 	if err := h.db.Delete(&execution).Error; err != nil {
 		logger.Errorf("删除执行记录失败: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除执行记录失败"})
@@ -531,7 +935,8 @@ func (h *JobExecutionHandler) BatchDeleteJobExecutions(c *gin.Context) {
 		}
 	}
 
-	// 批量删除执行记录
+	// 批量删除执行记录及尝试记录
+	h.db.Where("execution_id IN ?", req.IDs).Delete(&models.JobExecutionAttempt{})
 	if err := h.db.Where("id IN ?", req.IDs).Delete(&models.JobExecution{}).Error; err != nil {
 		logger.Errorf("批量删除执行记录失败: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "批量删除执行记录失败"})
@@ -558,18 +963,3 @@ func (h *JobExecutionHandler) BatchDeleteJobExecutions(c *gin.Context) {
 		"message": fmt.Sprintf("成功删除 %d 条执行记录", len(executions)),
 	})
 }
-
-// monitorJobCompletion 监控作业完成状态
-func (h *JobExecutionHandler) monitorJobCompletion(jobID uint) {
-	// 这里可以实现作业完成状态的监控逻辑
-	// 例如定期检查所有执行是否完成，然后更新作业状态
-	go func() {
-		// 简单的状态更新，实际可以用更复杂的监控机制
-		if err := h.executionService.UpdateJobStatus(jobID); err != nil {
-			logger.Logger.WithFields(map[string]interface{}{
-				"job_id": jobID,
-				"error":  err.Error(),
-			}).Error("更新作业状态失败")
-		}
-	}()
-}
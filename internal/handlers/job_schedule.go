@@ -0,0 +1,310 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-devops/internal/models"
+	"go-devops/internal/scheduler"
+	"go-devops/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 预览触发时间的默认和最大次数
+const (
+	defaultPreviewCount = 5
+	maxPreviewCount     = 50
+)
+
+// JobScheduleHandler 作业定时调度处理器
+type JobScheduleHandler struct {
+	db              *gorm.DB
+	activityService *services.ActivityService
+}
+
+// NewJobScheduleHandler 创建作业定时调度处理器
+func NewJobScheduleHandler(db *gorm.DB) *JobScheduleHandler {
+	return &JobScheduleHandler{
+		db:              db,
+		activityService: services.NewActivityService(db),
+	}
+}
+
+// GetSchedules 获取定时调度列表
+func (h *JobScheduleHandler) GetSchedules(c *gin.Context) {
+	query := h.db.Model(&models.JobSchedule{})
+
+	// 作业过滤
+	if jobID := c.Query("job_id"); jobID != "" {
+		query = query.Where("job_id = ?", jobID)
+	}
+	// 启用状态过滤
+	if enabled := c.Query("enabled"); enabled != "" {
+		query = query.Where("enabled = ?", enabled == "true")
+	}
+
+	var schedules []models.JobSchedule
+	if err := query.Preload("Job").Preload("User").Order("id DESC").Find(&schedules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取定时调度列表失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, schedules)
+}
+
+// GetSchedule 获取单个定时调度，包含接下来的触发时间
+func (h *JobScheduleHandler) GetSchedule(c *gin.Context) {
+	schedule, ok := h.findSchedule(c)
+	if !ok {
+		return
+	}
+
+	var nextTimes []time.Time
+	if schedule.Enabled {
+		nextTimes, _ = scheduler.NextScheduleTimes(schedule.CronExpr, schedule.Timezone, time.Now(), defaultPreviewCount)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"schedule":   schedule,
+		"next_times": nextTimes,
+	})
+}
+
+// CreateSchedule 创建定时调度
+func (h *JobScheduleHandler) CreateSchedule(c *gin.Context) {
+	var req models.JobScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	schedule := models.JobSchedule{
+		Enabled:       true,
+		SkipIfRunning: true,
+		CreatedBy:     c.GetUint("user_id"),
+	}
+	if err := h.applyScheduleRequest(&schedule, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.db.Create(&schedule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建定时调度失败"})
+		return
+	}
+	// 布尔字段带有默认值，创建时为false会被忽略，需要单独写入
+	if !schedule.Enabled || !schedule.SkipIfRunning {
+		h.db.Model(&schedule).Updates(map[string]interface{}{
+			"enabled":         schedule.Enabled,
+			"skip_if_running": schedule.SkipIfRunning,
+		})
+	}
+
+	userID := c.GetUint("user_id")
+	h.activityService.LogSuccess(c, userID, "create", "job_schedule", &schedule.ID,
+		fmt.Sprintf("为作业 %d 创建定时调度 '%s'", schedule.JobID, schedule.CronExpr))
+
+	h.db.Preload("Job").Preload("User").First(&schedule, schedule.ID)
+	c.JSON(http.StatusCreated, schedule)
+}
+
+// UpdateSchedule 更新定时调度
+func (h *JobScheduleHandler) UpdateSchedule(c *gin.Context) {
+	schedule, ok := h.findSchedule(c)
+	if !ok || !h.checkScheduleOwner(c, schedule, "修改") {
+		return
+	}
+
+	var req models.JobScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	if err := h.applyScheduleRequest(schedule, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.db.Save(schedule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新定时调度失败"})
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	h.activityService.LogSuccess(c, userID, "update", "job_schedule", &schedule.ID,
+		fmt.Sprintf("更新作业 %d 的定时调度 '%s'", schedule.JobID, schedule.CronExpr))
+
+	h.db.Preload("Job").Preload("User").First(schedule, schedule.ID)
+	c.JSON(http.StatusOK, schedule)
+}
+
+// EnableSchedule 启用定时调度
+func (h *JobScheduleHandler) EnableSchedule(c *gin.Context) {
+	h.setScheduleEnabled(c, true)
+}
+
+// DisableSchedule 禁用定时调度
+func (h *JobScheduleHandler) DisableSchedule(c *gin.Context) {
+	h.setScheduleEnabled(c, false)
+}
+
+// setScheduleEnabled 切换定时调度的启用状态，启用时重新计算下次触发时间
+func (h *JobScheduleHandler) setScheduleEnabled(c *gin.Context, enabled bool) {
+	action, actionName := "disable", "禁用"
+	if enabled {
+		action, actionName = "enable", "启用"
+	}
+	schedule, ok := h.findSchedule(c)
+	if !ok || !h.checkScheduleOwner(c, schedule, actionName) {
+		return
+	}
+
+	updates := map[string]interface{}{"enabled": enabled, "next_run_at": nil}
+	if enabled {
+		next, err := scheduler.NextScheduleTime(schedule, time.Now())
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		updates["next_run_at"] = next
+	}
+	if err := h.db.Model(schedule).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新定时调度失败"})
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	h.activityService.LogSuccess(c, userID, action, "job_schedule", &schedule.ID,
+		fmt.Sprintf("%s作业 %d 的定时调度 '%s'", actionName, schedule.JobID, schedule.CronExpr))
+
+	h.db.First(schedule, schedule.ID)
+
+	c.JSON(http.StatusOK, schedule)
+}
+
+// DeleteSchedule 删除定时调度
+func (h *JobScheduleHandler) DeleteSchedule(c *gin.Context) {
+	schedule, ok := h.findSchedule(c)
+	if !ok || !h.checkScheduleOwner(c, schedule, "删除") {
+		return
+	}
+
+	if err := h.db.Delete(schedule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除定时调度失败"})
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	h.activityService.LogSuccess(c, userID, "delete", "job_schedule", &schedule.ID,
+		fmt.Sprintf("删除作业 %d 的定时调度 '%s'", schedule.JobID, schedule.CronExpr))
+
+	c.JSON(http.StatusOK, gin.H{"message": "定时调度删除成功"})
+}
+
+// PreviewSchedule 预览cron表达式接下来的触发时间
+func (h *JobScheduleHandler) PreviewSchedule(c *gin.Context) {
+	cronExpr := c.Query("cron_expr")
+	if cronExpr == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cron表达式不能为空"})
+		return
+	}
+	count, _ := strconv.Atoi(c.DefaultQuery("count", strconv.Itoa(defaultPreviewCount)))
+	if count < 1 || count > maxPreviewCount {
+		count = defaultPreviewCount
+	}
+
+	times, err := scheduler.NextScheduleTimes(cronExpr, c.Query("timezone"), time.Now(), count)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"cron_expr":  cronExpr,
+		"timezone":   c.Query("timezone"),
+		"next_times": times,
+	})
+}
+
+// findSchedule 根据路径参数获取定时调度，失败时已写入响应
+func (h *JobScheduleHandler) findSchedule(c *gin.Context) (*models.JobSchedule, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的调度ID"})
+		return nil, false
+	}
+
+	var schedule models.JobSchedule
+	if err := h.db.First(&schedule, uint(id)).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "定时调度不存在"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "获取定时调度失败"})
+		}
+		return nil, false
+	}
+	return &schedule, true
+}
+
+// checkScheduleOwner 只有调度的创建者或管理员可以修改、启停和删除调度：
+// 调度以创建者的名义触发运行，不能由其他用户改变调度的内容
+func (h *JobScheduleHandler) checkScheduleOwner(c *gin.Context, schedule *models.JobSchedule, actionName string) bool {
+	if schedule.CreatedBy != c.GetUint("user_id") && c.GetString("role") != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "没有权限" + actionName + "此定时调度"})
+		return false
+	}
+	return true
+}
+
+// applyScheduleRequest 校验请求并写入调度，同时计算下次触发时间
+func (h *JobScheduleHandler) applyScheduleRequest(schedule *models.JobSchedule, req *models.JobScheduleRequest) error {
+	var job models.Job
+	if err := h.db.Preload("Script").First(&job, req.JobID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return fmt.Errorf("作业不存在")
+		}
+		return fmt.Errorf("获取作业信息失败")
+	}
+
+	// 参数覆盖值需符合脚本参数定义
+	if _, err := services.ResolveJobParameters(&job, req.Parameters); err != nil {
+		return err
+	}
+	parameters := ""
+	if len(req.Parameters) > 0 {
+		data, err := json.Marshal(req.Parameters)
+		if err != nil {
+			return fmt.Errorf("参数格式错误")
+		}
+		parameters = string(data)
+	}
+
+	schedule.JobID = req.JobID
+	schedule.Name = req.Name
+	schedule.CronExpr = req.CronExpr
+	schedule.Timezone = req.Timezone
+	schedule.Parameters = parameters
+	if req.Enabled != nil {
+		schedule.Enabled = *req.Enabled
+	}
+	if req.SkipIfRunning != nil {
+		schedule.SkipIfRunning = *req.SkipIfRunning
+	}
+
+	// 校验cron表达式和时区，并计算下次触发时间
+	next, err := scheduler.NextScheduleTime(schedule, time.Now())
+	if err != nil {
+		return err
+	}
+	schedule.NextRunAt = nil
+	if schedule.Enabled {
+		schedule.NextRunAt = &next
+	}
+	return nil
+}
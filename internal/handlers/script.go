@@ -3,7 +3,9 @@ package handlers
 import (
 	"encoding/json"
 	"fmt"
+	"go-devops/internal/executor"
 	"go-devops/internal/models"
+	"go-devops/internal/services"
 	"net/http"
 	"strconv"
 	"strings"
@@ -91,11 +93,23 @@ func (h *ScriptHandler) CreateScript(c *gin.Context) {
 		return
 	}
 
+	if err := executor.ValidateScriptType(req.Type); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	parameters, err := services.EncodeScriptParameters(req.Parameters)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	script := models.Script{
 		Name:        req.Name,
 		Description: req.Description,
 		Content:     req.Content,
 		Type:        req.Type,
+		Parameters:  parameters,
 		CreatedBy:   userID.(uint),
 	}
 
@@ -144,7 +158,19 @@ func (h *ScriptHandler) UpdateScript(c *gin.Context) {
 		return
 	}
 
+	if err := executor.ValidateScriptType(req.Type); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	parameters, err := services.EncodeScriptParameters(req.Parameters)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// 更新脚本信息
+	script.Parameters = parameters
 	script.Name = req.Name
 	script.Description = req.Description
 	script.Content = req.Content
@@ -413,6 +439,11 @@ func (h *ScriptHandler) BatchImportScripts(c *gin.Context) {
 		if scriptType == "" {
 			scriptType = "shell" // 默认类型
 		}
+		if err := executor.ValidateScriptType(scriptType); err != nil {
+			errorCount++
+			errors = append(errors, fmt.Sprintf("第%d行错误：%v", i+1, err))
+			continue
+		}
 
 		script := models.Script{
 			Name:        name,
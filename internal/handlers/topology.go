@@ -354,14 +354,20 @@ func (h *TopologyHandler) CreateEnvironment(c *gin.Context) {
 		return
 	}
 
+	if req.RequireApproval && c.GetString("role") != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "只有管理员可以设置执行审批"})
+		return
+	}
+
 	// 自动生成唯一编码
 	code := h.generateUniqueEnvironmentCode(req.Name, req.BusinessID)
 
 	environment := models.Environment{
-		Name:        req.Name,
-		Code:        code,
-		BusinessID:  req.BusinessID,
-		Description: req.Description,
+		Name:            req.Name,
+		Code:            code,
+		BusinessID:      req.BusinessID,
+		Description:     req.Description,
+		RequireApproval: req.RequireApproval,
 	}
 
 	if err := h.db.Create(&environment).Error; err != nil {
@@ -412,10 +418,17 @@ func (h *TopologyHandler) UpdateEnvironment(c *gin.Context) {
 		return
 	}
 
+	// 审批设置只有管理员可以修改
+	if req.RequireApproval != environment.RequireApproval && c.GetString("role") != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "只有管理员可以修改执行审批设置"})
+		return
+	}
+
 	// 更新环境信息，但不更新code字段
 	environment.Name = req.Name
 	environment.BusinessID = req.BusinessID
 	environment.Description = req.Description
+	environment.RequireApproval = req.RequireApproval
 	// 不更新 environment.Code
 
 	if err := h.db.Save(&environment).Error; err != nil {
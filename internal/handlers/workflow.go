@@ -0,0 +1,359 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"go-devops/internal/models"
+	"go-devops/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// WorkflowHandler 工作流处理器
+type WorkflowHandler struct {
+	db              *gorm.DB
+	workflowService *services.WorkflowService
+	activityService *services.ActivityService
+}
+
+// NewWorkflowHandler 创建工作流处理器
+func NewWorkflowHandler(db *gorm.DB) *WorkflowHandler {
+	return &WorkflowHandler{
+		db:              db,
+		workflowService: services.NewWorkflowService(db),
+		activityService: services.NewActivityService(db),
+	}
+}
+
+// GetWorkflows 获取工作流列表
+func (h *WorkflowHandler) GetWorkflows(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 || size > 100 {
+		size = 20
+	}
+
+	offset := (page - 1) * size
+
+	var workflows []models.Workflow
+	var total int64
+
+	query := h.db.Model(&models.Workflow{})
+
+	// 搜索过滤
+	if search := c.Query("search"); search != "" {
+		query = query.Where("name LIKE ? OR description LIKE ?", "%"+search+"%", "%"+search+"%")
+	}
+
+	// 获取总数
+	query.Count(&total)
+
+	// 获取数据
+	if err := query.Preload("Steps", func(db *gorm.DB) *gorm.DB {
+		return db.Order("step_order ASC")
+	}).Preload("User").
+		Offset(offset).Limit(size).
+		Order("created_at DESC").
+		Find(&workflows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取工作流列表失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  workflows,
+		"total": total,
+		"page":  page,
+		"size":  size,
+	})
+}
+
+// GetWorkflow 获取单个工作流（包含步骤）
+func (h *WorkflowHandler) GetWorkflow(c *gin.Context) {
+	workflow, ok := h.findWorkflow(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, workflow)
+}
+
+// CreateWorkflow 创建工作流
+func (h *WorkflowHandler) CreateWorkflow(c *gin.Context) {
+	var req models.WorkflowRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	parameters, err := services.EncodeScriptParameters(req.Parameters)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	steps, err := h.workflowService.BuildWorkflowSteps(req.Parameters, req.Steps)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	workflow := models.Workflow{
+		Name:        req.Name,
+		Description: req.Description,
+		Parameters:  parameters,
+		Steps:       steps,
+		CreatedBy:   userID,
+	}
+	if err := h.db.Create(&workflow).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建工作流失败"})
+		return
+	}
+
+	h.activityService.LogSuccess(c, userID, "create", "workflow", &workflow.ID,
+		fmt.Sprintf("创建工作流 '%s'（%d 个步骤）", workflow.Name, len(steps)))
+
+	created, err := h.workflowService.LoadWorkflow(workflow.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+// UpdateWorkflow 更新工作流，步骤整体替换
+func (h *WorkflowHandler) UpdateWorkflow(c *gin.Context) {
+	workflow, ok := h.findWorkflow(c)
+	if !ok {
+		return
+	}
+
+	var req models.WorkflowRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	parameters, err := services.EncodeScriptParameters(req.Parameters)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	steps, err := h.workflowService.BuildWorkflowSteps(req.Parameters, req.Steps)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	for i := range steps {
+		steps[i].WorkflowID = workflow.ID
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Workflow{}).Where("id = ?", workflow.ID).Updates(map[string]interface{}{
+			"name":        req.Name,
+			"description": req.Description,
+			"parameters":  parameters,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("workflow_id = ?", workflow.ID).Delete(&models.WorkflowStep{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&steps).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新工作流失败"})
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	h.activityService.LogSuccess(c, userID, "update", "workflow", &workflow.ID,
+		fmt.Sprintf("更新工作流 '%s'（%d 个步骤）", req.Name, len(steps)))
+
+	updated, err := h.workflowService.LoadWorkflow(workflow.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+// DeleteWorkflow 删除工作流及其运行记录
+func (h *WorkflowHandler) DeleteWorkflow(c *gin.Context) {
+	workflow, ok := h.findWorkflow(c)
+	if !ok {
+		return
+	}
+
+	// 检查是否有正在运行的工作流
+	var runningCount int64
+	h.db.Model(&models.WorkflowRun{}).Where("workflow_id = ? AND status = ?", workflow.ID, "running").Count(&runningCount)
+	if runningCount > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "工作流正在运行中，无法删除"})
+		return
+	}
+
+	// 删除相关的执行记录、步骤运行记录、运行记录和步骤
+	stepRunIDs := h.db.Model(&models.WorkflowStepRun{}).Select("workflow_step_runs.id").
+		Joins("JOIN workflow_runs ON workflow_runs.id = workflow_step_runs.workflow_run_id").
+		Where("workflow_runs.workflow_id = ?", workflow.ID)
+	h.db.Where("execution_id IN (?)", h.db.Model(&models.JobExecution{}).Select("id").Where("workflow_step_run_id IN (?)", stepRunIDs)).
+		Delete(&models.JobExecutionAttempt{})
+	h.db.Where("workflow_step_run_id IN (?)", stepRunIDs).Delete(&models.JobExecution{})
+	h.db.Where("workflow_run_id IN (?)", h.db.Model(&models.WorkflowRun{}).Select("id").Where("workflow_id = ?", workflow.ID)).
+		Delete(&models.WorkflowStepRun{})
+	h.db.Where("workflow_id = ?", workflow.ID).Delete(&models.WorkflowRun{})
+	h.db.Where("workflow_id = ?", workflow.ID).Delete(&models.WorkflowStep{})
+
+	if err := h.db.Delete(&models.Workflow{}, workflow.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除工作流失败"})
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	h.activityService.LogSuccess(c, userID, "delete", "workflow", &workflow.ID,
+		fmt.Sprintf("删除工作流 '%s'", workflow.Name))
+
+	c.JSON(http.StatusOK, gin.H{"message": "工作流删除成功"})
+}
+
+// RunWorkflow 运行工作流，请求体可携带工作流参数值
+func (h *WorkflowHandler) RunWorkflow(c *gin.Context) {
+	workflow, ok := h.findWorkflow(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Parameters map[string]interface{} `json:"parameters"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+			return
+		}
+	}
+
+	userID := c.GetUint("user_id")
+	run, err := h.workflowService.StartWorkflowRun(workflow, req.Parameters, userID)
+	if err != nil {
+		var approvalErr *services.ApprovalRequiredError
+		if errors.As(err, &approvalErr) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	h.activityService.LogSuccess(c, userID, "execute", "workflow", &workflow.ID,
+		fmt.Sprintf("运行工作流 '%s'（运行ID: %d）", workflow.Name, run.ID))
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "工作流已开始运行",
+		"workflow_id": workflow.ID,
+		"run_id":      run.ID,
+	})
+}
+
+// GetWorkflowRuns 获取工作流的运行记录列表
+func (h *WorkflowHandler) GetWorkflowRuns(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的工作流ID"})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 || size > 100 {
+		size = 20
+	}
+
+	offset := (page - 1) * size
+
+	var runs []models.WorkflowRun
+	var total int64
+
+	query := h.db.Model(&models.WorkflowRun{}).Where("workflow_id = ?", id)
+
+	// 状态过滤
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	// 获取总数
+	query.Count(&total)
+
+	// 获取数据
+	if err := query.Preload("TriggeredUser").
+		Offset(offset).Limit(size).
+		Order("id DESC").
+		Find(&runs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取运行记录失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  runs,
+		"total": total,
+		"page":  page,
+		"size":  size,
+	})
+}
+
+// GetWorkflowRunDetail 获取工作流运行详情（包含各步骤的运行记录、输出和执行记录）
+func (h *WorkflowHandler) GetWorkflowRunDetail(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的运行ID"})
+		return
+	}
+
+	var run models.WorkflowRun
+	if err := h.db.Preload("Workflow").Preload("TriggeredUser").
+		Preload("Steps", func(db *gorm.DB) *gorm.DB {
+			return db.Order("step_order ASC, id ASC")
+		}).
+		Preload("Steps.Executions").Preload("Steps.Executions.Host").
+		First(&run, uint(id)).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "工作流运行记录不存在"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "获取工作流运行记录失败"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, run)
+}
+
+// findWorkflow 根据路径参数获取工作流，失败时已写入响应
+func (h *WorkflowHandler) findWorkflow(c *gin.Context) (*models.Workflow, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的工作流ID"})
+		return nil, false
+	}
+
+	workflow, err := h.workflowService.LoadWorkflow(uint(id))
+	if err != nil {
+		if err == services.ErrWorkflowNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "获取工作流信息失败"})
+		}
+		return nil, false
+	}
+	return workflow, true
+}
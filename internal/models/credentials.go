@@ -0,0 +1,156 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"go-devops/internal/logger"
+	"go-devops/internal/secret"
+
+	"gorm.io/gorm"
+)
+
+// 凭据（SSH凭据和提权密码）在数据库中加密存储：写入前加密，查询后解密，业务代码只处理明文。
+// 解密失败时不中断查询，字段保留密文（再次保存时原样写回），并在 CredentialError 中记录原因，
+// 连接和提权时拒绝使用该记录的凭据
+
+// credentialFields 主机凭据的列名和字段
+func (h *Host) credentialFields() map[string]*string {
+	return map[string]*string{
+		"password":        &h.Password,
+		"private_key":     &h.PrivateKey,
+		"passphrase":      &h.Passphrase,
+		"become_password": &h.BecomePassword,
+	}
+}
+
+// BeforeSave 写入前加密凭据
+func (h *Host) BeforeSave(tx *gorm.DB) error {
+	return encryptCredentials(tx, h.credentialFields())
+}
+
+// AfterSave 写入后将模型中的凭据恢复为明文
+func (h *Host) AfterSave(tx *gorm.DB) error {
+	h.CredentialError = decryptCredentials(fmt.Sprintf("主机 %s (ID: %d)", h.IP, h.ID), h.credentialFields())
+	return nil
+}
+
+// AfterFind 查询后解密凭据
+func (h *Host) AfterFind(tx *gorm.DB) error {
+	h.CredentialError = decryptCredentials(fmt.Sprintf("主机 %s (ID: %d)", h.IP, h.ID), h.credentialFields())
+	return nil
+}
+
+// credentialFields 跳板机凭据的列名和字段
+func (b *Bastion) credentialFields() map[string]*string {
+	return map[string]*string{
+		"password":    &b.Password,
+		"private_key": &b.PrivateKey,
+		"passphrase":  &b.Passphrase,
+	}
+}
+
+// BeforeSave 写入前加密凭据
+func (b *Bastion) BeforeSave(tx *gorm.DB) error {
+	return encryptCredentials(tx, b.credentialFields())
+}
+
+// AfterSave 写入后将模型中的凭据恢复为明文
+func (b *Bastion) AfterSave(tx *gorm.DB) error {
+	b.CredentialError = decryptCredentials(fmt.Sprintf("跳板机 %s (ID: %d)", b.Name, b.ID), b.credentialFields())
+	return nil
+}
+
+// AfterFind 查询后解密凭据
+func (b *Bastion) AfterFind(tx *gorm.DB) error {
+	b.CredentialError = decryptCredentials(fmt.Sprintf("跳板机 %s (ID: %d)", b.Name, b.ID), b.credentialFields())
+	return nil
+}
+
+// credentialFields 作业凭据的列名和字段
+func (j *Job) credentialFields() map[string]*string {
+	return map[string]*string{
+		"become_password": &j.BecomePassword,
+	}
+}
+
+// BeforeSave 写入前加密提权密码
+func (j *Job) BeforeSave(tx *gorm.DB) error {
+	return encryptCredentials(tx, j.credentialFields())
+}
+
+// AfterSave 写入后将模型中的提权密码恢复为明文
+func (j *Job) AfterSave(tx *gorm.DB) error {
+	j.CredentialError = decryptCredentials(fmt.Sprintf("作业 %s (ID: %d)", j.Name, j.ID), j.credentialFields())
+	return nil
+}
+
+// AfterFind 查询后解密提权密码
+func (j *Job) AfterFind(tx *gorm.DB) error {
+	j.CredentialError = decryptCredentials(fmt.Sprintf("作业 %s (ID: %d)", j.Name, j.ID), j.credentialFields())
+	return nil
+}
+
+// encryptCredentials 加密将要写入的凭据。以map更新时（Updates/Update）加密map中的值，
+// 否则加密模型字段；已加密的值（包括解密失败而保留的密文）不重复加密
+func encryptCredentials(tx *gorm.DB, fields map[string]*string) error {
+	if values, ok := tx.Statement.Dest.(map[string]interface{}); ok {
+		for column := range fields {
+			value, ok := values[column].(string)
+			if !ok {
+				continue
+			}
+			encrypted, err := encryptCredential(value)
+			if err != nil {
+				return err
+			}
+			values[column] = encrypted
+		}
+		return nil
+	}
+
+	for _, field := range fields {
+		encrypted, err := encryptCredential(*field)
+		if err != nil {
+			return err
+		}
+		*field = encrypted
+	}
+	return nil
+}
+
+func encryptCredential(value string) (string, error) {
+	if value == "" || secret.IsEncrypted(value) {
+		return value, nil
+	}
+	encrypted, err := secret.Encrypt(value)
+	if err != nil {
+		return "", fmt.Errorf("加密凭据失败: %v", err)
+	}
+	return encrypted, nil
+}
+
+// decryptCredentials 解密模型中的凭据，明文原样保留。无法解密的字段保留密文并记录日志，
+// 返回失败原因，全部成功时返回空字符串
+func decryptCredentials(owner string, fields map[string]*string) string {
+	var failures []string
+	for column, field := range fields {
+		plain, err := secret.Decrypt(*field)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("解密凭据 %s 失败: %v", column, err))
+			continue
+		}
+		*field = plain
+	}
+	if len(failures) == 0 {
+		return ""
+	}
+	sort.Strings(failures)
+	message := strings.Join(failures, "；")
+	logger.Logger.WithFields(map[string]interface{}{
+		"owner": owner,
+		"error": message,
+	}).Warn("凭据无法解密，已标记为不可用")
+	return message
+}
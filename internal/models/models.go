@@ -26,15 +26,57 @@ type Host struct {
 	Description string `json:"description"`
 	Tags        string `json:"tags"`
 	// SSH认证相关字段
-	AuthType   string    `json:"auth_type" gorm:"default:password"` // password, key
-	Username   string    `json:"username"`
-	Password   string    `json:"-" gorm:"column:password"` // 不在JSON中显示密码
-	PrivateKey string    `json:"-" gorm:"type:text"`       // SSH私钥，不在JSON中显示
-	Passphrase string    `json:"-"`                        // 私钥密码短语
+	AuthType    string `json:"auth_type" gorm:"default:password"` // password, key, agent, certificate, keyboard-interactive
+	Username    string `json:"username"`
+	Password    string `json:"-" gorm:"column:password"`     // 不在JSON中显示密码
+	PrivateKey  string `json:"-" gorm:"type:text"`           // SSH私钥，不在JSON中显示
+	Passphrase  string `json:"-"`                            // 私钥密码短语
+	Certificate string `json:"certificate" gorm:"type:text"` // OpenSSH用户证书，certificate认证时使用，为空时由CA签发短期证书
+	// 提权执行（become）设置
+	BecomeMethod   string    `json:"become_method"` // 为空不提权，sudo, su
+	BecomeUser     string    `json:"become_user"`   // 目标用户，为空时为root
+	BecomePassword string    `json:"-"`             // 提权密码（加密存储）
+	// 凭据无法解密（如主密钥未配置）时的原因，此时主机的凭据不可用
+	CredentialError string `json:"credential_error,omitempty" gorm:"-"`
+	// 经由跳板机连接，为空时直接连接
+	BastionID *uint `json:"bastion_id" gorm:"index"`
+	// SSH主机密钥（authorized_keys格式），连接时校验
+	HostKey                   string     `json:"host_key" gorm:"type:text"`
+	HostKeyFingerprint        string     `json:"host_key_fingerprint"` // SHA256指纹
+	HostKeyTrustedAt          *time.Time `json:"host_key_trusted_at"`
+	PendingHostKey            string     `json:"pending_host_key" gorm:"type:text"` // 未受信任的密钥：严格模式下首次连接或与已记录密钥不一致时记录，等待管理员确认
+	PendingHostKeyFingerprint string     `json:"pending_host_key_fingerprint"`
 	CreatedAt  time.Time `json:"created_at"`
 	UpdatedAt  time.Time `json:"updated_at"`
 }
 
+// 跳板机模型，主机经由跳板机（可多级）以 ProxyJump 方式连接
+type Bastion struct {
+	ID          uint   `json:"id" gorm:"primaryKey"`
+	Name        string `json:"name" gorm:"uniqueIndex;size:100;not null"`
+	IP          string `json:"ip" gorm:"not null"`
+	Port        int    `json:"port" gorm:"default:22"`
+	Description string `json:"description"`
+	ParentID    *uint  `json:"parent_id" gorm:"index"` // 经由的上一级跳板机，为空时直接连接
+	// SSH认证相关字段
+	AuthType    string `json:"auth_type" gorm:"default:password"` // password, key, agent, certificate, keyboard-interactive
+	Username    string `json:"username"`
+	Password    string `json:"-"`
+	PrivateKey  string `json:"-" gorm:"type:text"`
+	Passphrase  string `json:"-"`
+	Certificate string `json:"certificate" gorm:"type:text"` // OpenSSH用户证书，为空时由CA签发短期证书
+	// 凭据无法解密时的原因，此时跳板机的凭据不可用
+	CredentialError string `json:"credential_error,omitempty" gorm:"-"`
+	// SSH主机密钥，校验方式与主机相同
+	HostKey                   string     `json:"host_key" gorm:"type:text"`
+	HostKeyFingerprint        string     `json:"host_key_fingerprint"`
+	HostKeyTrustedAt          *time.Time `json:"host_key_trusted_at"`
+	PendingHostKey            string     `json:"pending_host_key" gorm:"type:text"`
+	PendingHostKeyFingerprint string     `json:"pending_host_key_fingerprint"`
+	CreatedAt                 time.Time  `json:"created_at"`
+	UpdatedAt                 time.Time  `json:"updated_at"`
+}
+
 // 脚本模型
 type Script struct {
 	ID          uint      `json:"id" gorm:"primaryKey"`
@@ -42,12 +84,41 @@ type Script struct {
 	Content     string    `json:"content" gorm:"type:text CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci"`
 	Type        string    `json:"type" gorm:"default:shell;type:varchar(50) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci"`
 	Description string    `json:"description" gorm:"type:text CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci"`
+	Parameters  string    `json:"parameters" gorm:"type:text"` // 参数定义（ScriptParameter的JSON数组）
 	CreatedBy   uint      `json:"created_by"`
 	User        User      `json:"user" gorm:"foreignKey:CreatedBy"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 }
 
+// 自定义脚本解释器，由管理员注册，内置类型不存储
+type ScriptInterpreter struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	Type        string    `json:"type" gorm:"uniqueIndex;size:50;not null"` // 脚本类型，对应Script.Type
+	Extension   string    `json:"extension"`                                // 脚本文件扩展名，如 .py
+	Probes      string    `json:"probes"`                                   // 解释器探测顺序，逗号分隔
+	Template    string    `json:"template"`                                 // 启动模板，支持 {interpreter} {script} {args}
+	Description string    `json:"description"`
+	CreatedBy   uint      `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// 脚本参数定义
+type ScriptParameter struct {
+	Name        string `json:"name"`        // 参数名，同时用于环境变量 DEVOPS_PARAM_<NAME>
+	Type        string `json:"type"`        // 参数类型：string, int, float, bool
+	Default     string `json:"default"`     // 默认值
+	Required    bool   `json:"required"`    // 是否必填
+	Description string `json:"description"` // 参数说明
+}
+
+// 解析后的脚本参数值，按脚本声明顺序作为位置参数传递
+type ScriptParameterValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
 // 作业
 type Job struct {
 	ID          uint      `json:"id" gorm:"primaryKey"`
@@ -56,30 +127,153 @@ type Job struct {
 	ScriptID    uint      `json:"script_id"`
 	Script      Script    `json:"script" gorm:"foreignKey:ScriptID"`
 	HostIDs     string    `json:"host_ids" gorm:"type:text"` // JSON数组存储主机ID列表
-	Parameters  string    `json:"parameters" gorm:"type:text"` // 脚本参数
+	Parameters  string    `json:"parameters" gorm:"type:text"` // 脚本参数值（JSON对象，参数名 -> 值）
 	Timeout     int       `json:"timeout" gorm:"default:300"` // 超时时间（秒）
-	Status      string    `json:"status" gorm:"default:pending"` // 作业状态：pending, running, completed, failed
+	Status      string    `json:"status" gorm:"default:pending"` // 作业状态：取最近一次运行的状态
 	// 文件关联字段
 	InputFileIDs    string `json:"input_file_ids" gorm:"type:text"`     // 输入文件ID列表（JSON数组）
 	SaveOutput      bool   `json:"save_output" gorm:"default:false"`    // 是否保存输出为文件
 	SaveError       bool   `json:"save_error" gorm:"default:false"`     // 是否保存错误日志为文件
 	OutputCategory  string `json:"output_category" gorm:"default:script_output"` // 输出文件分类
+	// 多主机执行调度
+	Concurrency      int    `json:"concurrency" gorm:"default:0"`          // 同时执行的最大主机数，0表示不限制
+	BatchSize        string `json:"batch_size"`                            // 滚动批次大小：主机数或百分比（如 "10%"），为空时不分批
+	StopOnFailure    bool   `json:"stop_on_failure" gorm:"default:false"`  // 失败主机数超过阈值时中止剩余主机
+	FailureThreshold int    `json:"failure_threshold" gorm:"default:0"`    // 允许失败的主机数
+	// 单主机执行失败后的重试策略
+	RetryMaxAttempts int    `json:"retry_max_attempts" gorm:"default:1"`   // 最大执行次数（含首次），1表示不重试
+	RetryBackoff     string `json:"retry_backoff" gorm:"default:fixed"`    // 重试间隔策略：fixed, linear, exponential
+	RetryInterval    int    `json:"retry_interval" gorm:"default:10"`      // 重试间隔基数（秒）
+	RetryOn          string `json:"retry_on"`                              // 需要重试的失败类型，逗号分隔：connection, exit_code, timeout；为空时只重试连接失败
+	// 提权执行（become），优先于主机设置
+	BecomeMethod        string `json:"become_method"`                       // 为空时沿用主机设置，none 不提权，sudo, su
+	BecomeUser          string `json:"become_user"`                         // 目标用户，为空时为root
+	BecomePassword      string `json:"-"`                                   // 提权密码（加密存储），为空时沿用主机的提权密码
+	BecomePasswordInput string `json:"become_password,omitempty" gorm:"-"` // 请求中的提权密码明文，不存储
+	CredentialError     string `json:"credential_error,omitempty" gorm:"-"` // 提权密码无法解密时的原因，此时不能使用作业的提权密码
+	// 执行结束后从工作目录收集的产物
+	ArtifactPatterns  string `json:"artifact_patterns" gorm:"type:text"`       // 相对工作目录的glob模式，逗号或换行分隔，如 reports/*.html
+	ArtifactSizeLimit int    `json:"artifact_size_limit" gorm:"default:100"`   // 每次运行所有主机的产物总大小上限（MB）
+	// 执行审批：需要审批时手动执行和重新运行先创建执行申请，由管理员批准后才启动
+	RequireApproval   bool `json:"require_approval" gorm:"default:false"`   // 作业本身需要审批，目标主机所在环境需要审批时同样需要
+	RequiredApprovals int  `json:"required_approvals" gorm:"default:1"`     // 需要的批准人数
 	CreatedBy   uint      `json:"created_by"`
 	User        User      `json:"user" gorm:"foreignKey:CreatedBy"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 }
 
+// 作业定时调度
+type JobSchedule struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	JobID         uint       `json:"job_id" gorm:"index;not null"`
+	Job           *Job       `json:"job,omitempty" gorm:"foreignKey:JobID"`
+	Name          string     `json:"name"`
+	CronExpr      string     `json:"cron_expr" gorm:"not null"`          // cron表达式：分 时 日 月 周
+	Timezone      string     `json:"timezone"`                           // 时区，例如 Asia/Shanghai，为空时使用服务器时区
+	Enabled       bool       `json:"enabled" gorm:"default:true"`        // 是否启用
+	SkipIfRunning bool       `json:"skip_if_running" gorm:"default:true"` // 上次运行未结束时跳过本次触发
+	Parameters    string     `json:"parameters" gorm:"type:text"`        // 覆盖作业参数值（JSON对象）
+	LastRunAt     *time.Time `json:"last_run_at"`                        // 上次触发时间
+	LastRunID     *uint      `json:"last_run_id"`                        // 上次触发的运行批次
+	NextRunAt     *time.Time `json:"next_run_at" gorm:"index"`           // 下次触发时间
+	CreatedBy     uint       `json:"created_by"`
+	User          User       `json:"user" gorm:"foreignKey:CreatedBy"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// 作业运行批次，一次作业执行在各主机上的执行记录归属同一批次
+type JobRun struct {
+	ID            uint           `json:"id" gorm:"primaryKey"`
+	JobID         uint           `json:"job_id" gorm:"index"`
+	Job           *Job           `json:"job,omitempty" gorm:"foreignKey:JobID"`
+	TriggerType   string         `json:"trigger_type" gorm:"default:manual"` // 触发来源：manual, schedule, workflow, rerun, dependency
+	TriggeredBy   uint           `json:"triggered_by"`                       // 触发者ID，定时触发时为作业创建者
+	ParentRunID   *uint          `json:"parent_run_id" gorm:"index"`         // 依赖触发时为上游作业的运行批次，重新运行时为源运行批次
+	TriggeredUser User           `json:"triggered_user" gorm:"foreignKey:TriggeredBy"`
+	Parameters    string         `json:"parameters" gorm:"type:text"`        // 本次运行最终使用的参数（JSON数组）
+	Status        string         `json:"status" gorm:"default:pending"`      // 运行状态：pending, running, completed, failed, partial_failed, cancelled
+	TotalCount    int            `json:"total_count"`                        // 主机总数
+	SuccessCount  int            `json:"success_count"`                      // 成功数
+	FailedCount   int            `json:"failed_count"`                       // 失败数（含超时和取消）
+	StartTime     time.Time      `json:"start_time"`
+	EndTime       *time.Time     `json:"end_time"`
+	Executions    []JobExecution `json:"executions,omitempty" gorm:"foreignKey:RunID"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+}
+
+// 执行申请：需要审批的作业在手动执行或重新运行时创建，达到所需批准人数后启动运行
+type ExecutionRequest struct {
+	ID                uint       `json:"id" gorm:"primaryKey"`
+	JobID             uint       `json:"job_id" gorm:"index"`
+	Job               *Job       `json:"job,omitempty" gorm:"foreignKey:JobID"`
+	TriggerType       string     `json:"trigger_type"`                       // 申请的操作：manual（执行）, rerun（重新运行）
+	Payload           string     `json:"payload" gorm:"type:text"`           // 原始请求（JSON）：执行时为参数覆盖值，重新运行时为重新运行请求
+	Reasons           string     `json:"reasons" gorm:"type:text"`           // 需要审批的原因，如 作业要求审批、环境 production 要求审批
+	Comment           string     `json:"comment" gorm:"type:text"`           // 申请说明
+	ScriptContent     string     `json:"script_content" gorm:"type:text"`    // 申请时的脚本内容
+	HostIDs           string     `json:"host_ids" gorm:"type:text"`          // 申请时作业的主机ID列表（JSON数组）
+	ConfigDigest      string     `json:"config_digest"`                      // 申请时运行配置的摘要，批准时不一致则拒绝启动
+	Status            string     `json:"status" gorm:"default:pending;index"` // 状态：pending, approved, rejected, expired, cancelled
+	RequiredApprovals int        `json:"required_approvals"`                 // 需要的批准人数
+	ApprovalCount     int        `json:"approval_count"`                     // 已批准人数
+	RequestedBy       uint       `json:"requested_by" gorm:"index"`
+	Requester         User       `json:"requester" gorm:"foreignKey:RequestedBy"`
+	ExpiresAt         time.Time  `json:"expires_at"`                         // 超过该时间仍未批准则过期
+	DecidedAt         *time.Time `json:"decided_at"`                         // 批准、驳回、过期或撤销的时间
+	RunID             *uint      `json:"run_id"`                             // 批准后启动的运行批次
+	StartError        string     `json:"start_error" gorm:"type:text"`       // 批准后启动运行失败的原因
+	Approvals         []ExecutionApproval `json:"approvals,omitempty" gorm:"foreignKey:RequestID"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+// 执行申请的审批记录，每个管理员对同一申请只能审批一次
+type ExecutionApproval struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	RequestID uint      `json:"request_id" gorm:"uniqueIndex:idx_execution_approval_user"`
+	UserID    uint      `json:"user_id" gorm:"uniqueIndex:idx_execution_approval_user"`
+	User      User      `json:"user" gorm:"foreignKey:UserID"`
+	Decision  string    `json:"decision"` // approve, reject
+	Comment   string    `json:"comment" gorm:"type:text"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// 作业依赖：上游作业运行结束且满足条件时触发下游作业
+type JobDependency struct {
+	ID              uint      `json:"id" gorm:"primaryKey"`
+	UpstreamJobID   uint      `json:"upstream_job_id" gorm:"index;not null"`
+	UpstreamJob     *Job      `json:"upstream_job,omitempty" gorm:"foreignKey:UpstreamJobID"`
+	DownstreamJobID uint      `json:"downstream_job_id" gorm:"index;not null"`
+	DownstreamJob   *Job      `json:"downstream_job,omitempty" gorm:"foreignKey:DownstreamJobID"`
+	Condition       string    `json:"condition" gorm:"default:success"` // 触发条件：success, failure, always
+	CreatedBy       uint      `json:"created_by"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
 // 作业执行记录
 type JobExecution struct {
 	ID          uint       `json:"id" gorm:"primaryKey"`
 	JobID       *uint      `json:"job_id"` // 允许为NULL，快速执行时不关联作业
 	Job         *Job       `json:"job" gorm:"foreignKey:JobID"`
+	RunID       *uint      `json:"run_id" gorm:"index"` // 所属运行批次，快速执行时为NULL
+	WorkflowStepRunID *uint `json:"workflow_step_run_id" gorm:"index"` // 所属工作流步骤运行，非工作流执行时为NULL
 	HostID      uint       `json:"host_id"`
 	Host        Host       `json:"host" gorm:"foreignKey:HostID"`
-	Status      string     `json:"status" gorm:"default:running"`
-	Output      string     `json:"output" gorm:"type:text"`
-	Error       string     `json:"error" gorm:"type:text"`
+	Status      string     `json:"status" gorm:"default:running"` // 执行状态：pending, running, completed, failed, timeout, cancelled, skipped
+	Output      string     `json:"output" gorm:"type:text"` // 标准输出
+	Error       string     `json:"error" gorm:"type:text"`  // 标准错误，无标准错误时为失败原因
+	Results     string     `json:"results" gorm:"type:text"` // 从标准输出提取的结构化结果（JSON对象）：::set-output 行和末尾的JSON对象
+	ExitCode    *int       `json:"exit_code"`               // 远程命令退出码，连接失败或超时时为空
+	FailureType string     `json:"failure_type" gorm:"index"` // 失败类型：exit_code, connection, timeout, cancelled, become
+	CancelledBy *uint      `json:"cancelled_by"`              // 取消执行的用户ID
+	AttemptCount int       `json:"attempt_count" gorm:"default:1"` // 实际执行次数（含重试）
+	Workdir     string     `json:"workdir"`                   // 远程工作目录（最后一次尝试），执行后删除，配置为失败时保留则可据此排查
+	Attempts    []JobExecutionAttempt `json:"attempts,omitempty" gorm:"foreignKey:ExecutionID"` // 启用重试时每次尝试的记录
+	Artifacts   []File     `json:"artifacts,omitempty" gorm:"foreignKey:ExecutionID"` // 执行结束后收集的产物文件
 	StartTime   time.Time  `json:"start_time"`
 	EndTime     *time.Time `json:"end_time"`
 	ExecutedBy  uint       `json:"executed_by"`
@@ -99,7 +293,106 @@ type JobExecution struct {
 	UpdatedAt     time.Time  `json:"updated_at"`
 }
 
+// 执行尝试记录，作业启用重试时记录每一次尝试
+type JobExecutionAttempt struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	ExecutionID uint      `json:"execution_id" gorm:"index"`
+	Attempt     int       `json:"attempt"`                 // 第几次尝试，从1开始
+	Status      string    `json:"status"`                  // 尝试结果：completed, failed, timeout, cancelled
+	Output      string    `json:"output" gorm:"type:text"` // 标准输出
+	Error       string    `json:"error" gorm:"type:text"`  // 标准错误，无标准错误时为失败原因
+	ExitCode    *int      `json:"exit_code"`
+	FailureType string    `json:"failure_type"` // 失败类型：exit_code, connection, timeout, cancelled, become
+	StartTime   time.Time `json:"start_time"`
+	EndTime     time.Time `json:"end_time"`
+	CreatedAt   time.Time `json:"created_at"`
+}
 
+// 工作流，由按顺序执行的多个步骤组成
+type Workflow struct {
+	ID          uint           `json:"id" gorm:"primaryKey"`
+	Name        string         `json:"name" gorm:"not null"`
+	Description string         `json:"description"`
+	Parameters  string         `json:"parameters" gorm:"type:text"` // 工作流参数定义（ScriptParameter的JSON数组）
+	Steps       []WorkflowStep `json:"steps,omitempty" gorm:"foreignKey:WorkflowID"`
+	CreatedBy   uint           `json:"created_by"`
+	User        User           `json:"user" gorm:"foreignKey:CreatedBy"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
+
+// 工作流步骤：执行脚本或分发文件，每个步骤有自己的目标主机
+type WorkflowStep struct {
+	ID               uint      `json:"id" gorm:"primaryKey"`
+	WorkflowID       uint      `json:"workflow_id" gorm:"index"`
+	StepOrder        int       `json:"step_order"`                      // 执行顺序，从1开始
+	Name             string    `json:"name" gorm:"not null"`            // 步骤名，工作流内唯一，后续步骤通过名称引用其输出
+	Type             string    `json:"type" gorm:"not null"`            // 步骤类型：script, file_distribution
+	ScriptID         *uint     `json:"script_id"`                       // 脚本步骤执行的脚本
+	Script           *Script   `json:"script,omitempty" gorm:"foreignKey:ScriptID"`
+	FileID           *uint     `json:"file_id"`                         // 文件分发步骤分发的文件
+	File             *File     `json:"file,omitempty" gorm:"foreignKey:FileID"`
+	TargetPath       string    `json:"target_path"`                     // 文件分发的目标路径
+	HostIDs          string    `json:"host_ids" gorm:"type:text"`       // 目标主机ID列表（JSON数组）
+	Parameters       string    `json:"parameters" gorm:"type:text"`     // 脚本参数（JSON对象），值中可引用 ${params.名称} 和 ${steps.步骤名.输出名}
+	Timeout          int       `json:"timeout" gorm:"default:300"`      // 超时时间（秒）
+	OnFailure        string    `json:"on_failure" gorm:"default:abort"` // 失败策略：abort, continue, rollback
+	RollbackScriptID *uint     `json:"rollback_script_id"`              // 失败策略为rollback时在本步骤主机上执行的回滚脚本
+	RollbackScript   *Script   `json:"rollback_script,omitempty" gorm:"foreignKey:RollbackScriptID"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// 工作流运行记录
+type WorkflowRun struct {
+	ID            uint              `json:"id" gorm:"primaryKey"`
+	WorkflowID    uint              `json:"workflow_id" gorm:"index"`
+	Workflow      *Workflow         `json:"workflow,omitempty" gorm:"foreignKey:WorkflowID"`
+	Status        string            `json:"status" gorm:"default:pending"` // 运行状态：pending, running, completed, failed, partial_failed
+	Parameters    string            `json:"parameters" gorm:"type:text"`   // 本次运行的参数值（JSON对象）
+	CurrentStep   int               `json:"current_step"`                  // 当前执行的步骤顺序
+	Error         string            `json:"error" gorm:"type:text"`        // 失败原因
+	TriggeredBy   uint              `json:"triggered_by"`
+	TriggeredUser User              `json:"triggered_user" gorm:"foreignKey:TriggeredBy"`
+	StartTime     time.Time         `json:"start_time"`
+	EndTime       *time.Time        `json:"end_time"`
+	Steps         []WorkflowStepRun `json:"steps,omitempty" gorm:"foreignKey:WorkflowRunID"`
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+}
+
+// 工作流步骤运行记录，回滚也作为一条记录（类型为rollback）
+type WorkflowStepRun struct {
+	ID             uint           `json:"id" gorm:"primaryKey"`
+	WorkflowRunID  uint           `json:"workflow_run_id" gorm:"index"`
+	StepID         uint           `json:"step_id"`
+	StepOrder      int            `json:"step_order"`
+	Name           string         `json:"name"`
+	Type           string         `json:"type"`                          // 步骤类型：script, file_distribution, rollback
+	Status         string         `json:"status" gorm:"default:pending"` // 步骤状态：pending, running, completed, failed, skipped
+	Parameters     string         `json:"parameters" gorm:"type:text"`   // 替换引用后的脚本参数（JSON对象）
+	Outputs        string         `json:"outputs" gorm:"type:text"`      // 步骤输出（JSON对象），供后续步骤引用
+	Error          string         `json:"error" gorm:"type:text"`
+	DistributionID *uint          `json:"distribution_id"` // 文件分发步骤的分发记录
+	Executions     []JobExecution `json:"executions,omitempty" gorm:"foreignKey:WorkflowStepRunID"`
+	StartTime      *time.Time     `json:"start_time"`
+	EndTime        *time.Time     `json:"end_time"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+}
+
+
+
+// 作业定时调度创建/更新请求
+type JobScheduleRequest struct {
+	JobID         uint                   `json:"job_id" binding:"required"`
+	Name          string                 `json:"name"`
+	CronExpr      string                 `json:"cron_expr" binding:"required"`
+	Timezone      string                 `json:"timezone"`
+	Enabled       *bool                  `json:"enabled"`         // 不传时默认启用
+	SkipIfRunning *bool                  `json:"skip_if_running"` // 不传时默认跳过重叠运行
+	Parameters    map[string]interface{} `json:"parameters"`      // 覆盖作业参数值
+}
 
 // 用户登录请求
 type LoginRequest struct {
@@ -128,11 +421,17 @@ type HostRequest struct {
 	OS          string `json:"os"`
 	Description string `json:"description"`
 	Tags        string `json:"tags"`
-	AuthType    string `json:"auth_type"` // password, key
+	AuthType    string `json:"auth_type"` // password, key, agent, certificate, keyboard-interactive
 	Username    string `json:"username"`
 	Password    string `json:"password"`
 	PrivateKey  string `json:"private_key"`
 	Passphrase  string `json:"passphrase"`
+	Certificate string `json:"certificate"` // OpenSSH用户证书，更新时为空表示不修改
+	// 提权执行（become）设置
+	BecomeMethod   string `json:"become_method"`   // 为空不提权，sudo, su
+	BecomeUser     string `json:"become_user"`     // 目标用户，为空时为root
+	BecomePassword string `json:"become_password"` // 提权密码，更新时为空表示不修改
+	BastionID      *uint  `json:"bastion_id"`      // 经由的跳板机，为空时直接连接
 }
 
 // SSH连接测试响应
@@ -165,10 +464,37 @@ type BatchImportError struct {
 
 // 主机密码更新请求
 type HostPasswordUpdateRequest struct {
-	AuthType   string `json:"auth_type,omitempty"`
-	Password   string `json:"password,omitempty"`
-	PrivateKey string `json:"private_key,omitempty"`
-	Passphrase string `json:"passphrase,omitempty"`
+	AuthType    string `json:"auth_type,omitempty"`
+	Password    string `json:"password,omitempty"`
+	PrivateKey  string `json:"private_key,omitempty"`
+	Passphrase  string `json:"passphrase,omitempty"`
+	Certificate string `json:"certificate,omitempty"`
+}
+
+// 跳板机创建/更新请求
+type BastionRequest struct {
+	Name        string `json:"name" binding:"required"`
+	IP          string `json:"ip" binding:"required"`
+	Port        int    `json:"port"`
+	Description string `json:"description"`
+	ParentID    *uint  `json:"parent_id"`
+	AuthType    string `json:"auth_type"` // password, key, agent, certificate, keyboard-interactive
+	Username    string `json:"username" binding:"required"`
+	Password    string `json:"password"`    // 更新时为空表示不修改
+	PrivateKey  string `json:"private_key"` // 更新时为空表示不修改
+	Passphrase  string `json:"passphrase"`  // 更新时为空表示不修改
+	Certificate string `json:"certificate"` // 更新时为空表示不修改
+}
+
+// 主机密钥确认请求
+type HostKeyApproveRequest struct {
+	Fingerprint string `json:"fingerprint"` // 待确认密钥的指纹，提供时须与当前待确认密钥一致
+}
+
+// known_hosts 导入请求
+type KnownHostsImportRequest struct {
+	Content   string `json:"content" binding:"required"` // OpenSSH known_hosts 文件内容
+	Overwrite bool   `json:"overwrite"`                  // 覆盖已信任的不同密钥
 }
 
 // 批量主机操作请求
@@ -213,6 +539,7 @@ type Environment struct {
 	BusinessID  uint      `json:"business_id"`
 	Business    Business  `json:"business" gorm:"foreignKey:BusinessID"`
 	Description string    `json:"description"`
+	RequireApproval bool  `json:"require_approval" gorm:"default:false"` // 在该环境的主机上执行作业需要审批
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 }
@@ -273,6 +600,7 @@ type EnvironmentRequest struct {
 	Code        string `json:"code"` // 编码由后端自动生成
 	BusinessID  uint   `json:"business_id" binding:"required"`
 	Description string `json:"description"`
+	RequireApproval bool `json:"require_approval"` // 只有管理员可以修改
 }
 
 type ClusterRequest struct {
@@ -318,6 +646,7 @@ type File struct {
 	UploadedBy  uint      `json:"uploaded_by"`                             // 上传者ID
 	User        User      `json:"user" gorm:"foreignKey:UploadedBy"`       // 上传者信息
 	DownloadCount int     `json:"download_count" gorm:"default:0"`         // 下载次数
+	ExecutionID *uint     `json:"execution_id" gorm:"index"`               // 产物所属的执行记录，非产物文件为NULL
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 }
@@ -377,6 +706,47 @@ type FileUpdateRequest struct {
 	IsPublic    bool   `json:"is_public"`
 }
 
+// 作业重新运行请求
+type JobRerunRequest struct {
+	RunID   uint   `json:"run_id"`   // 源运行批次，不传时使用最近一次运行
+	Mode    string `json:"mode"`     // failed_only（默认）, all, selected_hosts
+	HostIDs []uint `json:"host_ids"` // selected_hosts 模式下要重新运行的主机
+	Comment string `json:"comment"`  // 需要审批时的申请说明
+}
+
+// 执行申请的审批请求
+type ExecutionApprovalRequest struct {
+	Comment string `json:"comment"`
+}
+
+// 作业依赖创建请求
+type JobDependencyRequest struct {
+	UpstreamJobID uint   `json:"upstream_job_id" binding:"required"`
+	Condition     string `json:"condition"` // success（默认）, failure, always
+}
+
+// 工作流创建/更新请求
+type WorkflowRequest struct {
+	Name        string                `json:"name" binding:"required"`
+	Description string                `json:"description"`
+	Parameters  []ScriptParameter     `json:"parameters"` // 工作流参数定义
+	Steps       []WorkflowStepRequest `json:"steps" binding:"required"`
+}
+
+// 工作流步骤请求
+type WorkflowStepRequest struct {
+	Name             string                 `json:"name" binding:"required"`
+	Type             string                 `json:"type" binding:"required"` // script, file_distribution
+	ScriptID         uint                   `json:"script_id"`
+	FileID           uint                   `json:"file_id"`
+	TargetPath       string                 `json:"target_path"`
+	HostIDs          []uint                 `json:"host_ids"`
+	Parameters       map[string]interface{} `json:"parameters"`
+	Timeout          int                    `json:"timeout"`
+	OnFailure        string                 `json:"on_failure"` // abort（默认）, continue, rollback
+	RollbackScriptID uint                   `json:"rollback_script_id"`
+}
+
 // 作业创建/更新请求（扩展支持文件关联）
 type JobRequest struct {
 	Name           string `json:"name" binding:"required"`
@@ -393,10 +763,20 @@ type JobRequest struct {
 
 // 脚本创建/更新请求
 type ScriptRequest struct {
-	Name        string `json:"name" binding:"required"`
-	Description string `json:"description"`
-	Content     string `json:"content" binding:"required"`
-	Type        string `json:"type" binding:"required"`
+	Name        string            `json:"name" binding:"required"`
+	Description string            `json:"description"`
+	Content     string            `json:"content" binding:"required"`
+	Type        string            `json:"type" binding:"required"`
+	Parameters  []ScriptParameter `json:"parameters"` // 参数定义
+}
+
+// 自定义脚本解释器创建/更新请求
+type ScriptInterpreterRequest struct {
+	Type        string   `json:"type" binding:"required"`
+	Extension   string   `json:"extension"`
+	Probes      []string `json:"probes" binding:"required,min=1"`
+	Template    string   `json:"template"` // 为空时使用 {interpreter} {script} {args}
+	Description string   `json:"description"`
 }
 
 // 脚本执行结果文件保存请求
@@ -0,0 +1,31 @@
+package scheduler
+
+import (
+	"time"
+
+	"go-devops/internal/logger"
+	"go-devops/internal/services"
+)
+
+// 执行申请过期检查间隔
+const approvalExpiryInterval = time.Minute
+
+// 执行申请过期检查任务：将超过有效期仍未批准的申请标记为过期
+func (s *Scheduler) startApprovalExpiryChecker() {
+	approvalService := services.NewApprovalService(s.db)
+
+	ticker := time.NewTicker(approvalExpiryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if expired := approvalService.ExpireRequests(); expired > 0 {
+				logger.Infof("已将 %d 个执行申请标记为过期", expired)
+			}
+		case <-s.stopChan:
+			logger.Info("执行申请过期检查任务停止")
+			return
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package scheduler
+
+import (
+	"fmt"
+	"time"
+
+	"go-devops/internal/logger"
+	"go-devops/internal/models"
+	"go-devops/internal/services"
+)
+
+// 检查到期定时作业的间隔
+const scheduleCheckInterval = 30 * time.Second
+
+// LoadScheduleLocation 加载调度时区，为空时使用服务器时区
+func LoadScheduleLocation(timezone string) (*time.Location, error) {
+	if timezone == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("时区 '%s' 无效", timezone)
+	}
+	return loc, nil
+}
+
+// NextScheduleTimes 按调度的时区计算after之后的n次触发时间
+func NextScheduleTimes(cronExpr, timezone string, after time.Time, n int) ([]time.Time, error) {
+	cron, err := ParseCron(cronExpr)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := LoadScheduleLocation(timezone)
+	if err != nil {
+		return nil, err
+	}
+	times := cron.NextN(after.In(loc), n)
+	if len(times) == 0 {
+		return nil, fmt.Errorf("cron表达式 '%s' 不会触发", cronExpr)
+	}
+	return times, nil
+}
+
+// NextScheduleTime 计算调度在after之后的下一次触发时间
+// 返回服务器时区的时间，保证数据库中的next_run_at与当前时间可以直接比较
+func NextScheduleTime(schedule *models.JobSchedule, after time.Time) (time.Time, error) {
+	times, err := NextScheduleTimes(schedule.CronExpr, schedule.Timezone, after, 1)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return times[0].Local(), nil
+}
+
+// 定时作业检查任务
+func (s *Scheduler) startJobScheduleRunner() {
+	logger.Infof("定时作业检查间隔设置为: %v", scheduleCheckInterval)
+
+	ticker := time.NewTicker(scheduleCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.runDueSchedules()
+		case <-s.stopChan:
+			logger.Info("定时作业检查任务停止")
+			return
+		}
+	}
+}
+
+// runDueSchedules 触发所有已到期的定时作业
+func (s *Scheduler) runDueSchedules() {
+	now := time.Now()
+
+	var schedules []models.JobSchedule
+	if err := s.db.Where("enabled = ? AND next_run_at IS NOT NULL AND next_run_at <= ?", true, now).
+		Find(&schedules).Error; err != nil {
+		logger.Errorf("获取到期的定时作业失败: %v", err)
+		return
+	}
+
+	for i := range schedules {
+		s.fireSchedule(&schedules[i], now)
+	}
+}
+
+// fireSchedule 触发单个定时作业：先推进下次触发时间，再启动作业运行
+func (s *Scheduler) fireSchedule(schedule *models.JobSchedule, now time.Time) {
+	updates := map[string]interface{}{"last_run_at": now, "next_run_at": nil}
+	if next, err := NextScheduleTime(schedule, now); err != nil {
+		logger.Errorf("定时作业 %d 计算下次触发时间失败: %v", schedule.ID, err)
+	} else {
+		updates["next_run_at"] = next
+	}
+
+	// 只有next_run_at仍处于到期状态时才触发，避免多个实例重复执行；错过的触发时间只补触发一次
+	result := s.db.Model(&models.JobSchedule{}).
+		Where("id = ? AND next_run_at <= ?", schedule.ID, now).
+		Updates(updates)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return
+	}
+
+	if schedule.SkipIfRunning && s.jobRunning(schedule.JobID) {
+		logger.Warnf("作业 %d 上次运行尚未结束，跳过定时触发（调度ID: %d）", schedule.JobID, schedule.ID)
+		return
+	}
+
+	values, err := services.ParseParameterValues(schedule.Parameters)
+	if err != nil {
+		logger.Errorf("定时作业 %d 参数格式错误: %v", schedule.ID, err)
+		return
+	}
+	overrides := make(map[string]interface{}, len(values))
+	for name, value := range values {
+		overrides[name] = value
+	}
+
+	request, err := s.executionService.NewJobRunRequest(schedule.JobID, overrides, services.TriggerSchedule, schedule.CreatedBy)
+	if err != nil {
+		logger.Errorf("定时作业 %d 触发失败: %v", schedule.ID, err)
+		return
+	}
+	payload := services.ExecutionRequestPayload{Parameters: overrides}
+	run, approval, err := s.executionService.StartJobRunOrRequestApproval(request, payload,
+		fmt.Sprintf("定时调度 '%s'（ID: %d）触发", schedule.Name, schedule.ID))
+	if err != nil {
+		logger.Errorf("定时作业 %d 启动运行失败: %v", schedule.ID, err)
+		if run != nil {
+			// 运行已创建并标记为失败
+			s.db.Model(&models.JobSchedule{}).Where("id = ?", schedule.ID).Update("last_run_id", run.ID)
+		}
+		return
+	}
+	if approval != nil {
+		logger.Warnf("定时作业需要审批，未启动运行 - 调度ID: %d, 作业ID: %d, 执行申请ID: %d", schedule.ID, schedule.JobID, approval.ID)
+		return
+	}
+
+	s.db.Model(&models.JobSchedule{}).Where("id = ?", schedule.ID).Update("last_run_id", run.ID)
+	logger.Infof("定时作业已触发 - 调度ID: %d, 作业ID: %d, 运行ID: %d", schedule.ID, schedule.JobID, run.ID)
+}
+
+// jobRunning 判断作业是否有尚未结束的运行
+func (s *Scheduler) jobRunning(jobID uint) bool {
+	var count int64
+	s.db.Model(&models.JobRun{}).Where("job_id = ? AND status = ?", jobID, "running").Count(&count)
+	return count > 0
+}
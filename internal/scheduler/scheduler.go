@@ -4,16 +4,18 @@ import (
 	"time"
 	"go-devops/internal/config"
 	"go-devops/internal/models"
+	"go-devops/internal/services"
 	"go-devops/internal/ssh"
 	"go-devops/internal/logger"
 	"gorm.io/gorm"
 )
 
 type Scheduler struct {
-	db       *gorm.DB
-	stopChan chan bool
-	running  bool
-	cfg      *config.Config
+	db               *gorm.DB
+	stopChan         chan bool
+	running          bool
+	cfg              *config.Config
+	executionService *services.ExecutionService
 }
 
 func NewScheduler(db *gorm.DB) *Scheduler {
@@ -25,10 +27,11 @@ func NewScheduler(db *gorm.DB) *Scheduler {
 	}
 	
 	return &Scheduler{
-		db:       db,
-		stopChan: make(chan bool),
-		running:  false,
-		cfg:      cfg,
+		db:               db,
+		stopChan:         make(chan bool),
+		running:          false,
+		cfg:              cfg,
+		executionService: services.NewExecutionService(db),
 	}
 }
 
@@ -50,6 +53,12 @@ func (s *Scheduler) Start() {
 	
 	// 启动主机状态检查定时任务
 	go s.startHostStatusChecker()
+
+	// 启动定时作业检查任务
+	go s.startJobScheduleRunner()
+
+	// 启动执行申请过期检查任务
+	go s.startApprovalExpiryChecker()
 }
 
 // 停止定时任务调度器
@@ -59,7 +68,8 @@ func (s *Scheduler) Stop() {
 	}
 	
 	s.running = false
-	s.stopChan <- true
+	// 关闭通道以通知所有定时任务退出
+	close(s.stopChan)
 	logger.Infof("定时任务调度器停止")
 }
 
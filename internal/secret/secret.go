@@ -0,0 +1,240 @@
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// 密文前缀，用于区分加密值和历史明文
+const (
+	// v1：直接使用主密钥加密，只用于解密历史数据
+	encryptedPrefixV1 = "enc:v1:"
+	// v2：信封加密，每个值使用随机数据密钥加密，数据密钥由主密钥加密后随密文存储，
+	// 格式为 enc:v2:<主密钥ID>:<加密的数据密钥>:<密文>
+	encryptedPrefixV2 = "enc:v2:"
+)
+
+// 数据密钥长度（AES-256）
+const dataKeySize = 32
+
+// masterKey 主密钥，ID用于在密文中标识加密时使用的主密钥
+type masterKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+var (
+	mu       sync.RWMutex
+	current  *masterKey
+	previous []*masterKey // 轮换前的主密钥，只用于解密
+)
+
+// ErrKeyNotConfigured 未初始化加密密钥
+var ErrKeyNotConfigured = errors.New("未配置加密密钥")
+
+// Init 使用配置的主密钥初始化加密器，主密钥经SHA-256派生为AES-256密钥。
+// previousKeys 为轮换前的主密钥，用于解密尚未重新加密的数据
+func Init(key string, previousKeys ...string) error {
+	if key == "" {
+		return ErrKeyNotConfigured
+	}
+	cur, err := newMasterKey(key)
+	if err != nil {
+		return err
+	}
+	var prev []*masterKey
+	for _, k := range previousKeys {
+		if k == "" || k == key {
+			continue
+		}
+		mk, err := newMasterKey(k)
+		if err != nil {
+			return err
+		}
+		prev = append(prev, mk)
+	}
+
+	mu.Lock()
+	current = cur
+	previous = prev
+	mu.Unlock()
+	return nil
+}
+
+func newMasterKey(key string) (*masterKey, error) {
+	sum := sha256.Sum256([]byte(key))
+	aead, err := newAEAD(sum[:])
+	if err != nil {
+		return nil, fmt.Errorf("初始化加密器失败: %v", err)
+	}
+	id := sha256.Sum256(sum[:])
+	return &masterKey{id: hex.EncodeToString(id[:4]), aead: aead}, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func keys() (*masterKey, []*masterKey, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if current == nil {
+		return nil, nil, ErrKeyNotConfigured
+	}
+	return current, previous, nil
+}
+
+// KeyID 返回当前主密钥的ID
+func KeyID() string {
+	cur, _, err := keys()
+	if err != nil {
+		return ""
+	}
+	return cur.id
+}
+
+// IsEncrypted 判断值是否为本包生成的密文
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, encryptedPrefixV2) || strings.HasPrefix(value, encryptedPrefixV1)
+}
+
+// NeedsRotation 判断非空值是否需要用当前主密钥重新加密：明文、v1密文或由其他主密钥加密的密文
+func NeedsRotation(value string) bool {
+	if value == "" {
+		return false
+	}
+	if !strings.HasPrefix(value, encryptedPrefixV2) {
+		return true
+	}
+	id, _, _ := strings.Cut(strings.TrimPrefix(value, encryptedPrefixV2), ":")
+	return id != KeyID()
+}
+
+// Encrypt 使用信封加密：随机数据密钥以AES-GCM加密数据，主密钥加密数据密钥。空字符串原样返回
+func Encrypt(plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+	cur, _, err := keys()
+	if err != nil {
+		return "", err
+	}
+
+	dataKey := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return "", fmt.Errorf("生成数据密钥失败: %v", err)
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", fmt.Errorf("初始化加密器失败: %v", err)
+	}
+	sealed, err := seal(aead, []byte(plaintext), nil)
+	if err != nil {
+		return "", err
+	}
+	// 数据密钥绑定主密钥ID，防止被替换到其他主密钥下
+	wrapped, err := seal(cur.aead, dataKey, []byte(cur.id))
+	if err != nil {
+		return "", err
+	}
+	return encryptedPrefixV2 + cur.id + ":" +
+		base64.StdEncoding.EncodeToString(wrapped) + ":" +
+		base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt 解密Encrypt生成的密文，非密文（历史明文）原样返回
+func Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	cur, prev, err := keys()
+	if err != nil {
+		return "", err
+	}
+	if strings.HasPrefix(value, encryptedPrefixV1) {
+		return decryptV1(strings.TrimPrefix(value, encryptedPrefixV1), append([]*masterKey{cur}, prev...))
+	}
+
+	parts := strings.Split(strings.TrimPrefix(value, encryptedPrefixV2), ":")
+	if len(parts) != 3 {
+		return "", errors.New("密文格式错误")
+	}
+	id := parts[0]
+	var key *masterKey
+	for _, mk := range append([]*masterKey{cur}, prev...) {
+		if mk.id == id {
+			key = mk
+			break
+		}
+	}
+	if key == nil {
+		return "", fmt.Errorf("解密失败，未配置加密该值的主密钥（ID: %s）", id)
+	}
+
+	wrapped, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("密文格式错误: %v", err)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", fmt.Errorf("密文格式错误: %v", err)
+	}
+	dataKey, err := open(key.aead, wrapped, []byte(id))
+	if err != nil {
+		return "", fmt.Errorf("解密数据密钥失败: %v", err)
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", fmt.Errorf("密文格式错误: %v", err)
+	}
+	plaintext, err := open(aead, sealed, nil)
+	if err != nil {
+		return "", fmt.Errorf("解密失败: %v", err)
+	}
+	return string(plaintext), nil
+}
+
+// decryptV1 解密直接由主密钥加密的历史密文，依次尝试各主密钥
+func decryptV1(encoded string, candidates []*masterKey) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("密文格式错误: %v", err)
+	}
+	for _, mk := range candidates {
+		if plaintext, err := open(mk.aead, sealed, nil); err == nil {
+			return string(plaintext), nil
+		}
+	}
+	return "", errors.New("解密失败，加密密钥可能已变更")
+}
+
+// seal 加密并在密文前附加随机nonce
+func seal(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("生成随机数失败: %v", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// open 解密seal生成的密文
+func open(aead cipher.AEAD, sealed, additionalData []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("密文格式错误")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
@@ -47,6 +47,24 @@ func (s *ActivityService) LogFailure(c *gin.Context, userID uint, action, resour
 	s.LogActivity(c, userID, action, resource, resourceID, description, "failed", errorMsg)
 }
 
+// 记录后台任务（如定时检查）产生的活动，没有请求上下文
+func (s *ActivityService) LogSystem(userID uint, action, resource string, resourceID *uint, description string) {
+	activity := models.UserActivity{
+		UserID:      userID,
+		Action:      action,
+		Resource:    resource,
+		ResourceID:  resourceID,
+		Description: description,
+		UserAgent:   "system",
+		Status:      "success",
+		CreatedAt:   time.Now(),
+	}
+
+	if err := s.db.Create(&activity).Error; err != nil {
+		logger.Errorf("记录用户活动失败: %v", err)
+	}
+}
+
 // 获取用户活动列表（支持分页和筛选）
 func (s *ActivityService) GetActivities(page, size int, userID *uint, action, resource, status string, startDate, endDate, keyword string) ([]models.UserActivity, int64, error) {
 	var activities []models.UserActivity
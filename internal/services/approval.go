@@ -0,0 +1,577 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go-devops/internal/executor"
+	"go-devops/internal/logger"
+	"go-devops/internal/models"
+	"go-devops/internal/ssh"
+
+	"gorm.io/gorm"
+)
+
+// 执行申请状态
+const (
+	ApprovalPending   = "pending"
+	ApprovalApproved  = "approved"
+	ApprovalRejected  = "rejected"
+	ApprovalExpired   = "expired"
+	ApprovalCancelled = "cancelled"
+)
+
+// 审批决定
+const (
+	DecisionApprove = "approve"
+	DecisionReject  = "reject"
+)
+
+// 执行申请的默认有效期（小时）
+const defaultApprovalExpireHours = 24
+
+var (
+	ErrExecutionRequestNotFound = errors.New("执行申请不存在")
+	ErrExecutionRequestClosed   = errors.New("执行申请已结束（已批准、驳回、过期或撤销）")
+	ErrExecutionRequestExpired  = errors.New("执行申请已过期，请重新申请")
+	ErrSelfApproval             = errors.New("不能审批自己提交的执行申请")
+	ErrAlreadyReviewed          = errors.New("已审批过该执行申请")
+	ErrCancelNotAllowed         = errors.New("只有申请人或管理员可以撤销执行申请")
+)
+
+// approvalConfig 执行审批配置，由ConfigureApproval在启动时设置
+var approvalConfig = struct {
+	sync.RWMutex
+	expire time.Duration
+}{expire: defaultApprovalExpireHours * time.Hour}
+
+// ConfigureApproval 设置执行申请的有效期（小时），<=0 时使用默认值
+func ConfigureApproval(expireHours int) {
+	if expireHours <= 0 {
+		expireHours = defaultApprovalExpireHours
+	}
+	approvalConfig.Lock()
+	defer approvalConfig.Unlock()
+	approvalConfig.expire = time.Duration(expireHours) * time.Hour
+}
+
+func approvalExpire() time.Duration {
+	approvalConfig.RLock()
+	defer approvalConfig.RUnlock()
+	return approvalConfig.expire
+}
+
+// ExecutionRequestPayload 执行申请保存的原始请求，批准后据此重新构造运行
+type ExecutionRequestPayload struct {
+	Parameters  map[string]interface{} `json:"parameters,omitempty"`    // 执行时的参数覆盖值
+	RunID       uint                   `json:"run_id,omitempty"`        // 重新运行的源运行批次（已解析为具体ID）
+	Mode        string                 `json:"mode,omitempty"`          // 重新运行模式
+	HostIDs     []uint                 `json:"host_ids,omitempty"`      // selected_hosts 模式下的主机
+	ParentRunID uint                   `json:"parent_run_id,omitempty"` // 依赖触发时的上游运行批次
+}
+
+// ApprovalRequiredError 运行需要审批但未经批准
+type ApprovalRequiredError struct {
+	Reasons []string
+}
+
+func (e *ApprovalRequiredError) Error() string {
+	return strings.Join(e.Reasons, "；") + "，需要审批后才能执行"
+}
+
+// ApprovalService 执行审批服务
+type ApprovalService struct {
+	db               *gorm.DB
+	executionService *ExecutionService
+	activityService  *ActivityService
+}
+
+// NewApprovalService 创建执行审批服务
+func NewApprovalService(db *gorm.DB) *ApprovalService {
+	return &ApprovalService{
+		db:               db,
+		executionService: NewExecutionService(db),
+		activityService:  NewActivityService(db),
+	}
+}
+
+// ApprovalReasons 返回作业在目标主机上执行需要审批的原因，为空表示不需要审批。
+// job为空时只检查主机所在的环境
+func (s *ApprovalService) ApprovalReasons(job *models.Job, hostIDs []uint) ([]string, error) {
+	return s.executionService.approvalReasons(job, hostIDs)
+}
+
+// CreateRequest 为需要审批的运行创建执行申请，保存申请时的脚本内容和主机列表
+func (s *ApprovalService) CreateRequest(req JobRunRequest, payload ExecutionRequestPayload, reasons []string, comment string) (*models.ExecutionRequest, error) {
+	return s.executionService.createExecutionRequest(req, payload, reasons, comment)
+}
+
+// approvalReasons 返回作业在目标主机上执行需要审批的原因，为空表示不需要审批。
+// job为空时只检查主机所在的环境
+func (s *ExecutionService) approvalReasons(job *models.Job, hostIDs []uint) ([]string, error) {
+	var reasons []string
+	if job != nil && job.RequireApproval {
+		reasons = append(reasons, "作业要求审批")
+	}
+	if len(hostIDs) == 0 {
+		return reasons, nil
+	}
+
+	var environments []string
+	if err := s.db.Model(&models.Environment{}).
+		Joins("JOIN clusters ON clusters.environment_id = environments.id").
+		Joins("JOIN host_topologies ON host_topologies.cluster_id = clusters.id").
+		Where("environments.require_approval = ? AND host_topologies.host_id IN ?", true, hostIDs).
+		Distinct().Pluck("environments.name", &environments).Error; err != nil {
+		return nil, fmt.Errorf("获取主机所在环境失败: %v", err)
+	}
+	sort.Strings(environments)
+	for _, name := range environments {
+		reasons = append(reasons, fmt.Sprintf("环境 %s 要求审批", name))
+	}
+	return reasons, nil
+}
+
+// createExecutionRequest 为需要审批的运行创建执行申请，保存申请时的脚本内容和主机列表
+func (s *ExecutionService) createExecutionRequest(req JobRunRequest, payload ExecutionRequestPayload, reasons []string, comment string) (*models.ExecutionRequest, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("序列化执行申请失败: %v", err)
+	}
+	triggerType := req.TriggerType
+	if triggerType == "" {
+		triggerType = TriggerManual
+	}
+	required := req.Job.RequiredApprovals
+	if required < 1 {
+		required = 1
+	}
+	digest, err := s.runConfigDigest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	request := &models.ExecutionRequest{
+		JobID:             req.Job.ID,
+		TriggerType:       triggerType,
+		Payload:           string(data),
+		Reasons:           strings.Join(reasons, "；"),
+		Comment:           comment,
+		ScriptContent:     req.Job.Script.Content,
+		HostIDs:           runHostIDs(req.Hosts),
+		ConfigDigest:      digest,
+		Status:            ApprovalPending,
+		RequiredApprovals: required,
+		RequestedBy:       req.TriggeredBy,
+		ExpiresAt:         time.Now().Add(approvalExpire()),
+	}
+	if err := s.db.Create(request).Error; err != nil {
+		return nil, fmt.Errorf("创建执行申请失败: %v", err)
+	}
+
+	logger.Logger.WithFields(map[string]interface{}{
+		"request_id":   request.ID,
+		"job_id":       request.JobID,
+		"trigger_type": triggerType,
+		"requested_by": request.RequestedBy,
+		"reasons":      request.Reasons,
+	}).Info("已创建执行申请")
+	return request, nil
+}
+
+// StartJobRunOrRequestApproval 启动定时调度、依赖触发等自动触发的运行：需要审批时不启动，
+// 改为以触发者的名义创建执行申请，由管理员批准后再启动。定时调度已有待审批的申请时不重复创建
+func (s *ExecutionService) StartJobRunOrRequestApproval(req JobRunRequest, payload ExecutionRequestPayload, comment string) (*models.JobRun, *models.ExecutionRequest, error) {
+	run, _, err := s.StartJobRun(req)
+	var approvalErr *ApprovalRequiredError
+	if !errors.As(err, &approvalErr) {
+		return run, nil, err
+	}
+
+	if req.TriggerType == TriggerSchedule {
+		var pending models.ExecutionRequest
+		if err := s.db.Where("job_id = ? AND trigger_type = ? AND status = ? AND expires_at > ?",
+			req.Job.ID, req.TriggerType, ApprovalPending, time.Now()).First(&pending).Error; err == nil {
+			return nil, &pending, nil
+		}
+	}
+
+	request, err := s.createExecutionRequest(req, payload, approvalErr.Reasons, comment)
+	if err != nil {
+		return nil, nil, err
+	}
+	NewActivityService(s.db).LogSystem(req.TriggeredBy, "request_approval", "execution_request", &request.ID,
+		fmt.Sprintf("%s，申请执行作业 '%s' 在 %d 台主机上（%s）", comment, req.Job.Name, len(req.Hosts), request.Reasons))
+	return nil, request, nil
+}
+
+// runHostIDs 返回排序后的主机ID列表（JSON数组）
+func runHostIDs(hosts []models.Host) string {
+	ids := hostIDsOf(hosts)
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	data, _ := json.Marshal(ids)
+	return string(data)
+}
+
+// runConfigSnapshot 影响运行内容和方式的全部配置，用于计算执行申请的配置摘要
+type runConfigSnapshot struct {
+	ScriptContent  string                        `json:"script_content"`
+	ScriptType     string                        `json:"script_type"`
+	Interpreter    *ssh.Interpreter              `json:"interpreter"`
+	HostIDs        string                        `json:"host_ids"`
+	Hosts          []string                      `json:"hosts"`
+	Parameters     []models.ScriptParameterValue `json:"parameters"`
+	InputFileIDs   string                        `json:"input_file_ids"`
+	InputFiles     []string                      `json:"input_files"`
+	Timeout        int                           `json:"timeout"`
+	BecomeMethod   string                        `json:"become_method"`
+	BecomeUser     string                        `json:"become_user"`
+	Retry          RetryPolicy                   `json:"retry"`
+	Dispatch       DispatchOptions               `json:"dispatch"`
+	Artifacts      ArtifactPolicy                `json:"artifacts"`
+	SaveOutput     bool                          `json:"save_output"`
+	SaveError      bool                          `json:"save_error"`
+	OutputCategory string                        `json:"output_category"`
+}
+
+// runConfigDigest 计算运行配置的摘要（SHA-256）：脚本内容和类型、解释器、目标主机（地址、端口、登录用户、
+// 认证方式、跳板机和提权设置）、参数、输入文件（含内容哈希）、超时、提权、重试、调度、产物和输出保存设置。
+// 密码等凭据不参与计算
+func (s *ExecutionService) runConfigDigest(req JobRunRequest) (string, error) {
+	job := req.Job
+	snapshot := runConfigSnapshot{
+		ScriptContent:  job.Script.Content,
+		ScriptType:     job.Script.Type,
+		HostIDs:        runHostIDs(req.Hosts),
+		Parameters:     req.Parameters,
+		InputFileIDs:   job.InputFileIDs,
+		Timeout:        job.Timeout,
+		BecomeMethod:   job.BecomeMethod,
+		BecomeUser:     job.BecomeUser,
+		Retry:          RetryPolicyFromJob(job),
+		Dispatch:       DispatchOptionsFromJob(job),
+		Artifacts:      ArtifactPolicyFromJob(job),
+		SaveOutput:     job.SaveOutput,
+		SaveError:      job.SaveError,
+		OutputCategory: job.OutputCategory,
+	}
+	if interpreter, ok := executor.LookupInterpreter(job.Script.Type); ok {
+		snapshot.Interpreter = &interpreter
+	}
+	hosts := append([]models.Host(nil), req.Hosts...)
+	sort.Slice(hosts, func(i, j int) bool { return hosts[i].ID < hosts[j].ID })
+	// 主机的连接目标也参与计算，避免批准后修改主机地址将运行转到其他机器
+	for _, host := range hosts {
+		snapshot.Hosts = append(snapshot.Hosts, fmt.Sprintf("%d:%s:%d:%s:%s:%s:%s:%s", host.ID, host.IP, host.Port,
+			host.Username, host.AuthType, bastionIDString(host.BastionID), host.BecomeMethod, host.BecomeUser))
+	}
+	files := s.loadInputFiles(job)
+	sort.Slice(files, func(i, j int) bool { return files[i].ID < files[j].ID })
+	for _, file := range files {
+		snapshot.InputFiles = append(snapshot.InputFiles, fmt.Sprintf("%d:%s:%d:%s", file.ID, file.OriginalName, file.Size, file.MD5Hash))
+	}
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return "", fmt.Errorf("计算运行配置摘要失败: %v", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// bastionIDString 返回跳板机ID，直接连接时为空
+func bastionIDString(id *uint) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.FormatUint(uint64(*id), 10)
+}
+
+// hostIDsOf 返回主机的ID列表
+func hostIDsOf(hosts []models.Host) []uint {
+	ids := make([]uint, 0, len(hosts))
+	for _, host := range hosts {
+		ids = append(ids, host.ID)
+	}
+	return ids
+}
+
+// GetRequest 获取执行申请及其审批记录
+func (s *ApprovalService) GetRequest(id uint) (*models.ExecutionRequest, error) {
+	s.ExpireRequests()
+	var request models.ExecutionRequest
+	if err := s.db.Preload("Job").Preload("Requester").Preload("Approvals.User").First(&request, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrExecutionRequestNotFound
+		}
+		return nil, fmt.Errorf("获取执行申请失败: %v", err)
+	}
+	return &request, nil
+}
+
+// ListRequests 分页获取执行申请，可按状态和作业筛选
+func (s *ApprovalService) ListRequests(status string, jobID uint, page, size int) ([]models.ExecutionRequest, int64, error) {
+	s.ExpireRequests()
+	query := s.db.Model(&models.ExecutionRequest{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if jobID != 0 {
+		query = query.Where("job_id = ?", jobID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取执行申请失败: %v", err)
+	}
+	var requests []models.ExecutionRequest
+	if err := query.Preload("Job").Preload("Requester").Preload("Approvals.User").
+		Order("id DESC").Offset((page - 1) * size).Limit(size).Find(&requests).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取执行申请失败: %v", err)
+	}
+	return requests, total, nil
+}
+
+// loadPending 获取待审批的执行申请，已过期的申请在此时标记为过期
+func (s *ApprovalService) loadPending(id uint) (*models.ExecutionRequest, error) {
+	var request models.ExecutionRequest
+	if err := s.db.First(&request, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrExecutionRequestNotFound
+		}
+		return nil, fmt.Errorf("获取执行申请失败: %v", err)
+	}
+	if request.Status != ApprovalPending {
+		return nil, ErrExecutionRequestClosed
+	}
+	if !request.ExpiresAt.After(time.Now()) {
+		s.expireRequest(&request)
+		return nil, ErrExecutionRequestExpired
+	}
+	return &request, nil
+}
+
+// Approve 管理员批准执行申请，达到所需批准人数时启动运行。
+// 启动失败时申请仍为已批准，失败原因记录在 StartError 中
+func (s *ApprovalService) Approve(id, userID uint, comment string) (*models.ExecutionRequest, error) {
+	request, err := s.loadPending(id)
+	if err != nil {
+		return nil, err
+	}
+	if request.RequestedBy == userID {
+		return nil, ErrSelfApproval
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.recordDecision(tx, request.ID, userID, DecisionApprove, comment); err != nil {
+			return err
+		}
+		result := tx.Model(&models.ExecutionRequest{}).
+			Where("id = ? AND status = ?", request.ID, ApprovalPending).
+			Update("approval_count", gorm.Expr("approval_count + 1"))
+		if result.Error != nil {
+			return fmt.Errorf("更新执行申请失败: %v", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrExecutionRequestClosed
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.db.First(request, request.ID).Error; err != nil {
+		return nil, fmt.Errorf("获取执行申请失败: %v", err)
+	}
+	if request.ApprovalCount >= request.RequiredApprovals {
+		// 只有将状态改为已批准的审批人启动运行，避免并发批准时重复启动
+		now := time.Now()
+		result := s.db.Model(&models.ExecutionRequest{}).
+			Where("id = ? AND status = ?", request.ID, ApprovalPending).
+			Updates(map[string]interface{}{"status": ApprovalApproved, "decided_at": &now})
+		if result.Error == nil && result.RowsAffected == 1 {
+			request.Status = ApprovalApproved
+			request.DecidedAt = &now
+			s.startApprovedRun(request)
+		}
+	}
+	return s.GetRequest(request.ID)
+}
+
+// Reject 管理员驳回执行申请，任一管理员驳回即结束申请
+func (s *ApprovalService) Reject(id, userID uint, comment string) (*models.ExecutionRequest, error) {
+	request, err := s.loadPending(id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.recordDecision(tx, request.ID, userID, DecisionReject, comment); err != nil {
+			return err
+		}
+		now := time.Now()
+		result := tx.Model(&models.ExecutionRequest{}).
+			Where("id = ? AND status = ?", request.ID, ApprovalPending).
+			Updates(map[string]interface{}{"status": ApprovalRejected, "decided_at": &now})
+		if result.Error != nil {
+			return fmt.Errorf("更新执行申请失败: %v", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrExecutionRequestClosed
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetRequest(request.ID)
+}
+
+// Cancel 撤销待审批的执行申请，申请人和管理员可以撤销
+func (s *ApprovalService) Cancel(id, userID uint, isAdmin bool) (*models.ExecutionRequest, error) {
+	request, err := s.loadPending(id)
+	if err != nil {
+		return nil, err
+	}
+	if request.RequestedBy != userID && !isAdmin {
+		return nil, ErrCancelNotAllowed
+	}
+
+	now := time.Now()
+	result := s.db.Model(&models.ExecutionRequest{}).
+		Where("id = ? AND status = ?", request.ID, ApprovalPending).
+		Updates(map[string]interface{}{"status": ApprovalCancelled, "decided_at": &now})
+	if result.Error != nil {
+		return nil, fmt.Errorf("更新执行申请失败: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrExecutionRequestClosed
+	}
+	return s.GetRequest(request.ID)
+}
+
+// recordDecision 记录审批决定，同一管理员只能审批一次
+func (s *ApprovalService) recordDecision(tx *gorm.DB, requestID, userID uint, decision, comment string) error {
+	var count int64
+	tx.Model(&models.ExecutionApproval{}).Where("request_id = ? AND user_id = ?", requestID, userID).Count(&count)
+	if count > 0 {
+		return ErrAlreadyReviewed
+	}
+	approval := &models.ExecutionApproval{
+		RequestID: requestID,
+		UserID:    userID,
+		Decision:  decision,
+		Comment:   comment,
+	}
+	if err := tx.Create(approval).Error; err != nil {
+		// 唯一索引冲突：并发的重复审批
+		return ErrAlreadyReviewed
+	}
+	return nil
+}
+
+// startApprovedRun 按申请重新构造运行并启动。运行配置（见runConfigDigest）在申请后发生变化时不启动，
+// 以免执行未经审批的内容
+func (s *ApprovalService) startApprovedRun(request *models.ExecutionRequest) {
+	runRequest, err := s.rebuildRunRequest(request)
+	if err == nil {
+		var digest string
+		digest, err = s.executionService.runConfigDigest(runRequest)
+		if err == nil && digest != request.ConfigDigest {
+			err = errors.New("作业的运行配置（脚本、主机、参数、输入文件、提权、超时等）在申请后已修改，请重新申请")
+		}
+	}
+	var run *models.JobRun
+	if err == nil {
+		runRequest.Approved = true
+		run, _, err = s.executionService.StartJobRun(runRequest)
+	}
+
+	if err != nil {
+		request.StartError = err.Error()
+		updates := map[string]interface{}{"start_error": request.StartError}
+		if run != nil {
+			// 运行已创建但没有可执行的主机
+			request.RunID = &run.ID
+			updates["run_id"] = run.ID
+		}
+		s.db.Model(&models.ExecutionRequest{}).Where("id = ?", request.ID).Updates(updates)
+		logger.Logger.WithFields(map[string]interface{}{
+			"request_id": request.ID,
+			"job_id":     request.JobID,
+			"error":      err.Error(),
+		}).Error("执行申请已批准，但启动运行失败")
+		return
+	}
+
+	request.RunID = &run.ID
+	s.db.Model(&models.ExecutionRequest{}).Where("id = ?", request.ID).Update("run_id", run.ID)
+	logger.Logger.WithFields(map[string]interface{}{
+		"request_id": request.ID,
+		"job_id":     request.JobID,
+		"run_id":     run.ID,
+	}).Info("执行申请已批准，运行已启动")
+}
+
+// rebuildRunRequest 按申请时的原始请求重新构造运行，触发来源与申请一致，触发者为申请人
+func (s *ApprovalService) rebuildRunRequest(request *models.ExecutionRequest) (JobRunRequest, error) {
+	var payload ExecutionRequestPayload
+	if err := json.Unmarshal([]byte(request.Payload), &payload); err != nil {
+		return JobRunRequest{}, fmt.Errorf("执行申请格式错误: %v", err)
+	}
+	if request.TriggerType == TriggerRerun {
+		return s.executionService.NewRerunRequest(request.JobID, payload.RunID, payload.Mode, payload.HostIDs, request.RequestedBy)
+	}
+	req, err := s.executionService.NewJobRunRequest(request.JobID, payload.Parameters, request.TriggerType, request.RequestedBy)
+	if err == nil && payload.ParentRunID != 0 {
+		parentID := payload.ParentRunID
+		req.ParentRunID = &parentID
+	}
+	return req, err
+}
+
+// ExpireRequests 将超过有效期仍未批准的执行申请标记为过期，返回过期的数量
+func (s *ApprovalService) ExpireRequests() int {
+	var requests []models.ExecutionRequest
+	if err := s.db.Where("status = ? AND expires_at <= ?", ApprovalPending, time.Now()).Find(&requests).Error; err != nil {
+		logger.Errorf("获取过期的执行申请失败: %v", err)
+		return 0
+	}
+	expired := 0
+	for i := range requests {
+		if s.expireRequest(&requests[i]) {
+			expired++
+		}
+	}
+	return expired
+}
+
+// expireRequest 标记申请过期并记录到申请人的活动中
+func (s *ApprovalService) expireRequest(request *models.ExecutionRequest) bool {
+	now := time.Now()
+	result := s.db.Model(&models.ExecutionRequest{}).
+		Where("id = ? AND status = ?", request.ID, ApprovalPending).
+		Updates(map[string]interface{}{"status": ApprovalExpired, "decided_at": &now})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false
+	}
+	request.Status = ApprovalExpired
+	request.DecidedAt = &now
+
+	s.activityService.LogSystem(request.RequestedBy, "expire", "execution_request", &request.ID,
+		fmt.Sprintf("作业 %d 的执行申请已过期（已批准 %d/%d）", request.JobID, request.ApprovalCount, request.RequiredApprovals))
+	logger.Logger.WithFields(map[string]interface{}{
+		"request_id": request.ID,
+		"job_id":     request.JobID,
+	}).Warn("执行申请已过期")
+	return true
+}
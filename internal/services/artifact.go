@@ -0,0 +1,133 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"go-devops/internal/models"
+	"go-devops/internal/ssh"
+)
+
+// 产物保存的本地根目录，其下按 <运行批次ID>/<执行记录ID> 分目录
+const artifactRoot = "uploads/artifacts"
+
+// 每次运行的产物大小上限（MB）
+const (
+	defaultArtifactSizeLimit = 100
+	maxArtifactSizeLimit     = 10240
+)
+
+// ArtifactPolicy 执行结束后从工作目录收集产物的设置
+type ArtifactPolicy struct {
+	Patterns  []string // 相对工作目录的glob模式
+	SizeLimit int      // 同一运行批次所有主机的产物总大小上限（MB），<=0 时使用默认值
+}
+
+// ArtifactPolicyFromJob 读取作业中的产物设置，模式以逗号或换行分隔
+func ArtifactPolicyFromJob(job *models.Job) ArtifactPolicy {
+	policy := ArtifactPolicy{SizeLimit: job.ArtifactSizeLimit}
+	for _, pattern := range strings.FieldsFunc(job.ArtifactPatterns, func(r rune) bool {
+		return r == ',' || r == '\n'
+	}) {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			policy.Patterns = append(policy.Patterns, pattern)
+		}
+	}
+	return policy
+}
+
+// Validate 校验产物设置
+func (p ArtifactPolicy) Validate() error {
+	for _, pattern := range p.Patterns {
+		if err := ssh.ValidateArtifactPattern(pattern); err != nil {
+			return err
+		}
+	}
+	if p.SizeLimit > maxArtifactSizeLimit {
+		return fmt.Errorf("产物大小上限不能超过 %d MB", maxArtifactSizeLimit)
+	}
+	return nil
+}
+
+// Enabled 是否收集产物
+func (p ArtifactPolicy) Enabled() bool {
+	return len(p.Patterns) > 0
+}
+
+// sizeLimitBytes 返回产物大小上限（字节）
+func (p ArtifactPolicy) sizeLimitBytes() int64 {
+	limit := p.SizeLimit
+	if limit <= 0 {
+		limit = defaultArtifactSizeLimit
+	}
+	return int64(limit) * 1024 * 1024
+}
+
+// artifactQuotas 产物大小配额：同一运行批次的所有主机共享，不属于运行批次的执行单独计算
+var artifactQuotas = &artifactQuotaRegistry{used: make(map[string]int64)}
+
+type artifactQuotaRegistry struct {
+	mu   sync.Mutex
+	used map[string]int64
+}
+
+// reserve 申请配额，size为负数时归还
+func (r *artifactQuotaRegistry) reserve(key string, limit, size int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if size > 0 && r.used[key]+size > limit {
+		return fmt.Errorf("超出产物大小上限 %d MB，已跳过", limit/1024/1024)
+	}
+	r.used[key] += size
+	return nil
+}
+
+// release 运行批次或执行结束后释放配额记录
+func (r *artifactQuotaRegistry) release(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.used, key)
+}
+
+// artifactQuotaKey 返回配额的归属
+func artifactQuotaKey(runID, executionID uint) string {
+	if runID != 0 {
+		return fmt.Sprintf("run:%d", runID)
+	}
+	return fmt.Sprintf("execution:%d", executionID)
+}
+
+// artifactDir 返回执行的产物本地目录
+func artifactDir(runID, executionID uint) string {
+	run := "quick"
+	if runID != 0 {
+		run = fmt.Sprintf("%d", runID)
+	}
+	return filepath.Join(artifactRoot, run, fmt.Sprintf("%d", executionID))
+}
+
+// newArtifactRequest 生成单次尝试的产物收集请求，收集结果通过done返回
+func newArtifactRequest(policy ArtifactPolicy, runID, executionID uint, done func([]ssh.Artifact, []string)) *ssh.ArtifactRequest {
+	key := artifactQuotaKey(runID, executionID)
+	limit := policy.sizeLimitBytes()
+	return &ssh.ArtifactRequest{
+		Patterns: policy.Patterns,
+		LocalDir: artifactDir(runID, executionID),
+		Reserve: func(size int64) error {
+			return artifactQuotas.reserve(key, limit, size)
+		},
+		Done: done,
+	}
+}
+
+// discardArtifacts 丢弃被重试取代的尝试所收集的产物，并归还配额
+func discardArtifacts(runID, executionID uint, artifacts []ssh.Artifact) {
+	key := artifactQuotaKey(runID, executionID)
+	for _, artifact := range artifacts {
+		os.Remove(artifact.LocalPath)
+		artifactQuotas.reserve(key, 0, -artifact.Size)
+	}
+}
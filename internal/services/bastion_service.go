@@ -0,0 +1,282 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go-devops/internal/logger"
+	"go-devops/internal/models"
+	"go-devops/internal/ssh"
+
+	"gorm.io/gorm"
+)
+
+// 跳板机链路的最大级数
+const maxBastionHops = 5
+
+var (
+	// ErrBastionNotFound 跳板机不存在
+	ErrBastionNotFound = errors.New("跳板机不存在")
+	// ErrBastionInUse 跳板机仍被主机或其他跳板机使用
+	ErrBastionInUse = errors.New("跳板机正在使用中")
+)
+
+// BastionService 跳板机服务，同时为SSH连接提供跳板机链路
+type BastionService struct {
+	db *gorm.DB
+}
+
+// NewBastionService 创建跳板机服务
+func NewBastionService(db *gorm.DB) *BastionService {
+	return &BastionService{db: db}
+}
+
+// BastionChain 返回到达跳板机经过的链路，从可直接连接的第一跳到该跳板机本身
+func (s *BastionService) BastionChain(bastionID uint) ([]models.Bastion, error) {
+	var chain []models.Bastion
+	seen := make(map[uint]bool)
+	id := bastionID
+	for {
+		if seen[id] {
+			return nil, fmt.Errorf("跳板机链路存在循环（跳板机 %d）", id)
+		}
+		if len(chain) >= maxBastionHops {
+			return nil, fmt.Errorf("跳板机链路超过 %d 级", maxBastionHops)
+		}
+		seen[id] = true
+
+		var bastion models.Bastion
+		if err := s.db.First(&bastion, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, fmt.Errorf("跳板机 %d 不存在", id)
+			}
+			return nil, fmt.Errorf("获取跳板机失败: %v", err)
+		}
+		chain = append(chain, bastion)
+		if bastion.ParentID == nil {
+			break
+		}
+		id = *bastion.ParentID
+	}
+
+	// 按连接顺序排列
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+	return chain, nil
+}
+
+// ListBastions 获取跳板机列表
+func (s *BastionService) ListBastions() ([]models.Bastion, error) {
+	var bastions []models.Bastion
+	if err := s.db.Order("id ASC").Find(&bastions).Error; err != nil {
+		return nil, fmt.Errorf("获取跳板机列表失败: %v", err)
+	}
+	return bastions, nil
+}
+
+// GetBastion 获取跳板机
+func (s *BastionService) GetBastion(id uint) (*models.Bastion, error) {
+	var bastion models.Bastion
+	if err := s.db.First(&bastion, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBastionNotFound
+		}
+		return nil, err
+	}
+	return &bastion, nil
+}
+
+// CreateBastion 创建跳板机
+func (s *BastionService) CreateBastion(req models.BastionRequest) (*models.Bastion, error) {
+	bastion := models.Bastion{
+		Name:        req.Name,
+		IP:          req.IP,
+		Port:        req.Port,
+		Description: req.Description,
+		ParentID:    req.ParentID,
+		AuthType:    req.AuthType,
+		Username:    req.Username,
+		Password:    req.Password,
+		PrivateKey:  req.PrivateKey,
+		Passphrase:  req.Passphrase,
+		Certificate: req.Certificate,
+	}
+	if bastion.Port == 0 {
+		bastion.Port = 22
+	}
+	if bastion.AuthType == "" {
+		bastion.AuthType = "password"
+	}
+	if err := validateBastionAuth(&bastion); err != nil {
+		return nil, err
+	}
+	if err := s.validateParent(0, bastion.ParentID); err != nil {
+		return nil, err
+	}
+	if err := s.db.Create(&bastion).Error; err != nil {
+		return nil, fmt.Errorf("创建跳板机失败: %v", err)
+	}
+	logger.Infof("创建跳板机成功: %s (%s)", bastion.Name, bastion.IP)
+	return &bastion, nil
+}
+
+// UpdateBastion 更新跳板机，认证信息为空时不修改，地址变更时清除已记录的主机密钥
+func (s *BastionService) UpdateBastion(id uint, req models.BastionRequest) (*models.Bastion, error) {
+	bastion, err := s.GetBastion(id)
+	if err != nil {
+		return nil, err
+	}
+	if req.Port == 0 {
+		req.Port = 22
+	}
+	if req.AuthType == "" {
+		req.AuthType = bastion.AuthType
+	}
+	addressChanged := req.IP != bastion.IP || req.Port != bastion.Port
+
+	bastion.Name = req.Name
+	bastion.IP = req.IP
+	bastion.Port = req.Port
+	bastion.Description = req.Description
+	bastion.ParentID = req.ParentID
+	bastion.AuthType = req.AuthType
+	bastion.Username = req.Username
+	if req.Password != "" {
+		bastion.Password = req.Password
+	}
+	if req.PrivateKey != "" {
+		bastion.PrivateKey = req.PrivateKey
+	}
+	if req.Passphrase != "" {
+		bastion.Passphrase = req.Passphrase
+	}
+	if req.Certificate != "" {
+		bastion.Certificate = req.Certificate
+	}
+	if err := validateBastionAuth(bastion); err != nil {
+		return nil, err
+	}
+	if err := s.validateParent(id, bastion.ParentID); err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{
+		"name":        bastion.Name,
+		"ip":          bastion.IP,
+		"port":        bastion.Port,
+		"description": bastion.Description,
+		"parent_id":   bastion.ParentID,
+		"auth_type":   bastion.AuthType,
+		"username":    bastion.Username,
+		"password":    bastion.Password,
+		"private_key": bastion.PrivateKey,
+		"passphrase":  bastion.Passphrase,
+		"certificate": bastion.Certificate,
+	}
+	if addressChanged {
+		for field, value := range ResetHostKeyFields() {
+			updates[field] = value
+		}
+	}
+	if err := s.db.Model(bastion).Updates(updates).Error; err != nil {
+		return nil, fmt.Errorf("更新跳板机失败: %v", err)
+	}
+	return s.GetBastion(id)
+}
+
+// DeleteBastion 删除跳板机，仍被主机或其他跳板机使用时拒绝
+func (s *BastionService) DeleteBastion(id uint) (*models.Bastion, error) {
+	bastion, err := s.GetBastion(id)
+	if err != nil {
+		return nil, err
+	}
+	var hostCount, childCount int64
+	if err := s.db.Model(&models.Host{}).Where("bastion_id = ?", id).Count(&hostCount).Error; err != nil {
+		return nil, err
+	}
+	if err := s.db.Model(&models.Bastion{}).Where("parent_id = ?", id).Count(&childCount).Error; err != nil {
+		return nil, err
+	}
+	if hostCount > 0 || childCount > 0 {
+		return nil, fmt.Errorf("%w：%d 台主机、%d 个跳板机经由该跳板机连接", ErrBastionInUse, hostCount, childCount)
+	}
+	if err := s.db.Delete(bastion).Error; err != nil {
+		return nil, fmt.Errorf("删除跳板机失败: %v", err)
+	}
+	return bastion, nil
+}
+
+// ValidateHostBastion 检查主机引用的跳板机存在且链路有效
+func (s *BastionService) ValidateHostBastion(bastionID *uint) error {
+	if bastionID == nil {
+		return nil
+	}
+	_, err := s.BastionChain(*bastionID)
+	return err
+}
+
+// ApproveHostKey 信任跳板机的待确认密钥
+func (s *BastionService) ApproveHostKey(id uint, fingerprint string) (*models.Bastion, error) {
+	bastion, err := s.GetBastion(id)
+	if err != nil {
+		return nil, err
+	}
+	if bastion.PendingHostKey == "" {
+		return nil, ErrNoPendingHostKey
+	}
+	if fingerprint != "" && fingerprint != bastion.PendingHostKeyFingerprint {
+		return nil, ErrPendingHostKeyChanged
+	}
+	if err := s.db.Model(bastion).Updates(trustedKeyUpdates(bastion.PendingHostKey, bastion.PendingHostKeyFingerprint, time.Now())).Error; err != nil {
+		return nil, fmt.Errorf("更新跳板机密钥失败: %v", err)
+	}
+	return s.GetBastion(id)
+}
+
+// ResetHostKey 清除跳板机已记录的密钥，下次连接时按策略重新记录
+func (s *BastionService) ResetHostKey(id uint) (*models.Bastion, error) {
+	bastion, err := s.GetBastion(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.Model(bastion).Updates(ResetHostKeyFields()).Error; err != nil {
+		return nil, fmt.Errorf("重置跳板机密钥失败: %v", err)
+	}
+	return s.GetBastion(id)
+}
+
+// validateParent 检查上一级跳板机存在，且不会形成循环或超过最大级数
+func (s *BastionService) validateParent(id uint, parentID *uint) error {
+	if parentID == nil {
+		return nil
+	}
+	if *parentID == id {
+		return fmt.Errorf("上一级跳板机不能是自身")
+	}
+	chain, err := s.BastionChain(*parentID)
+	if err != nil {
+		return err
+	}
+	for _, hop := range chain {
+		if id != 0 && hop.ID == id {
+			return fmt.Errorf("上一级跳板机经由该跳板机连接，会形成循环")
+		}
+	}
+	if len(chain)+1 > maxBastionHops {
+		return fmt.Errorf("跳板机链路超过 %d 级", maxBastionHops)
+	}
+	return nil
+}
+
+// validateBastionAuth 检查跳板机认证信息完整
+func validateBastionAuth(bastion *models.Bastion) error {
+	return ssh.ValidateAuth(bastion.AuthType, bastion.Password, bastion.PrivateKey, bastion.Certificate)
+}
+
+// 确保实现SSH连接使用的接口
+var (
+	_ ssh.BastionStore = (*BastionService)(nil)
+	_ ssh.HostKeyStore = (*HostKeyService)(nil)
+)
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"fmt"
+
+	"go-devops/internal/models"
+	"go-devops/internal/ssh"
+)
+
+// BecomeNone 作业级设置：不提权，忽略主机上的提权设置
+const BecomeNone = "none"
+
+// BecomeSettings 作业级提权设置，Method为空时沿用主机设置
+type BecomeSettings struct {
+	Method          string // "", none, sudo, su
+	User            string // 目标用户，为空时为root
+	Password        string // 提权密码，为空时沿用主机的提权密码
+	CredentialError string // 作业的提权密码无法解密时的原因
+}
+
+// BecomeSettingsFromJob 读取作业中的提权设置
+func BecomeSettingsFromJob(job *models.Job) BecomeSettings {
+	return BecomeSettings{
+		Method:          job.BecomeMethod,
+		User:            job.BecomeUser,
+		Password:        job.BecomePassword,
+		CredentialError: job.CredentialError,
+	}
+}
+
+// ValidateJobBecome 校验作业级提权设置
+func ValidateJobBecome(method, user string) error {
+	if method == "" || method == BecomeNone {
+		return nil
+	}
+	if err := ssh.ValidateBecome(method, user); err != nil {
+		return fmt.Errorf("%v，作业还可以设置为 none 表示不提权", err)
+	}
+	return nil
+}
+
+// ValidateHostBecome 校验主机级提权设置，Method为空表示不提权
+func ValidateHostBecome(method, user string) error {
+	if method == "" {
+		return nil
+	}
+	return ssh.ValidateBecome(method, user)
+}
+
+// resolveBecome 合并作业和主机的提权设置：作业设置优先，作业未设置密码时沿用主机的提权密码
+func resolveBecome(settings BecomeSettings, host *models.Host) (*ssh.Become, error) {
+	method, user, password := host.BecomeMethod, host.BecomeUser, host.BecomePassword
+	credentialErr := host.CredentialError
+	switch settings.Method {
+	case "":
+	case BecomeNone:
+		return nil, nil
+	default:
+		method, user = settings.Method, settings.User
+		if settings.Password != "" || settings.CredentialError != "" {
+			password, credentialErr = settings.Password, settings.CredentialError
+		}
+	}
+	if method == "" {
+		return nil, nil
+	}
+
+	if credentialErr != "" {
+		return nil, fmt.Errorf("提权密码不可用，请重新设置: %s", credentialErr)
+	}
+	return &ssh.Become{Method: method, User: user, Password: password}, nil
+}
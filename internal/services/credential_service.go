@@ -0,0 +1,128 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"go-devops/internal/logger"
+	"go-devops/internal/secret"
+
+	"gorm.io/gorm"
+)
+
+// encryptedColumns 加密存储的列，均由模型钩子透明加解密
+var encryptedColumns = []struct {
+	table   string
+	columns []string
+}{
+	{"hosts", []string{"password", "private_key", "passphrase", "become_password"}},
+	{"bastions", []string{"password", "private_key", "passphrase"}},
+	{"jobs", []string{"become_password"}},
+}
+
+// CredentialReport 重新加密的统计
+type CredentialReport struct {
+	KeyID  string         `json:"key_id"` // 当前主密钥ID
+	Rows   map[string]int `json:"rows"`   // 各表更新的行数
+	Values int            `json:"values"` // 重新加密的值的数量
+}
+
+// CredentialService 加密存储的凭据的迁移和主密钥轮换
+type CredentialService struct {
+	db *gorm.DB
+}
+
+// NewCredentialService 创建凭据加密服务
+func NewCredentialService(db *gorm.DB) *CredentialService {
+	return &CredentialService{db: db}
+}
+
+// EncryptPlaintext 加密历史数据中的明文凭据，已加密的值不变，可重复执行
+func (s *CredentialService) EncryptPlaintext() (*CredentialReport, error) {
+	return s.reencrypt(func(value string) bool {
+		return value != "" && !secret.IsEncrypted(value)
+	})
+}
+
+// RotateKeys 使用当前主密钥重新加密所有未由其加密的值（包括明文和由旧主密钥加密的值），
+// 旧主密钥需配置在 security.previous_secret_keys 中。任一值无法解密时不做任何修改
+func (s *CredentialService) RotateKeys() (*CredentialReport, error) {
+	return s.reencrypt(secret.NeedsRotation)
+}
+
+// reencrypt 在一个事务中解密并用当前主密钥重新加密needed为true的值，
+// 直接按表更新，不触发模型钩子
+func (s *CredentialService) reencrypt(needed func(value string) bool) (*CredentialReport, error) {
+	report := &CredentialReport{KeyID: secret.KeyID(), Rows: make(map[string]int)}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		for _, target := range encryptedColumns {
+			updates, err := s.collect(tx, target.table, target.columns, needed)
+			if err != nil {
+				return err
+			}
+			for id, values := range updates {
+				if err := tx.Table(target.table).Where("id = ?", id).UpdateColumns(values).Error; err != nil {
+					return fmt.Errorf("更新 %s (ID: %d) 失败: %v", target.table, id, err)
+				}
+				report.Values += len(values)
+			}
+			report.Rows[target.table] = len(updates)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Logger.WithFields(map[string]interface{}{
+		"key_id": report.KeyID,
+		"rows":   report.Rows,
+		"values": report.Values,
+	}).Info("凭据重新加密完成")
+	return report, nil
+}
+
+// collect 读取表中需要重新加密的值，返回按行ID分组的新密文
+func (s *CredentialService) collect(tx *gorm.DB, table string, columns []string, needed func(value string) bool) (map[uint]map[string]interface{}, error) {
+	rows, err := tx.Table(table).Select("id, " + strings.Join(columns, ", ")).Rows()
+	if err != nil {
+		return nil, fmt.Errorf("读取 %s 失败: %v", table, err)
+	}
+	defer rows.Close()
+
+	updates := make(map[uint]map[string]interface{})
+	for rows.Next() {
+		var id uint
+		values := make([]sql.NullString, len(columns))
+		dest := []interface{}{&id}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("读取 %s 失败: %v", table, err)
+		}
+
+		for i, value := range values {
+			if !value.Valid || !needed(value.String) {
+				continue
+			}
+			plain, err := secret.Decrypt(value.String)
+			if err != nil {
+				return nil, fmt.Errorf("%s (ID: %d) 的 %s %v", table, id, columns[i], err)
+			}
+			encrypted, err := secret.Encrypt(plain)
+			if err != nil {
+				return nil, fmt.Errorf("加密 %s (ID: %d) 的 %s 失败: %v", table, id, columns[i], err)
+			}
+			if updates[id] == nil {
+				updates[id] = make(map[string]interface{})
+			}
+			updates[id][columns[i]] = encrypted
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("读取 %s 失败: %v", table, err)
+	}
+	return updates, nil
+}
@@ -0,0 +1,177 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go-devops/internal/logger"
+	"go-devops/internal/models"
+)
+
+// DispatchOptions 多主机执行的调度选项
+type DispatchOptions struct {
+	Concurrency      int    // 同时执行的最大主机数，<=0 不限制
+	BatchSize        string // 滚动批次大小：主机数或百分比（如 "10%"），为空时不分批
+	StopOnFailure    bool   // 失败主机数超过阈值时中止剩余主机
+	FailureThreshold int    // 允许失败的主机数
+}
+
+// DispatchOptionsFromJob 读取作业中的调度设置
+func DispatchOptionsFromJob(job *models.Job) DispatchOptions {
+	return DispatchOptions{
+		Concurrency:      job.Concurrency,
+		BatchSize:        job.BatchSize,
+		StopOnFailure:    job.StopOnFailure,
+		FailureThreshold: job.FailureThreshold,
+	}
+}
+
+// Validate 校验调度选项
+func (o DispatchOptions) Validate() error {
+	if o.Concurrency < 0 {
+		return fmt.Errorf("并发数不能为负数")
+	}
+	if o.FailureThreshold < 0 {
+		return fmt.Errorf("失败阈值不能为负数")
+	}
+	if _, err := ParseBatchSize(o.BatchSize, 1); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ParseBatchSize 按主机总数计算每批主机数，支持 "5" 和 "10%" 两种形式，为空时返回总数
+func ParseBatchSize(value string, total int) (int, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return total, nil
+	}
+
+	if strings.HasSuffix(value, "%") {
+		percent, err := strconv.Atoi(strings.TrimSpace(strings.TrimSuffix(value, "%")))
+		if err != nil || percent <= 0 || percent > 100 {
+			return 0, fmt.Errorf("批次大小 '%s' 无效，百分比应在 1%%-100%% 之间", value)
+		}
+		// 向上取整，至少1台
+		size := (total*percent + 99) / 100
+		if size < 1 {
+			size = 1
+		}
+		return size, nil
+	}
+
+	size, err := strconv.Atoi(value)
+	if err != nil || size <= 0 {
+		return 0, fmt.Errorf("批次大小 '%s' 无效，应为正整数或百分比", value)
+	}
+	return size, nil
+}
+
+// ExecutionTarget 单台主机上待执行的任务
+type ExecutionTarget struct {
+	Execution *models.JobExecution
+	Host      models.Host
+}
+
+// DispatchExecutions 按调度选项在多台主机上执行脚本：分批滚动执行，批内限制并发，
+// 失败主机数超过阈值时跳过尚未开始的主机。立即返回，执行在后台进行
+func (s *ExecutionService) DispatchExecutions(targets []ExecutionTarget, script *models.Script, opts ExecutionOptions, dispatch DispatchOptions) {
+	go s.dispatchExecutions(targets, script, opts, dispatch)
+}
+
+// dispatchExecutions 按批次执行，等待每批结束后再开始下一批
+func (s *ExecutionService) dispatchExecutions(targets []ExecutionTarget, script *models.Script, opts ExecutionOptions, dispatch DispatchOptions) {
+	batchSize, err := ParseBatchSize(dispatch.BatchSize, len(targets))
+	if err != nil {
+		batchSize = len(targets)
+	}
+	concurrency := dispatch.Concurrency
+	if concurrency <= 0 || concurrency > batchSize {
+		concurrency = batchSize
+	}
+
+	logger.Logger.WithFields(map[string]interface{}{
+		"run_id":            opts.RunID,
+		"host_count":        len(targets),
+		"batch_size":        batchSize,
+		"concurrency":       concurrency,
+		"stop_on_failure":   dispatch.StopOnFailure,
+		"failure_threshold": dispatch.FailureThreshold,
+	}).Info("开始调度多主机执行")
+
+	var mu sync.Mutex
+	failedCount := 0
+	aborted := false
+	abortReason := fmt.Sprintf("失败主机数超过阈值 %d，已中止剩余主机", dispatch.FailureThreshold)
+
+	for start := 0; start < len(targets); start += batchSize {
+		end := start + batchSize
+		if end > len(targets) {
+			end = len(targets)
+		}
+
+		// 已中止：剩余主机标记为跳过，仍交给执行方法处理以便统一结束流程
+		if aborted {
+			s.skipExecutions(targets[start:], abortReason)
+		}
+
+		semaphore := make(chan struct{}, concurrency)
+		var wg sync.WaitGroup
+		for i := start; i < end; i++ {
+			wg.Add(1)
+			go func(target ExecutionTarget) {
+				defer wg.Done()
+
+				// 获取并发许可
+				semaphore <- struct{}{}
+				defer func() { <-semaphore }()
+
+				// 等待期间已达到失败阈值的主机不再执行
+				mu.Lock()
+				skip := aborted
+				mu.Unlock()
+				if skip {
+					s.skipExecutions([]ExecutionTarget{target}, abortReason)
+				}
+
+				s.ExecuteScriptOnHostWithOptions(target.Execution, script, &target.Host, opts)
+
+				// 只有执行失败和超时计入失败阈值，跳过和取消不计入
+				if status := target.Execution.Status; status != "failed" && status != "timeout" {
+					return
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				failedCount++
+				if dispatch.StopOnFailure && failedCount > dispatch.FailureThreshold && !aborted {
+					aborted = true
+					logger.Logger.WithFields(map[string]interface{}{
+						"run_id":            opts.RunID,
+						"failed_count":      failedCount,
+						"failure_threshold": dispatch.FailureThreshold,
+					}).Warn("失败主机数超过阈值，中止剩余主机")
+				}
+			}(targets[i])
+		}
+		wg.Wait()
+	}
+}
+
+// skipExecutions 将尚未开始的执行标记为跳过
+func (s *ExecutionService) skipExecutions(targets []ExecutionTarget, reason string) {
+	ids := make([]uint, 0, len(targets))
+	for _, target := range targets {
+		ids = append(ids, target.Execution.ID)
+	}
+	endTime := time.Now()
+	s.db.Model(&models.JobExecution{}).
+		Where("id IN ? AND status = ?", ids, "pending").
+		Updates(map[string]interface{}{
+			"status":   "skipped",
+			"error":    reason,
+			"end_time": &endTime,
+		})
+}
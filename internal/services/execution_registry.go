@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrExecutionNotCancellable 执行已结束或不存在，无法取消
+var ErrExecutionNotCancellable = errors.New("执行已结束或不存在，无法取消")
+
+// runningExecution 正在运行的执行
+type runningExecution struct {
+	jobID       *uint
+	runID       uint
+	cancel      context.CancelFunc
+	cancelledBy *uint
+}
+
+// executionRegistry 正在运行的执行登记表，按执行ID索引，用于取消执行
+type executionRegistry struct {
+	mu         sync.Mutex
+	executions map[uint]*runningExecution
+}
+
+// 全局执行登记表，所有执行服务实例共享
+var runningExecutions = &executionRegistry{executions: make(map[uint]*runningExecution)}
+
+// register 登记正在运行的执行
+func (r *executionRegistry) register(executionID uint, jobID *uint, runID uint, cancel context.CancelFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.executions[executionID] = &runningExecution{jobID: jobID, runID: runID, cancel: cancel}
+}
+
+// unregister 移除执行登记，返回取消该执行的用户（未被取消时为nil）
+func (r *executionRegistry) unregister(executionID uint) *uint {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry, ok := r.executions[executionID]
+	if !ok {
+		return nil
+	}
+	delete(r.executions, executionID)
+	return entry.cancelledBy
+}
+
+// cancelledBy 返回取消该执行的用户，未被取消时为nil
+func (r *executionRegistry) cancelledBy(executionID uint) *uint {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if entry, ok := r.executions[executionID]; ok {
+		return entry.cancelledBy
+	}
+	return nil
+}
+
+// cancel 取消指定执行，执行未在运行时返回false
+func (r *executionRegistry) cancel(executionID, userID uint) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry, ok := r.executions[executionID]
+	if !ok {
+		return false
+	}
+	r.cancelEntry(entry, userID)
+	return true
+}
+
+// cancelRun 取消作业某次运行的所有执行，返回被取消的执行ID
+func (r *executionRegistry) cancelRun(jobID, runID, userID uint) []uint {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var cancelled []uint
+	for id, entry := range r.executions {
+		if entry.jobID != nil && *entry.jobID == jobID && entry.runID == runID {
+			r.cancelEntry(entry, userID)
+			cancelled = append(cancelled, id)
+		}
+	}
+	return cancelled
+}
+
+// cancelEntry 记录取消者并触发取消，重复取消时保留第一个取消者
+func (r *executionRegistry) cancelEntry(entry *runningExecution, userID uint) {
+	if entry.cancelledBy == nil {
+		entry.cancelledBy = &userID
+	}
+	entry.cancel()
+}
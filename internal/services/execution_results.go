@@ -0,0 +1,99 @@
+package services
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// 结构化结果的提取限制
+const (
+	maxResultCount       = 200         // set-output 结果的最大数量
+	maxResultValueLength = 4096        // 单个 set-output 值的最大长度
+	maxResultJSONSize    = 1024 * 1024 // 末尾JSON对象的最大大小
+	maxResultJSONLines   = 2000        // 查找末尾JSON对象时向前扫描的最大行数
+)
+
+// 结果名称格式，与工作流步骤输出的引用格式一致
+var resultNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// set-output 行：::set-output name=<名称>::<值> 或 ::set-output <名称>=<值>
+var (
+	setOutputNamedPattern = regexp.MustCompile(`^::set-output\s+name=([^:\s]+)::(.*)$`)
+	setOutputPlainPattern = regexp.MustCompile(`^::set-output\s+([^=\s]+)=(.*)$`)
+)
+
+// ParseExecutionResults 从标准输出中提取结构化结果：
+// set-output 行按出现顺序记录，同名时后出现的覆盖之前的；
+// 输出以JSON对象结尾时，其顶层字段合并到结果中并优先于 set-output
+func ParseExecutionResults(output string) map[string]interface{} {
+	results := make(map[string]interface{})
+	lines := strings.Split(output, "\n")
+
+	for _, line := range lines {
+		line = strings.TrimRight(line, "\r")
+		if !strings.HasPrefix(line, "::set-output") {
+			continue
+		}
+		match := setOutputNamedPattern.FindStringSubmatch(line)
+		if match == nil {
+			match = setOutputPlainPattern.FindStringSubmatch(line)
+		}
+		if match == nil || !resultNamePattern.MatchString(match[1]) {
+			continue
+		}
+		if _, exists := results[match[1]]; !exists && len(results) >= maxResultCount {
+			continue
+		}
+		value := match[2]
+		if len(value) > maxResultValueLength {
+			value = value[:maxResultValueLength]
+		}
+		results[match[1]] = value
+	}
+
+	for key, value := range trailingJSONObject(lines) {
+		results[key] = value
+	}
+	return results
+}
+
+// trailingJSONObject 查找输出末尾的JSON对象：从最后一行向前寻找以 { 开头的行，
+// 该行到输出结尾的内容能解析为JSON对象时返回
+func trailingJSONObject(lines []string) map[string]interface{} {
+	end := len(lines)
+	for end > 0 && strings.TrimSpace(lines[end-1]) == "" {
+		end--
+	}
+	if end == 0 || !strings.HasSuffix(strings.TrimSpace(lines[end-1]), "}") {
+		return nil
+	}
+
+	size := 0
+	for start := end - 1; start >= 0 && end-start <= maxResultJSONLines; start-- {
+		size += len(lines[start]) + 1
+		if size > maxResultJSONSize {
+			return nil
+		}
+		if !strings.HasPrefix(strings.TrimSpace(lines[start]), "{") {
+			continue
+		}
+		var object map[string]interface{}
+		if err := json.Unmarshal([]byte(strings.Join(lines[start:end], "\n")), &object); err == nil {
+			return object
+		}
+	}
+	return nil
+}
+
+// resultString 将结果值转换为字符串，非字符串值使用JSON表示
+func resultString(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
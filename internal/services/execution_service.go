@@ -1,12 +1,16 @@
 package services
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"go-devops/internal/executor"
 	"go-devops/internal/logger"
 	"go-devops/internal/models"
+	"go-devops/internal/ssh"
 
 	"gorm.io/gorm"
 )
@@ -25,57 +29,214 @@ func NewExecutionService(db *gorm.DB) *ExecutionService {
 	}
 }
 
+// 未配置超时时间时的默认执行超时
+const defaultExecutionTimeout = 300 * time.Second
+
+// ExecutionOptions 单台主机上的脚本执行选项
+type ExecutionOptions struct {
+	InputFiles     []models.File                 // 输入文件
+	SaveOutput     bool                          // 是否保存输出为文件
+	SaveError      bool                          // 是否保存错误日志为文件
+	OutputCategory string                        // 输出文件分类
+	Timeout        int                           // 超时时间（秒），<=0 时使用默认值
+	Parameters     []models.ScriptParameterValue // 已校验的脚本参数
+	RunID          uint                          // 所属作业运行批次ID，用于整批取消
+	Retry          RetryPolicy                   // 失败重试策略，每次尝试单独计算超时
+	Become         BecomeSettings                // 作业级提权设置，为空时沿用主机设置
+	Artifacts      ArtifactPolicy                // 执行结束后收集的产物
+}
+
+// timeoutDuration 返回执行超时时长
+func (o ExecutionOptions) timeoutDuration() time.Duration {
+	if o.Timeout <= 0 {
+		return defaultExecutionTimeout
+	}
+	return time.Duration(o.Timeout) * time.Second
+}
+
 // ExecuteScriptOnHost 在指定主机上执行脚本的统一方法
 func (s *ExecutionService) ExecuteScriptOnHost(execution *models.JobExecution, script *models.Script, host *models.Host) {
-	s.ExecuteScriptOnHostWithOptions(execution, script, host, nil, false, false, "")
+	s.ExecuteScriptOnHostWithOptions(execution, script, host, ExecutionOptions{})
 }
 
-// ExecuteScriptOnHostWithOptions 在指定主机上执行脚本（支持文件参数和结果保存）
-func (s *ExecutionService) ExecuteScriptOnHostWithOptions(execution *models.JobExecution, script *models.Script, host *models.Host, inputFiles []models.File, saveOutput, saveError bool, outputCategory string) {
+// ExecuteScriptOnHostWithOptions 在指定主机上执行脚本（支持文件参数、结果保存和超时控制）
+func (s *ExecutionService) ExecuteScriptOnHostWithOptions(execution *models.JobExecution, script *models.Script, host *models.Host, opts ExecutionOptions) {
+	timeout := opts.timeoutDuration()
 	logger.Logger.WithFields(map[string]interface{}{
 		"execution_id": execution.ID,
 		"host_id":      host.ID,
 		"host_name":    host.Name,
 		"script_type":  script.Type,
 		"script_name":  script.Name,
+		"timeout":      timeout.String(),
 	}).Info("开始执行脚本")
 
-	// 更新执行状态为运行中
-	execution.Status = "running"
+	// 属于运行批次的执行，无论以何种方式结束都要报告，最后执行的defer
+	if opts.RunID != 0 {
+		defer s.reportRunExecutionDone(opts.RunID)
+	}
+
+	// 登记为运行中的执行，以便随时取消（包括重试等待期间）；取消或超时都会终止远程进程组
+	runCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	runningExecutions.register(execution.ID, execution.JobID, opts.RunID, cancel)
+	defer runningExecutions.unregister(execution.ID)
+
+	// 更新执行状态为运行中（等待期间已被取消的执行不再启动）
 	execution.StartTime = time.Now()
-	s.db.Save(execution)
+	result := s.db.Model(&models.JobExecution{}).
+		Where("id = ? AND status = ?", execution.ID, "pending").
+		Updates(map[string]interface{}{"status": "running", "start_time": execution.StartTime})
+	if result.Error == nil && result.RowsAffected == 0 {
+		// 同步数据库中的最终状态（已取消或已跳过）
+		s.db.First(execution, execution.ID)
+		logger.Logger.WithFields(map[string]interface{}{
+			"execution_id": execution.ID,
+			"status":       execution.Status,
+		}).Warn("执行已取消或已结束，跳过执行")
+		return
+	}
+	execution.Status = "running"
 
 	// 检查SSH连接
 	if host.AuthType == "" {
-		s.handleExecutionError(execution, "主机认证信息不完整")
+		s.handleExecutionFailure(execution, ssh.FailureConnection, "主机认证信息不完整")
+		s.db.Save(execution)
+		return
+	}
+
+	// 合并作业和主机的提权设置
+	become, err := resolveBecome(opts.Become, host)
+	if err != nil {
+		s.handleExecutionFailure(execution, ssh.FailureBecome, err.Error())
+		s.db.Save(execution)
 		return
 	}
 
-	// 执行脚本（传递输入文件）
-	output, errorOutput, err := s.executor.ExecuteScriptWithFiles(host, script, inputFiles)
+	// 实时输出：广播给订阅者，并定期持久化以便客户端断线后按偏移量续读
+	stream := outputHub.open(execution.ID)
+	defer func() {
+		outputHub.close(execution.ID, execution.Status)
+	}()
+	stopPersist := s.persistOutputPeriodically(execution.ID, stream)
+
+	// 不属于运行批次的执行单独计算产物配额
+	if opts.Artifacts.Enabled() && opts.RunID == 0 {
+		defer artifactQuotas.release(artifactQuotaKey(0, execution.ID))
+	}
+
+	// 执行脚本（传递输入文件和参数），失败时按重试策略重新执行
+	var output, errorOutput string
+	var artifacts []ssh.Artifact
+	var artifactProblems []string
+	for attempt := 1; ; attempt++ {
+		attemptStart := time.Now()
+		workdirPrefix, keepWorkdir := executionWorkdir(execution.ID, attempt)
+		workdir := ""
+		scriptOpts := ssh.ScriptOptions{
+			InputFiles:  opts.InputFiles,
+			Parameters:  opts.Parameters,
+			OnOutput:    stream.write,
+			Become:      become,
+			Workdir:     workdirPrefix,
+			OnWorkdir:   func(dir string) { workdir = dir },
+			KeepWorkdir: keepWorkdir,
+		}
+		if opts.Artifacts.Enabled() {
+			scriptOpts.Artifacts = newArtifactRequest(opts.Artifacts, opts.RunID, execution.ID, func(collected []ssh.Artifact, problems []string) {
+				artifacts, artifactProblems = collected, problems
+			})
+		}
+		ctx, cancelAttempt := context.WithTimeout(runCtx, timeout)
+		output, errorOutput, err = s.executor.ExecuteScriptWithFiles(ctx, host, script, scriptOpts)
+		cancelAttempt()
+		execution.AttemptCount = attempt
+		execution.Workdir = workdir
+		if opts.Retry.Enabled() {
+			s.recordAttempt(execution.ID, attempt, attemptStart, output, errorOutput, err, timeout)
+		}
+
+		failureType := ssh.FailureTypeOf(err)
+		if err == nil || attempt >= opts.Retry.MaxAttempts || !opts.Retry.ShouldRetry(failureType) {
+			break
+		}
+		// 只保留最后一次尝试的产物
+		discardArtifacts(opts.RunID, execution.ID, artifacts)
+		artifacts, artifactProblems = nil, nil
+
+		delay := opts.Retry.Delay(attempt)
+		logger.Logger.WithFields(map[string]interface{}{
+			"execution_id": execution.ID,
+			"attempt":      attempt,
+			"max_attempts": opts.Retry.MaxAttempts,
+			"failure_type": failureType,
+			"delay":        delay.String(),
+		}).Warn("脚本执行失败，准备重试")
+		stream.write(StreamStderr, []byte(fmt.Sprintf("\n[第 %d 次执行失败（%s），%v 后进行第 %d 次尝试]\n", attempt, failureType, delay, attempt+1)))
+
+		// 等待期间被取消时按取消处理
+		if !sleepContext(runCtx, delay) {
+			err = runCtx.Err()
+			break
+		}
+	}
+	if len(artifactProblems) > 0 {
+		notice := "\n[产物收集] " + strings.Join(artifactProblems, "\n[产物收集] ") + "\n"
+		stream.write(StreamStderr, []byte(notice))
+	}
+	stopPersist()
 
 	// 执行时长会在前端计算显示
-	// 处理执行结果
-	if err != nil {
-		s.handleExecutionError(execution, fmt.Sprintf("执行失败: %v", err))
-		if errorOutput != "" {
-			execution.Error = errorOutput
+	// 处理执行结果：标准输出和标准错误分别保存，超时或失败时同样保留已捕获的部分输出。
+	// 保存与实时输出相同的内容（包括重试时各次尝试的输出和提示），客户端按偏移量续读时不会错位；
+	// 各次尝试的输出另见 JobExecutionAttempt
+	lastOutput := output
+	output, errorOutput = stream.contents()
+	execution.Output = output
+	execution.Error = errorOutput
+	// 从最后一次尝试的标准输出提取结构化结果（失败时同样提取，便于排查）
+	if results := ParseExecutionResults(lastOutput); len(results) > 0 {
+		if data, err := json.Marshal(results); err == nil {
+			execution.Results = string(data)
 		}
-	} else {
+	}
+	execution.ExitCode = ssh.ExitCodeOf(err)
+	if err == nil {
 		execution.Status = "completed"
-		execution.Output = output
-		if errorOutput != "" {
-			execution.Error = errorOutput
+		zero := 0
+		execution.ExitCode = &zero
+	} else if failureType := ssh.FailureTypeOf(err); failureType == ssh.FailureTimeout {
+		// 超时：远程进程已被终止
+		s.handleExecutionTimeout(execution, timeout)
+	} else if failureType == ssh.FailureCancelled {
+		s.handleExecutionCancelled(execution, runningExecutions.cancelledBy(execution.ID))
+	} else if failureType == ssh.FailureBecome {
+		// 标准错误中通常只有密码提示，追加失败原因
+		if strings.TrimSpace(execution.Error) != "" {
+			execution.Error = strings.TrimRight(execution.Error, "\n") + "\n" + err.Error()
 		}
+		s.handleExecutionFailure(execution, failureType, err.Error())
+	} else {
+		s.handleExecutionFailure(execution, failureType, fmt.Sprintf("执行失败: %v", err))
 	}
 
 	// 结束时间
 	endTime := time.Now()
 	execution.EndTime = &endTime
 
+	// 登记产物文件
+	if len(artifacts) > 0 {
+		if err := s.executor.SaveArtifacts(execution, host, artifacts); err != nil {
+			logger.Logger.WithFields(map[string]interface{}{
+				"execution_id": execution.ID,
+				"error":        err.Error(),
+			}).Error("登记产物文件失败")
+		}
+	}
+
 	// 保存执行结果为文件（如果需要）
-	if (saveOutput && output != "") || (saveError && errorOutput != "") {
-		category := outputCategory
+	if (opts.SaveOutput && output != "") || (opts.SaveError && errorOutput != "") {
+		category := opts.OutputCategory
 		if category == "" {
 			category = "script_output"
 		}
@@ -102,10 +263,46 @@ func (s *ExecutionService) ExecuteScriptOnHostWithOptions(execution *models.JobE
 	}).Info("脚本执行完成")
 }
 
-// handleExecutionError 处理执行错误
-func (s *ExecutionService) handleExecutionError(execution *models.JobExecution, errorMsg string) {
+// 执行过程中输出持久化的间隔
+const outputPersistInterval = 2 * time.Second
+
+// persistOutputPeriodically 定期将实时输出写入执行记录，返回的函数用于停止并等待退出
+func (s *ExecutionService) persistOutputPeriodically(executionID uint, stream *executionStream) func() {
+	stop := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(outputPersistInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if output, errorOutput, changed := stream.snapshot(); changed {
+					s.db.Model(&models.JobExecution{}).Where("id = ?", executionID).Updates(map[string]interface{}{
+						"output": output,
+						"error":  errorOutput,
+					})
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
+	}
+}
+
+// handleExecutionFailure 处理执行失败，标准错误为空时将失败原因写入Error
+func (s *ExecutionService) handleExecutionFailure(execution *models.JobExecution, failureType, errorMsg string) {
 	execution.Status = "failed"
-	execution.Error = errorMsg
+	execution.FailureType = failureType
+	if strings.TrimSpace(execution.Error) == "" {
+		execution.Error = errorMsg
+	}
 	endTime := time.Now()
 	execution.EndTime = &endTime
 	
@@ -113,10 +310,95 @@ func (s *ExecutionService) handleExecutionError(execution *models.JobExecution,
 
 	logger.Logger.WithFields(map[string]interface{}{
 		"execution_id": execution.ID,
+		"failure_type": failureType,
+		"exit_code":    execution.ExitCode,
 		"error":        errorMsg,
 	}).Error("脚本执行失败")
 }
 
+// handleExecutionTimeout 处理执行超时
+func (s *ExecutionService) handleExecutionTimeout(execution *models.JobExecution, timeout time.Duration) {
+	execution.Status = "timeout"
+	execution.FailureType = ssh.FailureTimeout
+	message := fmt.Sprintf("执行超时（超过 %v），已终止远程进程", timeout)
+	if strings.TrimSpace(execution.Error) == "" {
+		execution.Error = message
+	}
+	endTime := time.Now()
+	execution.EndTime = &endTime
+
+	logger.Logger.WithFields(map[string]interface{}{
+		"execution_id": execution.ID,
+		"timeout":      timeout.String(),
+	}).Error("脚本执行超时")
+}
+
+// handleExecutionCancelled 处理执行被取消
+func (s *ExecutionService) handleExecutionCancelled(execution *models.JobExecution, cancelledBy *uint) {
+	execution.Status = "cancelled"
+	execution.FailureType = ssh.FailureCancelled
+	execution.CancelledBy = cancelledBy
+	if strings.TrimSpace(execution.Error) == "" {
+		execution.Error = "执行已被取消，已终止远程进程"
+	}
+	endTime := time.Now()
+	execution.EndTime = &endTime
+
+	logger.Logger.WithFields(map[string]interface{}{
+		"execution_id": execution.ID,
+		"cancelled_by": cancelledBy,
+	}).Warn("脚本执行已取消")
+}
+
+// CancelExecution 取消执行：运行中的执行会关闭SSH会话并终止远程进程组，尚未开始的执行直接标记为已取消
+func (s *ExecutionService) CancelExecution(executionID, userID uint) error {
+	if runningExecutions.cancel(executionID, userID) {
+		logger.Logger.WithFields(map[string]interface{}{
+			"execution_id": executionID,
+			"cancelled_by": userID,
+		}).Info("已发送取消执行信号")
+		return nil
+	}
+
+	endTime := time.Now()
+	result := s.db.Model(&models.JobExecution{}).
+		Where("id = ? AND status = ?", executionID, "pending").
+		Updates(map[string]interface{}{
+			"status":       "cancelled",
+			"failure_type": ssh.FailureCancelled,
+			"cancelled_by": userID,
+			"end_time":     &endTime,
+		})
+	if result.Error != nil {
+		return fmt.Errorf("取消执行失败: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrExecutionNotCancellable
+	}
+	return nil
+}
+
+// CancelJobRun 取消作业某次运行中所有尚未结束的执行，返回被取消的执行ID
+func (s *ExecutionService) CancelJobRun(jobID, runID, userID uint) ([]uint, error) {
+	// 先取消尚未开始的执行，再终止运行中的执行，避免遗漏取消过程中刚启动的执行
+	cancelled, err := s.cancelPendingRunExecutions(runID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("取消作业运行失败: %v", err)
+	}
+	cancelled = append(cancelled, runningExecutions.cancelRun(jobID, runID, userID)...)
+	if len(cancelled) == 0 {
+		return nil, ErrExecutionNotCancellable
+	}
+
+	logger.Logger.WithFields(map[string]interface{}{
+		"job_id":       jobID,
+		"run_id":       runID,
+		"cancelled_by": userID,
+		"executions":   cancelled,
+	}).Info("已发送取消作业运行信号")
+	return cancelled, nil
+}
+
 // SaveExecutionResultAsFile 保存执行结果为文件
 func (s *ExecutionService) SaveExecutionResultAsFile(execution *models.JobExecution, output, errorOutput string, category string, userID uint) error {
 	return s.executor.SaveExecutionResultAsFile(execution, output, errorOutput, category, userID)
@@ -143,44 +425,19 @@ func (s *ExecutionService) CreateJobExecution(jobID *uint, hostID uint, scriptCo
 	return execution, nil
 }
 
-// UpdateJobStatus 更新作业状态
-func (s *ExecutionService) UpdateJobStatus(jobID uint) error {
-	var executions []models.JobExecution
-	if err := s.db.Where("job_id = ?", jobID).Find(&executions).Error; err != nil {
-		return err
-	}
-
-	if len(executions) == 0 {
-		return nil
-	}
-
-	// 统计执行状态
-	var completedCount, failedCount, runningCount int
-	for _, exec := range executions {
-		switch exec.Status {
-		case "completed":
-			completedCount++
-		case "failed":
-			failedCount++
-		case "running":
-			runningCount++
-		}
-	}
+// IsExecutionFinished 判断执行状态是否为最终状态
+func IsExecutionFinished(status string) bool {
+	return status != "pending" && status != "running"
+}
 
-	// 确定作业整体状态
-	var jobStatus string
-	if runningCount > 0 {
-		jobStatus = "running"
-	} else if failedCount > 0 {
-		if completedCount > 0 {
-			jobStatus = "partial_failed"
-		} else {
-			jobStatus = "failed"
+// UpdateJobStatus 按作业最近一次运行的结果更新作业状态
+func (s *ExecutionService) UpdateJobStatus(jobID uint) error {
+	var run models.JobRun
+	if err := s.db.Where("job_id = ?", jobID).Order("id DESC").First(&run).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil
 		}
-	} else {
-		jobStatus = "completed"
+		return err
 	}
-
-	// 更新作业状态
-	return s.db.Model(&models.Job{}).Where("id = ?", jobID).Update("status", jobStatus).Error
+	return s.UpdateJobRunStatus(run.ID)
 }
@@ -0,0 +1,207 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"go-devops/internal/logger"
+	"go-devops/internal/models"
+	"go-devops/internal/ssh"
+
+	"gorm.io/gorm"
+)
+
+// FileDistributionService 文件分发服务
+type FileDistributionService struct {
+	db *gorm.DB
+}
+
+// NewFileDistributionService 创建文件分发服务实例
+func NewFileDistributionService(db *gorm.DB) *FileDistributionService {
+	return &FileDistributionService{db: db}
+}
+
+// CreateDistribution 创建分发记录及各主机的分发详情
+func (s *FileDistributionService) CreateDistribution(file *models.File, hostIDs []uint, targetPath string, userID uint) (*models.FileDistribution, error) {
+	// 将主机ID列表转换为JSON字符串
+	hostIDsJSON, _ := json.Marshal(hostIDs)
+
+	distribution := &models.FileDistribution{
+		FileID:     file.ID,
+		HostIDs:    string(hostIDsJSON),
+		TargetPath: targetPath,
+		Status:     "pending",
+		CreatedBy:  userID,
+	}
+	if err := s.db.Create(distribution).Error; err != nil {
+		return nil, err
+	}
+
+	// 创建分发详情记录
+	for _, hostID := range hostIDs {
+		detail := models.FileDistributionDetail{
+			DistributionID: distribution.ID,
+			HostID:         hostID,
+			Status:         "pending",
+		}
+		s.db.Create(&detail)
+	}
+	return distribution, nil
+}
+
+// ExecuteDistribution 执行文件分发（支持并发和重试），执行完成后返回最终状态
+func (s *FileDistributionService) ExecuteDistribution(distribution *models.FileDistribution, file *models.File, hosts []models.Host) string {
+	logger.Infof("开始执行文件分发任务: %d，目标主机数: %d", distribution.ID, len(hosts))
+
+	// 更新分发状态为运行中
+	startTime := time.Now()
+	s.db.Model(distribution).Updates(map[string]interface{}{
+		"status":     "running",
+		"start_time": &startTime,
+	})
+
+	// 并发控制：最多同时分发到3台主机
+	const maxConcurrency = 3
+	const maxRetries = 3
+
+	semaphore := make(chan struct{}, maxConcurrency)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+
+	successCount := 0
+	totalCount := len(hosts)
+	completedCount := 0
+
+	for _, host := range hosts {
+		wg.Add(1)
+		go func(currentHost models.Host) {
+			defer wg.Done()
+
+			// 获取并发许可
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			// 执行单个主机的分发任务
+			success := s.distributeToSingleHost(distribution, file, &currentHost, maxRetries)
+
+			// 更新计数器（需要加锁）
+			mu.Lock()
+			completedCount++
+			if success {
+				successCount++
+			}
+
+			// 更新总体进度
+			progress := (completedCount * 100) / totalCount
+			s.db.Model(distribution).UpdateColumn("progress", progress)
+
+			logger.Infof("分发进度: %d/%d (%.1f%%), 成功: %d",
+				completedCount, totalCount, float64(progress), successCount)
+			mu.Unlock()
+		}(host)
+	}
+
+	// 等待所有分发任务完成
+	wg.Wait()
+
+	// 更新最终状态
+	endTime := time.Now()
+	finalStatus := "completed"
+	if successCount == 0 {
+		finalStatus = "failed"
+	} else if successCount < totalCount {
+		finalStatus = "partial"
+	}
+
+	s.db.Model(distribution).Updates(map[string]interface{}{
+		"status":   finalStatus,
+		"progress": 100,
+		"end_time": &endTime,
+	})
+
+	logger.Infof("文件分发任务完成: %d, 成功: %d/%d, 用时: %v",
+		distribution.ID, successCount, totalCount, endTime.Sub(startTime))
+	return finalStatus
+}
+
+// 分发文件到单个主机（支持重试）
+func (s *FileDistributionService) distributeToSingleHost(distribution *models.FileDistribution, file *models.File, host *models.Host, maxRetries int) bool {
+	// 获取分发详情记录
+	var detail models.FileDistributionDetail
+	s.db.Where("distribution_id = ? AND host_id = ?", distribution.ID, host.ID).First(&detail)
+
+	detailStartTime := time.Now()
+	s.db.Model(&detail).Updates(map[string]interface{}{
+		"status":     "running",
+		"start_time": &detailStartTime,
+	})
+
+	// 重试逻辑
+	var lastErr error
+	for attempt := 1; attempt <= maxRetries; attempt++ {
+		logger.Infof("尝试分发文件到主机 %s (第%d次，共%d次)", host.Name, attempt, maxRetries)
+
+		err := s.transferFileToHost(file, host, distribution.TargetPath)
+		if err == nil {
+			// 分发成功
+			detailEndTime := time.Now()
+			s.db.Model(&detail).Updates(map[string]interface{}{
+				"status":   "completed",
+				"output":   fmt.Sprintf("文件传输成功 (尝试%d次)", attempt),
+				"end_time": &detailEndTime,
+			})
+
+			logger.Infof("文件分发到主机 %s 成功 (尝试%d次)", host.Name, attempt)
+			return true
+		}
+
+		lastErr = err
+		logger.Warnf("文件分发到主机 %s 失败 (第%d次): %v", host.Name, attempt, err)
+
+		// 如果不是最后一次尝试，等待一段时间再重试
+		if attempt < maxRetries {
+			retryDelay := time.Duration(attempt) * time.Second
+			logger.Infof("等待 %v 后重试...", retryDelay)
+			time.Sleep(retryDelay)
+		}
+	}
+
+	// 所有重试都失败了
+	detailEndTime := time.Now()
+	s.db.Model(&detail).Updates(map[string]interface{}{
+		"status":   "failed",
+		"error":    fmt.Sprintf("重试%d次后仍然失败: %v", maxRetries, lastErr),
+		"end_time": &detailEndTime,
+	})
+
+	logger.Errorf("文件分发到主机 %s 最终失败，已重试%d次: %v", host.Name, maxRetries, lastErr)
+	return false
+}
+
+// 传输文件到主机
+func (s *FileDistributionService) transferFileToHost(file *models.File, host *models.Host, targetPath string) error {
+	// 检查主机SSH配置
+	if host.Username == "" {
+		return fmt.Errorf("主机未配置SSH用户名")
+	}
+
+	if host.AuthType == "password" && host.Password == "" {
+		return fmt.Errorf("主机未配置SSH密码")
+	}
+
+	if host.AuthType == "key" && host.PrivateKey == "" {
+		return fmt.Errorf("主机未配置SSH私钥")
+	}
+
+	// 创建SSH客户端
+	sshClient, err := ssh.NewSSHClient(host)
+	if err != nil {
+		return fmt.Errorf("创建SSH连接失败: %v", err)
+	}
+	defer sshClient.Close()
+
+	// 上传文件到目标主机
+	return sshClient.UploadFile(file.Path, targetPath)
+}
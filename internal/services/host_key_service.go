@@ -0,0 +1,340 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go-devops/internal/logger"
+	"go-devops/internal/models"
+	"go-devops/internal/ssh"
+
+	"gorm.io/gorm"
+)
+
+// 主机密钥状态
+const (
+	HostKeyUnknown  = "unknown"  // 未记录密钥
+	HostKeyTrusted  = "trusted"  // 已信任
+	HostKeyPending  = "pending"  // 严格模式下首次连接记录的密钥，等待确认
+	HostKeyMismatch = "mismatch" // 主机提供的密钥与已信任密钥不一致，等待确认
+)
+
+var (
+	// ErrHostNotFound 主机不存在
+	ErrHostNotFound = errors.New("主机不存在")
+	// ErrNoPendingHostKey 没有待确认的主机密钥
+	ErrNoPendingHostKey = errors.New("主机没有待确认的密钥")
+	// ErrPendingHostKeyChanged 待确认密钥与请求中的指纹不一致
+	ErrPendingHostKeyChanged = errors.New("待确认密钥的指纹与请求不一致，请刷新后重新确认")
+)
+
+// HostKeyInfo 主机密钥信息
+type HostKeyInfo struct {
+	HostID             uint       `json:"host_id"`
+	HostName           string     `json:"host_name"`
+	IP                 string     `json:"ip"`
+	Port               int        `json:"port"`
+	Status             string     `json:"status"`
+	HostKey            string     `json:"host_key,omitempty"`
+	Fingerprint        string     `json:"fingerprint,omitempty"`
+	TrustedAt          *time.Time `json:"trusted_at,omitempty"`
+	PendingHostKey     string     `json:"pending_host_key,omitempty"`
+	PendingFingerprint string     `json:"pending_fingerprint,omitempty"`
+}
+
+// HostKeyImportItem known_hosts 导入时匹配到的主机
+type HostKeyImportItem struct {
+	HostID              uint   `json:"host_id"`
+	HostName            string `json:"host_name"`
+	IP                  string `json:"ip"`
+	Line                int    `json:"line"`
+	Fingerprint         string `json:"fingerprint"`
+	PreviousFingerprint string `json:"previous_fingerprint,omitempty"`
+}
+
+// KnownHostsImportResult known_hosts 导入结果
+type KnownHostsImportResult struct {
+	Imported  []HostKeyImportItem `json:"imported"`  // 新记录或更新了密钥的主机
+	Unchanged int                 `json:"unchanged"` // 已信任相同密钥的主机数
+	Conflicts []HostKeyImportItem `json:"conflicts"` // 已信任不同密钥且未指定覆盖的主机
+	Unmatched int                 `json:"unmatched"` // 未匹配任何主机的记录数
+	Problems  []string            `json:"problems"`  // 无法解析或不支持的行
+}
+
+// HostKeyService 主机密钥管理服务，同时作为SSH连接的主机和跳板机密钥存储
+type HostKeyService struct {
+	db *gorm.DB
+}
+
+// NewHostKeyService 创建主机密钥服务
+func NewHostKeyService(db *gorm.DB) *HostKeyService {
+	return &HostKeyService{db: db}
+}
+
+// TrustFirstKey 尚未记录密钥时记录并信任，并发的首次连接以先写入者为准
+func (s *HostKeyService) TrustFirstKey(target string, id uint, key, fingerprint string) (string, error) {
+	now := time.Now()
+	err := s.db.Model(hostKeyModel(target)).
+		Where("id = ? AND (host_key = '' OR host_key IS NULL)", id).
+		Updates(trustedKeyUpdates(key, fingerprint, now)).Error
+	if err != nil {
+		return "", err
+	}
+	var current struct{ HostKey string }
+	if err := s.db.Model(hostKeyModel(target)).Select("host_key").Where("id = ?", id).Take(&current).Error; err != nil {
+		return "", err
+	}
+	return current.HostKey, nil
+}
+
+// RecordPendingKey 记录未受信任的密钥，等待管理员确认
+func (s *HostKeyService) RecordPendingKey(target string, id uint, key, fingerprint string) error {
+	return s.db.Model(hostKeyModel(target)).Where("id = ?", id).Updates(map[string]interface{}{
+		"pending_host_key":             key,
+		"pending_host_key_fingerprint": fingerprint,
+	}).Error
+}
+
+// hostKeyModel 密钥归属对应的模型，主机和跳板机的密钥字段同名
+func hostKeyModel(target string) interface{} {
+	if target == ssh.HostKeyTargetBastion {
+		return &models.Bastion{}
+	}
+	return &models.Host{}
+}
+
+// trustedKeyUpdates 信任密钥并清除待确认密钥的字段更新
+func trustedKeyUpdates(key, fingerprint string, now time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"host_key":                     key,
+		"host_key_fingerprint":         fingerprint,
+		"host_key_trusted_at":          &now,
+		"pending_host_key":             "",
+		"pending_host_key_fingerprint": "",
+	}
+}
+
+// ResetHostKeyFields 清除已信任和待确认密钥的字段更新，主机或跳板机地址变更时使用
+func ResetHostKeyFields() map[string]interface{} {
+	return map[string]interface{}{
+		"host_key":                     "",
+		"host_key_fingerprint":         "",
+		"host_key_trusted_at":          nil,
+		"pending_host_key":             "",
+		"pending_host_key_fingerprint": "",
+	}
+}
+
+// hostKeyInfo 转换为主机密钥信息
+func hostKeyInfo(host *models.Host) HostKeyInfo {
+	info := HostKeyInfo{
+		HostID:             host.ID,
+		HostName:           host.Name,
+		IP:                 host.IP,
+		Port:               host.Port,
+		HostKey:            host.HostKey,
+		Fingerprint:        host.HostKeyFingerprint,
+		TrustedAt:          host.HostKeyTrustedAt,
+		PendingHostKey:     host.PendingHostKey,
+		PendingFingerprint: host.PendingHostKeyFingerprint,
+	}
+	switch {
+	case host.HostKey != "" && host.PendingHostKey != "":
+		info.Status = HostKeyMismatch
+	case host.HostKey != "":
+		info.Status = HostKeyTrusted
+	case host.PendingHostKey != "":
+		info.Status = HostKeyPending
+	default:
+		info.Status = HostKeyUnknown
+	}
+	return info
+}
+
+// ListHostKeys 获取所有主机的密钥状态，status不为空时按状态过滤
+func (s *HostKeyService) ListHostKeys(status string) ([]HostKeyInfo, error) {
+	var hosts []models.Host
+	if err := s.db.Order("id ASC").Find(&hosts).Error; err != nil {
+		return nil, fmt.Errorf("获取主机列表失败: %v", err)
+	}
+	infos := make([]HostKeyInfo, 0, len(hosts))
+	for i := range hosts {
+		info := hostKeyInfo(&hosts[i])
+		if status == "" || info.Status == status {
+			infos = append(infos, info)
+		}
+	}
+	return infos, nil
+}
+
+// GetHostKey 获取主机的密钥信息
+func (s *HostKeyService) GetHostKey(hostID uint) (*HostKeyInfo, error) {
+	host, err := s.loadHost(hostID)
+	if err != nil {
+		return nil, err
+	}
+	info := hostKeyInfo(host)
+	return &info, nil
+}
+
+// ApproveHostKey 信任主机的待确认密钥，fingerprint不为空时须与待确认密钥一致，防止确认期间密钥被替换
+func (s *HostKeyService) ApproveHostKey(hostID uint, fingerprint string) (*HostKeyInfo, error) {
+	host, err := s.loadHost(hostID)
+	if err != nil {
+		return nil, err
+	}
+	if host.PendingHostKey == "" {
+		return nil, ErrNoPendingHostKey
+	}
+	if fingerprint != "" && fingerprint != host.PendingHostKeyFingerprint {
+		return nil, ErrPendingHostKeyChanged
+	}
+
+	now := time.Now()
+	previous := host.HostKeyFingerprint
+	if err := s.setTrustedKey(host, host.PendingHostKey, host.PendingHostKeyFingerprint, now); err != nil {
+		return nil, err
+	}
+	logger.Logger.WithFields(map[string]interface{}{
+		"host_id":              host.ID,
+		"fingerprint":          host.HostKeyFingerprint,
+		"previous_fingerprint": previous,
+	}).Info("主机密钥已确认")
+	info := hostKeyInfo(host)
+	return &info, nil
+}
+
+// ResetHostKey 清除主机已记录的密钥，下次连接时按策略重新记录
+func (s *HostKeyService) ResetHostKey(hostID uint) (*HostKeyInfo, error) {
+	host, err := s.loadHost(hostID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.Model(host).Updates(ResetHostKeyFields()).Error; err != nil {
+		return nil, fmt.Errorf("重置主机密钥失败: %v", err)
+	}
+	host.HostKey, host.HostKeyFingerprint, host.HostKeyTrustedAt = "", "", nil
+	host.PendingHostKey, host.PendingHostKeyFingerprint = "", ""
+	ssh.CloseHostConnections(host.ID)
+	logger.Infof("主机 %s (%s) 的密钥已重置", host.Name, host.IP)
+	info := hostKeyInfo(host)
+	return &info, nil
+}
+
+// ImportKnownHosts 从 OpenSSH known_hosts 内容导入主机密钥，按IP和端口匹配主机，支持哈希主机名。
+// 同一主机有多个密钥时优先选择与待确认密钥相同的，其次按密钥类型偏好选择。
+// 主机已信任不同密钥时只有overwrite为true才覆盖
+func (s *HostKeyService) ImportKnownHosts(content string, overwrite bool) (*KnownHostsImportResult, error) {
+	entries, problems := ssh.ParseKnownHosts(content)
+	result := &KnownHostsImportResult{
+		Imported:  []HostKeyImportItem{},
+		Conflicts: []HostKeyImportItem{},
+		Problems:  problems,
+	}
+	if result.Problems == nil {
+		result.Problems = []string{}
+	}
+
+	var hosts []models.Host
+	if err := s.db.Order("id ASC").Find(&hosts).Error; err != nil {
+		return nil, fmt.Errorf("获取主机列表失败: %v", err)
+	}
+
+	// 每台主机选出一条记录
+	chosen := make(map[int]ssh.KnownHostsEntry)
+	for _, entry := range entries {
+		matched := false
+		for _, pattern := range entry.Hosts {
+			if !ssh.KnownHostsPatternSupported(pattern) {
+				result.Problems = append(result.Problems, fmt.Sprintf("第%d行的主机模式 %s 包含通配符或否定，已忽略", entry.Line, pattern))
+				continue
+			}
+			for i := range hosts {
+				if !ssh.KnownHostsPatternMatches(pattern, hosts[i].IP, hosts[i].Port) {
+					continue
+				}
+				matched = true
+				if current, ok := chosen[i]; !ok || preferImportedKey(&hosts[i], entry, current) {
+					chosen[i] = entry
+				}
+			}
+		}
+		if !matched {
+			result.Unmatched++
+		}
+	}
+
+	now := time.Now()
+	for i := range hosts {
+		entry, ok := chosen[i]
+		if !ok {
+			continue
+		}
+		host := &hosts[i]
+		item := HostKeyImportItem{
+			HostID:              host.ID,
+			HostName:            host.Name,
+			IP:                  host.IP,
+			Line:                entry.Line,
+			Fingerprint:         entry.Fingerprint,
+			PreviousFingerprint: host.HostKeyFingerprint,
+		}
+		switch {
+		case host.HostKey == entry.Key:
+			result.Unchanged++
+			continue
+		case host.HostKey != "" && !overwrite:
+			result.Conflicts = append(result.Conflicts, item)
+			continue
+		}
+		if err := s.setTrustedKey(host, entry.Key, entry.Fingerprint, now); err != nil {
+			return nil, err
+		}
+		result.Imported = append(result.Imported, item)
+	}
+
+	logger.Logger.WithFields(map[string]interface{}{
+		"imported":  len(result.Imported),
+		"unchanged": result.Unchanged,
+		"conflicts": len(result.Conflicts),
+		"unmatched": result.Unmatched,
+	}).Info("导入 known_hosts 完成")
+	return result, nil
+}
+
+// preferImportedKey 同一主机匹配到多条记录时，candidate是否优于current
+func preferImportedKey(host *models.Host, candidate, current ssh.KnownHostsEntry) bool {
+	for _, known := range []string{host.PendingHostKey, host.HostKey} {
+		if known == "" {
+			continue
+		}
+		if candidate.Key == known || current.Key == known {
+			return candidate.Key == known && current.Key != known
+		}
+	}
+	return ssh.HostKeyTypeRank(candidate.KeyType) < ssh.HostKeyTypeRank(current.KeyType)
+}
+
+// setTrustedKey 信任密钥并清除待确认密钥，关闭主机的已有连接使之后的连接按新密钥校验
+func (s *HostKeyService) setTrustedKey(host *models.Host, key, fingerprint string, now time.Time) error {
+	if err := s.db.Model(host).Updates(trustedKeyUpdates(key, fingerprint, now)).Error; err != nil {
+		return fmt.Errorf("更新主机密钥失败: %v", err)
+	}
+	host.HostKey, host.HostKeyFingerprint, host.HostKeyTrustedAt = key, fingerprint, &now
+	host.PendingHostKey, host.PendingHostKeyFingerprint = "", ""
+	ssh.CloseHostConnections(host.ID)
+	return nil
+}
+
+// loadHost 获取主机
+func (s *HostKeyService) loadHost(hostID uint) (*models.Host, error) {
+	var host models.Host
+	if err := s.db.First(&host, hostID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrHostNotFound
+		}
+		return nil, err
+	}
+	return &host, nil
+}
@@ -0,0 +1,208 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"go-devops/internal/executor"
+	"go-devops/internal/logger"
+	"go-devops/internal/models"
+	"go-devops/internal/ssh"
+
+	"gorm.io/gorm"
+)
+
+// ErrInterpreterNotFound 自定义解释器不存在
+var ErrInterpreterNotFound = errors.New("解释器不存在")
+
+// 自定义解释器的默认启动模板
+const defaultInterpreterTemplate = "{interpreter} {script} {args}"
+
+// InterpreterInfo 脚本类型及其解释器，自定义解释器带有ID
+type InterpreterInfo struct {
+	ssh.Interpreter
+	ID          *uint  `json:"id,omitempty"`
+	Builtin     bool   `json:"builtin"`
+	Description string `json:"description,omitempty"`
+}
+
+// InterpreterService 自定义脚本解释器服务
+type InterpreterService struct {
+	db *gorm.DB
+}
+
+// NewInterpreterService 创建解释器服务
+func NewInterpreterService(db *gorm.DB) *InterpreterService {
+	return &InterpreterService{db: db}
+}
+
+// interpreterFromModel 转换为解释器定义
+func interpreterFromModel(record *models.ScriptInterpreter) ssh.Interpreter {
+	interpreter := ssh.Interpreter{
+		Type:      record.Type,
+		Extension: record.Extension,
+		Template:  record.Template,
+	}
+	for _, probe := range strings.Split(record.Probes, ",") {
+		if probe = strings.TrimSpace(probe); probe != "" {
+			interpreter.Probes = append(interpreter.Probes, probe)
+		}
+	}
+	return interpreter
+}
+
+// LoadCustomInterpreters 启动时将数据库中的自定义解释器注册到执行器
+func (s *InterpreterService) LoadCustomInterpreters() error {
+	var records []models.ScriptInterpreter
+	if err := s.db.Order("id ASC").Find(&records).Error; err != nil {
+		return fmt.Errorf("获取自定义解释器失败: %v", err)
+	}
+	for i := range records {
+		if err := executor.RegisterInterpreter(interpreterFromModel(&records[i])); err != nil {
+			logger.Logger.WithFields(map[string]interface{}{
+				"type":  records[i].Type,
+				"error": err.Error(),
+			}).Warn("注册自定义解释器失败")
+		}
+	}
+	return nil
+}
+
+// ListInterpreters 返回所有可用的脚本类型，内置类型在前
+func (s *InterpreterService) ListInterpreters() ([]InterpreterInfo, error) {
+	var records []models.ScriptInterpreter
+	if err := s.db.Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("获取自定义解释器失败: %v", err)
+	}
+	custom := make(map[string]*models.ScriptInterpreter, len(records))
+	for i := range records {
+		custom[records[i].Type] = &records[i]
+	}
+
+	var builtin, others []InterpreterInfo
+	for _, interpreter := range executor.Interpreters() {
+		info := InterpreterInfo{Interpreter: interpreter}
+		if executor.IsBuiltinInterpreter(interpreter.Type) {
+			info.Builtin = true
+			builtin = append(builtin, info)
+			continue
+		}
+		if record := custom[interpreter.Type]; record != nil {
+			id := record.ID
+			info.ID = &id
+			info.Description = record.Description
+		}
+		others = append(others, info)
+	}
+	return append(builtin, others...), nil
+}
+
+// buildInterpreter 根据请求构造并校验解释器定义
+func buildInterpreter(req models.ScriptInterpreterRequest) (ssh.Interpreter, error) {
+	interpreter := ssh.Interpreter{
+		Type:      strings.TrimSpace(req.Type),
+		Extension: strings.TrimSpace(req.Extension),
+		Template:  strings.TrimSpace(req.Template),
+	}
+	if interpreter.Template == "" {
+		interpreter.Template = defaultInterpreterTemplate
+	}
+	for _, probe := range req.Probes {
+		if probe = strings.TrimSpace(probe); probe != "" {
+			interpreter.Probes = append(interpreter.Probes, probe)
+		}
+	}
+	if err := interpreter.Validate(); err != nil {
+		return interpreter, err
+	}
+	if executor.IsBuiltinInterpreter(interpreter.Type) {
+		return interpreter, fmt.Errorf("脚本类型 '%s' 为内置类型，不能修改", interpreter.Type)
+	}
+	return interpreter, nil
+}
+
+// CreateInterpreter 注册自定义解释器
+func (s *InterpreterService) CreateInterpreter(req models.ScriptInterpreterRequest, userID uint) (*models.ScriptInterpreter, error) {
+	interpreter, err := buildInterpreter(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var count int64
+	s.db.Model(&models.ScriptInterpreter{}).Where("type = ?", interpreter.Type).Count(&count)
+	if count > 0 {
+		return nil, fmt.Errorf("脚本类型 '%s' 已存在", interpreter.Type)
+	}
+
+	record := &models.ScriptInterpreter{
+		Type:        interpreter.Type,
+		Extension:   interpreter.Extension,
+		Probes:      strings.Join(interpreter.Probes, ","),
+		Template:    interpreter.Template,
+		Description: req.Description,
+		CreatedBy:   userID,
+	}
+	if err := s.db.Create(record).Error; err != nil {
+		return nil, fmt.Errorf("创建解释器失败: %v", err)
+	}
+	if err := executor.RegisterInterpreter(interpreter); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// UpdateInterpreter 更新自定义解释器，脚本类型不能修改
+func (s *InterpreterService) UpdateInterpreter(id uint, req models.ScriptInterpreterRequest) (*models.ScriptInterpreter, error) {
+	var record models.ScriptInterpreter
+	if err := s.db.First(&record, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrInterpreterNotFound
+		}
+		return nil, fmt.Errorf("获取解释器失败: %v", err)
+	}
+	if strings.TrimSpace(req.Type) != record.Type {
+		return nil, errors.New("脚本类型不能修改")
+	}
+
+	interpreter, err := buildInterpreter(req)
+	if err != nil {
+		return nil, err
+	}
+	record.Extension = interpreter.Extension
+	record.Probes = strings.Join(interpreter.Probes, ",")
+	record.Template = interpreter.Template
+	record.Description = req.Description
+	if err := s.db.Save(&record).Error; err != nil {
+		return nil, fmt.Errorf("更新解释器失败: %v", err)
+	}
+	if err := executor.RegisterInterpreter(interpreter); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// DeleteInterpreter 删除自定义解释器，仍有脚本使用该类型时拒绝删除
+func (s *InterpreterService) DeleteInterpreter(id uint) (*models.ScriptInterpreter, error) {
+	var record models.ScriptInterpreter
+	if err := s.db.First(&record, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrInterpreterNotFound
+		}
+		return nil, fmt.Errorf("获取解释器失败: %v", err)
+	}
+
+	var count int64
+	if err := s.db.Model(&models.Script{}).Where("type = ?", record.Type).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("检查脚本引用失败: %v", err)
+	}
+	if count > 0 {
+		return nil, fmt.Errorf("仍有 %d 个脚本使用类型 '%s'，无法删除", count, record.Type)
+	}
+
+	if err := s.db.Delete(&record).Error; err != nil {
+		return nil, fmt.Errorf("删除解释器失败: %v", err)
+	}
+	executor.UnregisterInterpreter(record.Type)
+	return &record, nil
+}
@@ -0,0 +1,304 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"go-devops/internal/logger"
+	"go-devops/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// 作业依赖触发条件
+const (
+	DependencyOnSuccess = "success" // 上游运行全部成功
+	DependencyOnFailure = "failure" // 上游运行失败或部分失败
+	DependencyAlways    = "always"  // 上游运行结束即触发（取消除外）
+)
+
+// ErrDependencyCycle 添加依赖会形成环
+var ErrDependencyCycle = errors.New("添加该依赖会形成循环依赖")
+
+// jobDependencyMu 串行化本进程内的依赖添加，使环检查和插入之间不会穿插其他添加
+var jobDependencyMu sync.Mutex
+
+// JobDependencyNode 依赖图中的作业节点
+type JobDependencyNode struct {
+	ID       uint   `json:"id"`
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Relation string `json:"relation"` // 与查询作业的关系：self, upstream, downstream
+}
+
+// JobDependencyGraph 作业的依赖图，包含所有上游和下游作业
+type JobDependencyGraph struct {
+	Nodes []JobDependencyNode    `json:"nodes"`
+	Edges []models.JobDependency `json:"edges"`
+}
+
+// AddJobDependency 添加依赖：下游作业在上游作业运行结束且满足条件时触发
+func (s *ExecutionService) AddJobDependency(upstreamID, downstreamID uint, condition string, userID uint) (*models.JobDependency, error) {
+	if condition == "" {
+		condition = DependencyOnSuccess
+	}
+	switch condition {
+	case DependencyOnSuccess, DependencyOnFailure, DependencyAlways:
+	default:
+		return nil, fmt.Errorf("触发条件 '%s' 无效，应为 success、failure 或 always", condition)
+	}
+	if upstreamID == downstreamID {
+		return nil, errors.New("作业不能依赖自身")
+	}
+
+	var count int64
+	if err := s.db.Model(&models.Job{}).Where("id IN ?", []uint{upstreamID, downstreamID}).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("获取作业信息失败: %v", err)
+	}
+	if count != 2 {
+		return nil, ErrJobNotFound
+	}
+
+	jobDependencyMu.Lock()
+	defer jobDependencyMu.Unlock()
+
+	edges, err := s.loadJobDependencies()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkNewDependency(edges, upstreamID, downstreamID, 0); err != nil {
+		return nil, err
+	}
+
+	dependency := &models.JobDependency{
+		UpstreamJobID:   upstreamID,
+		DownstreamJobID: downstreamID,
+		Condition:       condition,
+		CreatedBy:       userID,
+	}
+	if err := s.db.Create(dependency).Error; err != nil {
+		return nil, fmt.Errorf("创建依赖关系失败: %v", err)
+	}
+
+	// 插入后重新检查：其他实例并发添加的依赖可能与本次添加共同形成环，此时撤销本次添加
+	edges, err = s.loadJobDependencies()
+	if err == nil {
+		err = checkNewDependency(edges, upstreamID, downstreamID, dependency.ID)
+	}
+	if err != nil {
+		if delErr := s.db.Delete(dependency).Error; delErr != nil {
+			logger.Logger.WithFields(map[string]interface{}{
+				"dependency_id": dependency.ID,
+				"error":         delErr.Error(),
+			}).Error("撤销作业依赖失败")
+		}
+		return nil, err
+	}
+	return dependency, nil
+}
+
+// checkNewDependency 检查在edges中添加 upstream -> downstream 不会重复或形成环，
+// addedID不为0时表示该边已插入，检查时忽略它自身和之后插入的重复边
+func checkNewDependency(edges []models.JobDependency, upstreamID, downstreamID, addedID uint) error {
+	others := make([]models.JobDependency, 0, len(edges))
+	for _, edge := range edges {
+		if edge.ID == addedID {
+			continue
+		}
+		if edge.UpstreamJobID == upstreamID && edge.DownstreamJobID == downstreamID {
+			// 并发插入的重复依赖保留先插入的一条
+			if addedID != 0 && edge.ID > addedID {
+				continue
+			}
+			return errors.New("依赖关系已存在")
+		}
+		others = append(others, edge)
+	}
+	// 下游作业已能到达上游作业时，新增的边会形成环
+	if reachable(dependencyAdjacency(others, false), downstreamID)[upstreamID] {
+		return ErrDependencyCycle
+	}
+	return nil
+}
+
+// JobDependencyGraph 返回作业所在的依赖图：作业本身、所有上游和下游作业以及它们之间的依赖边
+func (s *ExecutionService) JobDependencyGraph(jobID uint) (*JobDependencyGraph, error) {
+	edges, err := s.loadJobDependencies()
+	if err != nil {
+		return nil, err
+	}
+
+	relations := map[uint]string{jobID: "self"}
+	for id := range reachable(dependencyAdjacency(edges, true), jobID) {
+		relations[id] = "upstream"
+	}
+	for id := range reachable(dependencyAdjacency(edges, false), jobID) {
+		relations[id] = "downstream"
+	}
+
+	ids := make([]uint, 0, len(relations))
+	for id := range relations {
+		ids = append(ids, id)
+	}
+	var jobs []models.Job
+	if err := s.db.Select("id", "name", "status").Where("id IN ?", ids).Order("id ASC").Find(&jobs).Error; err != nil {
+		return nil, fmt.Errorf("获取作业信息失败: %v", err)
+	}
+
+	graph := &JobDependencyGraph{
+		Nodes: make([]JobDependencyNode, 0, len(jobs)),
+		Edges: make([]models.JobDependency, 0),
+	}
+	for _, job := range jobs {
+		graph.Nodes = append(graph.Nodes, JobDependencyNode{
+			ID:       job.ID,
+			Name:     job.Name,
+			Status:   job.Status,
+			Relation: relations[job.ID],
+		})
+	}
+	for _, edge := range edges {
+		if relations[edge.UpstreamJobID] != "" && relations[edge.DownstreamJobID] != "" {
+			graph.Edges = append(graph.Edges, edge)
+		}
+	}
+	return graph, nil
+}
+
+// loadJobDependencies 加载全部依赖边
+func (s *ExecutionService) loadJobDependencies() ([]models.JobDependency, error) {
+	var edges []models.JobDependency
+	if err := s.db.Order("id ASC").Find(&edges).Error; err != nil {
+		return nil, fmt.Errorf("获取作业依赖失败: %v", err)
+	}
+	return edges, nil
+}
+
+// dependencyAdjacency 构建邻接表，reverse为true时从下游指向上游
+func dependencyAdjacency(edges []models.JobDependency, reverse bool) map[uint][]uint {
+	adjacency := make(map[uint][]uint)
+	for _, edge := range edges {
+		from, to := edge.UpstreamJobID, edge.DownstreamJobID
+		if reverse {
+			from, to = to, from
+		}
+		adjacency[from] = append(adjacency[from], to)
+	}
+	return adjacency
+}
+
+// reachable 返回从start出发可到达的所有节点（不含start本身，除非存在环）
+func reachable(adjacency map[uint][]uint, start uint) map[uint]bool {
+	visited := make(map[uint]bool)
+	queue := []uint{start}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, next := range adjacency[current] {
+			if !visited[next] {
+				visited[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	return visited
+}
+
+// dependencyConditionMatches 判断上游运行的最终状态是否满足触发条件，被取消的运行不触发下游
+func dependencyConditionMatches(condition, status string) bool {
+	switch status {
+	case "completed":
+		return condition == DependencyOnSuccess || condition == DependencyAlways
+	case "failed", "partial_failed":
+		return condition == DependencyOnFailure || condition == DependencyAlways
+	default:
+		return false
+	}
+}
+
+// triggerDownstreamJobs 上游运行结束后触发满足条件的下游作业
+// 下游运行沿用上游的触发者，记录上游运行ID，并继承上游运行中下游脚本声明过的参数值
+func (s *ExecutionService) triggerDownstreamJobs(run *models.JobRun) {
+	var edges []models.JobDependency
+	if err := s.db.Where("upstream_job_id = ?", run.JobID).Order("id ASC").Find(&edges).Error; err != nil {
+		logger.Logger.WithFields(map[string]interface{}{
+			"job_id": run.JobID,
+			"error":  err.Error(),
+		}).Error("获取下游作业失败")
+		return
+	}
+
+	var upstreamParams []models.ScriptParameterValue
+	if run.Parameters != "" {
+		json.Unmarshal([]byte(run.Parameters), &upstreamParams)
+	}
+
+	for _, edge := range edges {
+		if !dependencyConditionMatches(edge.Condition, run.Status) {
+			continue
+		}
+
+		fields := map[string]interface{}{
+			"upstream_job_id":   run.JobID,
+			"upstream_run_id":   run.ID,
+			"downstream_job_id": edge.DownstreamJobID,
+			"condition":         edge.Condition,
+		}
+		downstreamRun, approval, err := s.startDownstreamRun(edge.DownstreamJobID, run, upstreamParams)
+		if err != nil {
+			fields["error"] = err.Error()
+			if downstreamRun != nil {
+				fields["downstream_run_id"] = downstreamRun.ID
+			}
+			logger.Logger.WithFields(fields).Error("触发下游作业失败")
+			continue
+		}
+		if approval != nil {
+			fields["request_id"] = approval.ID
+			logger.Logger.WithFields(fields).Warn("下游作业需要审批，已提交执行申请")
+			continue
+		}
+		fields["downstream_run_id"] = downstreamRun.ID
+		logger.Logger.WithFields(fields).Info("已触发下游作业")
+	}
+}
+
+// startDownstreamRun 以依赖触发的方式启动下游作业，下游作业需要审批时返回创建的执行申请
+func (s *ExecutionService) startDownstreamRun(jobID uint, parent *models.JobRun, upstreamParams []models.ScriptParameterValue) (*models.JobRun, *models.ExecutionRequest, error) {
+	var job models.Job
+	if err := s.db.Preload("Script").First(&job, jobID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil, ErrJobNotFound
+		}
+		return nil, nil, err
+	}
+
+	// 只继承下游脚本声明过的参数，避免未声明参数导致校验失败
+	defs, err := ParseScriptParameters(job.Script.Parameters)
+	if err != nil {
+		return nil, nil, err
+	}
+	declared := make(map[string]bool, len(defs))
+	for _, def := range defs {
+		declared[def.Name] = true
+	}
+	overrides := make(map[string]interface{})
+	for _, param := range upstreamParams {
+		if declared[param.Name] && param.Value != "" {
+			overrides[param.Name] = param.Value
+		}
+	}
+
+	req, err := s.NewJobRunRequest(jobID, overrides, TriggerDependency, parent.TriggeredBy)
+	if err != nil {
+		return nil, nil, err
+	}
+	parentID := parent.ID
+	req.ParentRunID = &parentID
+
+	payload := ExecutionRequestPayload{Parameters: overrides, ParentRunID: parent.ID}
+	return s.StartJobRunOrRequestApproval(req, payload,
+		fmt.Sprintf("上游作业 %d 的运行 %d 结束后依赖触发", parent.JobID, parent.ID))
+}
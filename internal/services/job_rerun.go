@@ -0,0 +1,127 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"go-devops/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// 重新运行模式
+const (
+	RerunFailedOnly    = "failed_only"    // 只重新运行未成功的主机
+	RerunAll           = "all"            // 重新运行源批次的所有主机
+	RerunSelectedHosts = "selected_hosts" // 重新运行源批次中指定的主机
+)
+
+// ErrJobRunNotFound 运行记录不存在
+var ErrJobRunNotFound = errors.New("运行记录不存在")
+
+// NewRerunRequest 根据源运行批次构造重新运行请求：复用源批次执行记录中的脚本快照和运行参数，
+// 即使脚本在此之后被修改，重新运行的内容也与源批次一致。sourceRunID为0时使用作业最近一次运行
+func (s *ExecutionService) NewRerunRequest(jobID, sourceRunID uint, mode string, hostIDs []uint, triggeredBy uint) (JobRunRequest, error) {
+	var job models.Job
+	if err := s.db.Preload("Script").First(&job, jobID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return JobRunRequest{}, ErrJobNotFound
+		}
+		return JobRunRequest{}, fmt.Errorf("获取作业信息失败: %v", err)
+	}
+
+	// 获取源运行批次
+	var source models.JobRun
+	query := s.db.Where("job_id = ?", jobID)
+	if sourceRunID != 0 {
+		query = query.Where("id = ?", sourceRunID)
+	}
+	if err := query.Order("id DESC").First(&source).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return JobRunRequest{}, ErrJobRunNotFound
+		}
+		return JobRunRequest{}, fmt.Errorf("获取运行记录失败: %v", err)
+	}
+	if source.Status == "running" || source.Status == "pending" {
+		return JobRunRequest{}, fmt.Errorf("运行 %d 尚未结束，无法重新运行", source.ID)
+	}
+
+	var executions []models.JobExecution
+	if err := s.db.Where("run_id = ?", source.ID).Order("id ASC").Find(&executions).Error; err != nil {
+		return JobRunRequest{}, fmt.Errorf("获取执行记录失败: %v", err)
+	}
+	if len(executions) == 0 {
+		return JobRunRequest{}, fmt.Errorf("运行 %d 没有执行记录", source.ID)
+	}
+
+	// 按模式选择主机
+	var targetIDs []uint
+	switch mode {
+	case "", RerunFailedOnly:
+		for _, execution := range executions {
+			if execution.Status != "completed" {
+				targetIDs = append(targetIDs, execution.HostID)
+			}
+		}
+		if len(targetIDs) == 0 {
+			return JobRunRequest{}, fmt.Errorf("运行 %d 没有失败的主机", source.ID)
+		}
+	case RerunAll:
+		for _, execution := range executions {
+			targetIDs = append(targetIDs, execution.HostID)
+		}
+	case RerunSelectedHosts:
+		if len(hostIDs) == 0 {
+			return JobRunRequest{}, errors.New("请指定要重新运行的主机")
+		}
+		inSource := make(map[uint]bool, len(executions))
+		for _, execution := range executions {
+			inSource[execution.HostID] = true
+		}
+		for _, id := range hostIDs {
+			if !inSource[id] {
+				return JobRunRequest{}, fmt.Errorf("主机 %d 不在运行 %d 中", id, source.ID)
+			}
+		}
+		targetIDs = hostIDs
+	default:
+		return JobRunRequest{}, fmt.Errorf("重新运行模式 '%s' 无效，应为 failed_only、all 或 selected_hosts", mode)
+	}
+	targetIDs = uniqueIDs(targetIDs)
+
+	var hosts []models.Host
+	if err := s.db.Where("id IN ?", targetIDs).Find(&hosts).Error; err != nil {
+		return JobRunRequest{}, fmt.Errorf("获取主机信息失败: %v", err)
+	}
+	if len(hosts) != len(targetIDs) {
+		return JobRunRequest{}, errors.New("部分主机不存在")
+	}
+
+	// 使用源批次的运行参数
+	var params []models.ScriptParameterValue
+	if source.Parameters != "" {
+		if err := json.Unmarshal([]byte(source.Parameters), &params); err != nil {
+			return JobRunRequest{}, fmt.Errorf("源运行参数格式错误: %v", err)
+		}
+	}
+
+	// 使用源批次的脚本快照替换当前脚本内容
+	snapshot := executions[0]
+	rerunJob := job
+	rerunJob.Script.Content = snapshot.ScriptContent
+	rerunJob.Script.Type = snapshot.ScriptType
+	if snapshot.ScriptName != "" {
+		rerunJob.Script.Name = snapshot.ScriptName
+	}
+
+	sourceID := source.ID
+	return JobRunRequest{
+		Job:         &rerunJob,
+		Hosts:       hosts,
+		Parameters:  params,
+		TriggerType: TriggerRerun,
+		TriggeredBy: triggeredBy,
+		ParentRunID: &sourceID,
+	}, nil
+}
@@ -0,0 +1,383 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-devops/internal/logger"
+	"go-devops/internal/models"
+	"go-devops/internal/ssh"
+
+	"gorm.io/gorm"
+)
+
+// 作业运行触发来源
+const (
+	TriggerManual     = "manual"     // 手动执行
+	TriggerSchedule   = "schedule"   // 定时调度
+	TriggerWorkflow   = "workflow"   // 工作流
+	TriggerRerun      = "rerun"      // 重新运行
+	TriggerDependency = "dependency" // 上游作业依赖触发
+)
+
+// JobRunRequest 启动作业运行的参数
+type JobRunRequest struct {
+	Job         *models.Job                   // 作业（需预加载Script）
+	Hosts       []models.Host                 // 目标主机
+	Parameters  []models.ScriptParameterValue // 已校验的脚本参数
+	TriggerType string                        // 触发来源
+	TriggeredBy uint                          // 触发者ID
+	ParentRunID *uint                         // 依赖触发时的上游运行批次
+	Approved    bool                          // 已通过执行审批，只由审批服务设置；为false时需要审批的运行不会启动
+}
+
+// ErrJobNotFound 作业不存在
+var ErrJobNotFound = errors.New("作业不存在")
+
+// NewJobRunRequest 加载作业和目标主机，合并参数覆盖值并按脚本参数定义校验
+func (s *ExecutionService) NewJobRunRequest(jobID uint, overrides map[string]interface{}, triggerType string, triggeredBy uint) (JobRunRequest, error) {
+	var job models.Job
+	if err := s.db.Preload("Script").First(&job, jobID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return JobRunRequest{}, ErrJobNotFound
+		}
+		return JobRunRequest{}, fmt.Errorf("获取作业信息失败: %v", err)
+	}
+
+	// 解析主机ID列表
+	var hostIDs []uint
+	if err := json.Unmarshal([]byte(job.HostIDs), &hostIDs); err != nil {
+		return JobRunRequest{}, errors.New("主机配置错误")
+	}
+	if len(hostIDs) == 0 {
+		return JobRunRequest{}, errors.New("未配置执行主机")
+	}
+
+	// 获取主机信息
+	var hosts []models.Host
+	if err := s.db.Where("id IN ?", hostIDs).Find(&hosts).Error; err != nil {
+		return JobRunRequest{}, fmt.Errorf("获取主机信息失败: %v", err)
+	}
+	if len(hosts) != len(hostIDs) {
+		return JobRunRequest{}, errors.New("部分主机不存在")
+	}
+
+	// 校验并解析脚本参数
+	params, err := ResolveJobParameters(&job, overrides)
+	if err != nil {
+		return JobRunRequest{}, err
+	}
+
+	return JobRunRequest{
+		Job:         &job,
+		Hosts:       hosts,
+		Parameters:  params,
+		TriggerType: triggerType,
+		TriggeredBy: triggeredBy,
+	}, nil
+}
+
+// ResolveJobParameters 合并作业参数与本次执行的覆盖值，并按脚本参数定义校验
+func ResolveJobParameters(job *models.Job, overrides map[string]interface{}) ([]models.ScriptParameterValue, error) {
+	defs, err := ParseScriptParameters(job.Script.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	values, err := ParseParameterValues(job.Parameters)
+	if err != nil {
+		return nil, fmt.Errorf("作业%s", err.Error())
+	}
+	return ResolveScriptParameters(defs, MergeParameterValues(values, overrides))
+}
+
+// StartJobRun 创建作业运行批次，为每台主机创建执行记录并异步执行。
+// 所有主机的执行记录都创建失败时运行批次标记为失败，与错误一同返回
+func (s *ExecutionService) StartJobRun(req JobRunRequest) (*models.JobRun, []models.JobExecution, error) {
+	job := req.Job
+	if len(req.Hosts) == 0 {
+		return nil, nil, errors.New("未配置执行主机")
+	}
+
+	// 无论从哪里触发（手动、定时调度、依赖、重新运行），需要审批的运行都必须先经过批准
+	if !req.Approved {
+		reasons, err := s.approvalReasons(job, hostIDsOf(req.Hosts))
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(reasons) > 0 {
+			return nil, nil, &ApprovalRequiredError{Reasons: reasons}
+		}
+	}
+
+	triggerType := req.TriggerType
+	if triggerType == "" {
+		triggerType = TriggerManual
+	}
+	params, err := json.Marshal(req.Parameters)
+	if err != nil {
+		return nil, nil, fmt.Errorf("序列化运行参数失败: %v", err)
+	}
+
+	run := &models.JobRun{
+		JobID:       job.ID,
+		TriggerType: triggerType,
+		TriggeredBy: req.TriggeredBy,
+		ParentRunID: req.ParentRunID,
+		Parameters:  string(params),
+		Status:      "running",
+		StartTime:   time.Now(),
+	}
+	if err := s.db.Create(run).Error; err != nil {
+		return nil, nil, fmt.Errorf("创建作业运行记录失败: %v", err)
+	}
+
+	// 更新作业状态为运行中
+	s.db.Model(&models.Job{}).Where("id = ?", job.ID).Update("status", "running")
+
+	opts := ExecutionOptions{
+		InputFiles:     s.loadInputFiles(job),
+		SaveOutput:     job.SaveOutput,
+		SaveError:      job.SaveError,
+		OutputCategory: job.OutputCategory,
+		Timeout:        job.Timeout,
+		Parameters:     req.Parameters,
+		RunID:          run.ID,
+		Retry:          RetryPolicyFromJob(job),
+		Become:         BecomeSettingsFromJob(job),
+		Artifacts:      ArtifactPolicyFromJob(job),
+	}
+
+	var executions []models.JobExecution
+	var targets []ExecutionTarget
+	for _, host := range req.Hosts {
+		execution, err := s.createRunExecution(run, job, host.ID)
+		if err != nil {
+			logger.Logger.WithFields(map[string]interface{}{
+				"job_id":  job.ID,
+				"run_id":  run.ID,
+				"host_id": host.ID,
+				"error":   err.Error(),
+			}).Error("创建执行记录失败")
+			continue
+		}
+		executions = append(executions, *execution)
+		targets = append(targets, ExecutionTarget{Execution: execution, Host: host})
+	}
+
+	// 主机总数只计入成功创建了执行记录的主机，运行批次才能汇总到结束
+	run.TotalCount = len(targets)
+	if err := s.db.Model(run).Update("total_count", run.TotalCount).Error; err != nil {
+		logger.Logger.WithFields(map[string]interface{}{
+			"run_id": run.ID,
+			"error":  err.Error(),
+		}).Error("更新作业运行主机数失败")
+	}
+
+	if len(targets) == 0 {
+		// 没有可执行的主机：直接将运行标记为失败，不触发下游作业和回调
+		if err := s.UpdateJobRunStatus(run.ID); err != nil {
+			logger.Logger.WithFields(map[string]interface{}{
+				"run_id": run.ID,
+				"error":  err.Error(),
+			}).Error("更新作业运行状态失败")
+		}
+		s.db.First(run, run.ID)
+		return run, nil, fmt.Errorf("创建执行记录失败，运行 %d 已标记为失败", run.ID)
+	}
+
+	// 按作业的并发和批次设置异步执行，每台主机结束时向运行跟踪器报告。
+	// 多占用一个计数并在分发完成后释放，避免先启动的执行在其余执行登记前结束而提前汇总
+	jobRunTracker.add(run.ID, len(targets)+1)
+	defer s.reportRunExecutionDone(run.ID)
+	s.DispatchExecutions(targets, &job.Script, opts, DispatchOptionsFromJob(job))
+
+	logger.Logger.WithFields(map[string]interface{}{
+		"job_id":       job.ID,
+		"run_id":       run.ID,
+		"trigger_type": triggerType,
+		"host_count":   len(executions),
+	}).Info("作业运行已启动")
+
+	return run, executions, nil
+}
+
+// loadInputFiles 获取作业的输入文件（如果有）
+func (s *ExecutionService) loadInputFiles(job *models.Job) []models.File {
+	var inputFiles []models.File
+	if job.InputFileIDs != "" {
+		var fileIDs []uint
+		if err := json.Unmarshal([]byte(job.InputFileIDs), &fileIDs); err == nil {
+			s.db.Where("id IN ?", fileIDs).Find(&inputFiles)
+		}
+	}
+	return inputFiles
+}
+
+// createRunExecution 创建归属于运行批次的执行记录
+func (s *ExecutionService) createRunExecution(run *models.JobRun, job *models.Job, hostID uint) (*models.JobExecution, error) {
+	jobID := job.ID
+	runID := run.ID
+	execution := &models.JobExecution{
+		JobID:         &jobID,
+		RunID:         &runID,
+		HostID:        hostID,
+		Status:        "pending",
+		ScriptContent: job.Script.Content,
+		ScriptType:    job.Script.Type,
+		IsQuickExec:   false,
+		ExecutedBy:    run.TriggeredBy,
+		JobName:       job.Name,
+		ScriptName:    job.Script.Name,
+	}
+
+	if err := s.db.Create(execution).Error; err != nil {
+		return nil, fmt.Errorf("创建执行记录失败: %v", err)
+	}
+	return execution, nil
+}
+
+// jobRunCounts 运行批次内各类执行的数量
+type jobRunCounts struct {
+	total, success, failed, cancelled, unfinished int
+}
+
+// countJobRunExecutions 统计各运行批次内执行记录的状态
+func (s *ExecutionService) countJobRunExecutions(runIDs []uint) (map[uint]*jobRunCounts, error) {
+	var stats []struct {
+		RunID  uint
+		Status string
+		Count  int
+	}
+	if err := s.db.Model(&models.JobExecution{}).
+		Select("run_id, status, count(*) as count").
+		Where("run_id IN ?", runIDs).
+		Group("run_id, status").
+		Scan(&stats).Error; err != nil {
+		return nil, err
+	}
+
+	counts := make(map[uint]*jobRunCounts, len(runIDs))
+	for _, id := range runIDs {
+		counts[id] = &jobRunCounts{}
+	}
+	for _, stat := range stats {
+		c := counts[stat.RunID]
+		c.total += stat.Count
+		switch {
+		case stat.Status == "completed":
+			c.success += stat.Count
+		case !IsExecutionFinished(stat.Status):
+			c.unfinished += stat.Count
+		default:
+			c.failed += stat.Count
+			if stat.Status == "cancelled" {
+				c.cancelled += stat.Count
+			}
+		}
+	}
+	return counts, nil
+}
+
+// jobRunStatus 根据执行统计确定运行批次的整体状态
+func jobRunStatus(c *jobRunCounts) string {
+	switch {
+	case c.unfinished > 0:
+		return "running"
+	case c.total == 0:
+		return "failed"
+	case c.failed == 0:
+		return "completed"
+	case c.success > 0:
+		return "partial_failed"
+	case c.cancelled == c.total:
+		return "cancelled"
+	default:
+		return "failed"
+	}
+}
+
+// FillJobRunCounts 用执行记录的实时统计填充运行批次的成功/失败数量
+func (s *ExecutionService) FillJobRunCounts(runs []models.JobRun) error {
+	if len(runs) == 0 {
+		return nil
+	}
+	runIDs := make([]uint, 0, len(runs))
+	for _, run := range runs {
+		runIDs = append(runIDs, run.ID)
+	}
+	counts, err := s.countJobRunExecutions(runIDs)
+	if err != nil {
+		return err
+	}
+	for i := range runs {
+		c := counts[runs[i].ID]
+		runs[i].SuccessCount = c.success
+		runs[i].FailedCount = c.failed
+	}
+	return nil
+}
+
+// UpdateJobRunStatus 汇总运行批次内的执行结果，更新运行状态；该批次为作业最近一次运行时同步更新作业状态
+func (s *ExecutionService) UpdateJobRunStatus(runID uint) error {
+	var run models.JobRun
+	if err := s.db.First(&run, runID).Error; err != nil {
+		return err
+	}
+
+	counts, err := s.countJobRunExecutions([]uint{runID})
+	if err != nil {
+		return err
+	}
+	c := counts[runID]
+	status := jobRunStatus(c)
+
+	updates := map[string]interface{}{
+		"status":        status,
+		"success_count": c.success,
+		"failed_count":  c.failed,
+	}
+	if status != "running" && run.EndTime == nil {
+		updates["end_time"] = time.Now()
+	}
+	if err := s.db.Model(&run).Updates(updates).Error; err != nil {
+		return err
+	}
+
+	// 只有最近一次运行决定作业状态
+	var latest models.JobRun
+	if err := s.db.Where("job_id = ?", run.JobID).Order("id DESC").First(&latest).Error; err != nil {
+		return err
+	}
+	if latest.ID != run.ID {
+		return nil
+	}
+	return s.db.Model(&models.Job{}).Where("id = ?", run.JobID).Update("status", status).Error
+}
+
+// cancelPendingRunExecutions 将运行批次中尚未开始的执行标记为已取消
+func (s *ExecutionService) cancelPendingRunExecutions(runID, userID uint) ([]uint, error) {
+	var ids []uint
+	if err := s.db.Model(&models.JobExecution{}).
+		Where("run_id = ? AND status = ?", runID, "pending").
+		Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	endTime := time.Now()
+	if err := s.db.Model(&models.JobExecution{}).
+		Where("id IN ? AND status = ?", ids, "pending").
+		Updates(map[string]interface{}{
+			"status":       "cancelled",
+			"failure_type": ssh.FailureCancelled,
+			"cancelled_by": userID,
+			"end_time":     &endTime,
+		}).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
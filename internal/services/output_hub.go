@@ -0,0 +1,194 @@
+package services
+
+import (
+	"sync"
+	"unicode/utf8"
+)
+
+// 每个订阅者的事件缓冲数量，缓冲满时断开该订阅者，由客户端按偏移量重连
+const subscriberBufferSize = 256
+
+// 输出流名称
+const (
+	StreamStdout = "stdout"
+	StreamStderr = "stderr"
+)
+
+// OutputEvent 执行输出流事件
+type OutputEvent struct {
+	Type   string `json:"type"`             // output, status
+	Stream string `json:"stream,omitempty"` // stdout, stderr
+	Data   string `json:"data,omitempty"`   // 输出内容
+	Offset int    `json:"offset"`           // 本块内容在所属输出流中的起始字节偏移
+	Status string `json:"status,omitempty"` // 执行最终状态
+}
+
+// executionStream 单个执行的实时输出
+type executionStream struct {
+	mu          sync.Mutex
+	output      map[string][]byte // 各输出流的完整内容
+	pending     map[string][]byte // 各输出流中尚未凑成完整UTF-8字符的字节
+	subscribers map[chan OutputEvent]struct{}
+	dirty       bool
+}
+
+// OutputHub 管理正在运行的执行的实时输出，并向订阅者广播
+type OutputHub struct {
+	mu      sync.Mutex
+	streams map[uint]*executionStream
+}
+
+// 全局输出中心，所有执行服务实例共享
+var outputHub = &OutputHub{streams: make(map[uint]*executionStream)}
+
+// open 为执行创建输出流
+func (h *OutputHub) open(executionID uint) *executionStream {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	stream := &executionStream{
+		output:      make(map[string][]byte),
+		pending:     make(map[string][]byte),
+		subscribers: make(map[chan OutputEvent]struct{}),
+	}
+	h.streams[executionID] = stream
+	return stream
+}
+
+// close 广播最终状态并关闭所有订阅
+func (h *OutputHub) close(executionID uint, status string) {
+	h.mu.Lock()
+	stream, ok := h.streams[executionID]
+	delete(h.streams, executionID)
+	h.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+	event := OutputEvent{Type: "status", Status: status}
+	for ch := range stream.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+		close(ch)
+	}
+	stream.subscribers = nil
+}
+
+// Subscribe 订阅执行输出。执行仍在运行时返回各输出流在offsets之后已产生的内容和后续事件通道；
+// 执行不在运行中时running为false，调用方应从数据库读取输出
+func (h *OutputHub) Subscribe(executionID uint, offsets map[string]int) (backlog []OutputEvent, events <-chan OutputEvent, unsubscribe func(), running bool) {
+	h.mu.Lock()
+	stream, ok := h.streams[executionID]
+	h.mu.Unlock()
+	if !ok {
+		return nil, nil, func() {}, false
+	}
+
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+	if stream.subscribers == nil {
+		// 已结束
+		return nil, nil, func() {}, false
+	}
+
+	for _, name := range []string{StreamStdout, StreamStderr} {
+		offset := offsets[name]
+		if offset < 0 {
+			offset = 0
+		}
+		if content := stream.output[name]; offset < len(content) {
+			backlog = append(backlog, OutputEvent{
+				Type:   "output",
+				Stream: name,
+				Data:   string(content[offset:]),
+				Offset: offset,
+			})
+		}
+	}
+
+	ch := make(chan OutputEvent, subscriberBufferSize)
+	stream.subscribers[ch] = struct{}{}
+	unsubscribe = func() {
+		stream.mu.Lock()
+		defer stream.mu.Unlock()
+		if _, ok := stream.subscribers[ch]; ok {
+			delete(stream.subscribers, ch)
+			close(ch)
+		}
+	}
+	return backlog, ch, unsubscribe, true
+}
+
+// write 追加输出并广播给订阅者
+func (s *executionStream) write(streamName string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	complete, rest := splitUTF8(append(s.pending[streamName], data...))
+	s.pending[streamName] = rest
+	if len(complete) == 0 {
+		return
+	}
+
+	event := OutputEvent{
+		Type:   "output",
+		Stream: streamName,
+		Data:   string(complete),
+		Offset: len(s.output[streamName]),
+	}
+	s.output[streamName] = append(s.output[streamName], complete...)
+	s.dirty = true
+
+	for ch := range s.subscribers {
+		select {
+		case ch <- event:
+		default:
+			// 订阅者消费过慢，断开后由客户端按偏移量重连
+			delete(s.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// snapshot 返回自上次调用以来有变化时的标准输出和标准错误
+func (s *executionStream) snapshot() (string, string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.dirty {
+		return "", "", false
+	}
+	s.dirty = false
+	return string(s.output[StreamStdout]), string(s.output[StreamStderr]), true
+}
+
+// contents 返回标准输出和标准错误的完整内容，包括末尾不完整的字符字节
+func (s *executionStream) contents() (string, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return string(s.output[StreamStdout]) + string(s.pending[StreamStdout]),
+		string(s.output[StreamStderr]) + string(s.pending[StreamStderr])
+}
+
+// splitUTF8 将数据拆分为完整的UTF-8部分和末尾不完整的字符字节
+func splitUTF8(data []byte) ([]byte, []byte) {
+	// UTF-8字符最长4字节，只需检查末尾3个字节
+	for i := 1; i <= 3 && i <= len(data); i++ {
+		start := len(data) - i
+		if utf8.RuneStart(data[start]) {
+			if !utf8.FullRune(data[start:]) {
+				return data[:start], append([]byte(nil), data[start:]...)
+			}
+			break
+		}
+	}
+	return data, nil
+}
+
+// SubscribeExecutionOutput 订阅执行的实时输出，offsets为各输出流已接收的字节数
+func SubscribeExecutionOutput(executionID uint, offsets map[string]int) ([]OutputEvent, <-chan OutputEvent, func(), bool) {
+	return outputHub.Subscribe(executionID, offsets)
+}
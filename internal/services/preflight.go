@@ -0,0 +1,174 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"go-devops/internal/executor"
+	"go-devops/internal/logger"
+	"go-devops/internal/models"
+	"go-devops/internal/ssh"
+)
+
+// 预检时同时检查的最大主机数
+const preflightConcurrency = 20
+
+// 与主机无关的检查项
+const (
+	PreflightScriptType = "script_type" // 脚本类型已注册
+	PreflightInputFiles = "input_files" // 输入文件在本地存在
+)
+
+// PreflightHostReport 单台主机的预检结果
+type PreflightHostReport struct {
+	HostID   uint                 `json:"host_id"`
+	HostName string               `json:"host_name"`
+	IP       string               `json:"ip"`
+	Passed   bool                 `json:"passed"`
+	Checks   []ssh.PreflightCheck `json:"checks"`
+}
+
+// PreflightReport 作业运行的预检报告
+type PreflightReport struct {
+	JobID       uint                  `json:"job_id"`
+	Passed      bool                  `json:"passed"`       // 所有检查均通过
+	HostCount   int                   `json:"host_count"`   // 目标主机数
+	FailedHosts int                   `json:"failed_hosts"` // 未通过检查的主机数
+	Checks      []ssh.PreflightCheck  `json:"checks"`       // 与主机无关的检查
+	Hosts       []PreflightHostReport `json:"hosts"`        // 各目标主机的检查结果
+}
+
+// PreflightJobRun 按作业的实际执行设置检查目标主机，不创建运行记录也不执行脚本
+func (s *ExecutionService) PreflightJobRun(req JobRunRequest) *PreflightReport {
+	job := req.Job
+	report := &PreflightReport{
+		JobID:     job.ID,
+		HostCount: len(req.Hosts),
+		Hosts:     make([]PreflightHostReport, len(req.Hosts)),
+	}
+
+	var interpreter *ssh.Interpreter
+	if found, ok := executor.LookupInterpreter(job.Script.Type); ok {
+		interpreter = &found
+		report.Checks = append(report.Checks, ssh.PreflightCheck{
+			Name:    PreflightScriptType,
+			Passed:  true,
+			Message: fmt.Sprintf("脚本类型 %s 已注册", job.Script.Type),
+		})
+	} else {
+		report.Checks = append(report.Checks, ssh.PreflightCheck{
+			Name:    PreflightScriptType,
+			Message: fmt.Sprintf("不支持的脚本类型: %s", job.Script.Type),
+		})
+	}
+	report.Checks = append(report.Checks, s.checkInputFiles(job))
+
+	become := BecomeSettingsFromJob(job)
+	base := workdirBase()
+	semaphore := make(chan struct{}, preflightConcurrency)
+	var wg sync.WaitGroup
+	for i := range req.Hosts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+			report.Hosts[i] = preflightHost(&req.Hosts[i], become, interpreter, base)
+		}(i)
+	}
+	wg.Wait()
+
+	report.Passed = true
+	for _, check := range report.Checks {
+		report.Passed = report.Passed && check.Passed
+	}
+	for _, host := range report.Hosts {
+		if !host.Passed {
+			report.FailedHosts++
+			report.Passed = false
+		}
+	}
+
+	logger.Logger.WithFields(map[string]interface{}{
+		"job_id":       job.ID,
+		"host_count":   report.HostCount,
+		"failed_hosts": report.FailedHosts,
+		"passed":       report.Passed,
+	}).Info("作业预检完成")
+	return report
+}
+
+// preflightHost 检查单台主机，提权设置与实际执行时的合并规则一致
+func preflightHost(host *models.Host, settings BecomeSettings, interpreter *ssh.Interpreter, base string) PreflightHostReport {
+	result := PreflightHostReport{HostID: host.ID, HostName: host.Name, IP: host.IP}
+
+	if host.AuthType == "" {
+		result.Checks = []ssh.PreflightCheck{{Name: ssh.PreflightConnection, Message: "主机认证信息不完整"}}
+		return result
+	}
+	become, err := resolveBecome(settings, host)
+	if err != nil {
+		result.Checks = []ssh.PreflightCheck{{Name: ssh.PreflightBecome, Message: err.Error()}}
+		return result
+	}
+
+	result.Checks = ssh.Preflight(host, ssh.PreflightOptions{
+		Become:      become,
+		Interpreter: interpreter,
+		WorkdirBase: base,
+	})
+	result.Passed = true
+	for _, check := range result.Checks {
+		result.Passed = result.Passed && check.Passed
+	}
+	return result
+}
+
+// checkInputFiles 检查作业的输入文件记录存在且本地文件可读
+func (s *ExecutionService) checkInputFiles(job *models.Job) ssh.PreflightCheck {
+	check := ssh.PreflightCheck{Name: PreflightInputFiles}
+	if job.InputFileIDs == "" {
+		check.Passed = true
+		check.Message = "未配置输入文件"
+		return check
+	}
+	var fileIDs []uint
+	if err := json.Unmarshal([]byte(job.InputFileIDs), &fileIDs); err != nil {
+		check.Message = "输入文件配置错误"
+		return check
+	}
+	if len(fileIDs) == 0 {
+		check.Passed = true
+		check.Message = "未配置输入文件"
+		return check
+	}
+
+	var files []models.File
+	if err := s.db.Where("id IN ?", fileIDs).Find(&files).Error; err != nil {
+		check.Message = fmt.Sprintf("获取输入文件失败: %v", err)
+		return check
+	}
+	found := make(map[uint]bool, len(files))
+	var problems []string
+	for _, file := range files {
+		found[file.ID] = true
+		if info, err := os.Stat(file.Path); err != nil || info.IsDir() {
+			problems = append(problems, fmt.Sprintf("%s（ID: %d）本地文件不存在", file.OriginalName, file.ID))
+		}
+	}
+	for _, id := range fileIDs {
+		if !found[id] {
+			problems = append(problems, fmt.Sprintf("文件ID %d 不存在", id))
+		}
+	}
+	if len(problems) > 0 {
+		check.Message = strings.Join(problems, "；")
+		return check
+	}
+	check.Passed = true
+	check.Message = fmt.Sprintf("%d 个输入文件均存在", len(files))
+	return check
+}
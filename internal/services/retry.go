@@ -0,0 +1,170 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go-devops/internal/logger"
+	"go-devops/internal/models"
+	"go-devops/internal/ssh"
+)
+
+// 重试间隔策略
+const (
+	BackoffFixed       = "fixed"       // 固定间隔
+	BackoffLinear      = "linear"      // 间隔随次数线性增长
+	BackoffExponential = "exponential" // 间隔随次数翻倍
+)
+
+// 重试次数和间隔的上限
+const (
+	maxRetryAttempts = 10
+	maxRetryDelay    = 10 * time.Minute
+)
+
+// RetryPolicy 单主机执行失败后的重试策略
+type RetryPolicy struct {
+	MaxAttempts int      // 最大执行次数（含首次），<=1 不重试
+	Backoff     string   // 间隔策略：fixed, linear, exponential
+	Interval    int      // 间隔基数（秒）
+	RetryOn     []string // 需要重试的失败类型，为空时只重试连接失败
+}
+
+// RetryPolicyFromJob 读取作业中的重试策略
+func RetryPolicyFromJob(job *models.Job) RetryPolicy {
+	policy := RetryPolicy{
+		MaxAttempts: job.RetryMaxAttempts,
+		Backoff:     job.RetryBackoff,
+		Interval:    job.RetryInterval,
+	}
+	for _, failureType := range strings.Split(job.RetryOn, ",") {
+		if failureType = strings.TrimSpace(failureType); failureType != "" {
+			policy.RetryOn = append(policy.RetryOn, failureType)
+		}
+	}
+	return policy
+}
+
+// Validate 校验重试策略
+func (p RetryPolicy) Validate() error {
+	if p.MaxAttempts > maxRetryAttempts {
+		return fmt.Errorf("最大执行次数不能超过 %d", maxRetryAttempts)
+	}
+	if p.Interval < 0 {
+		return fmt.Errorf("重试间隔不能为负数")
+	}
+	switch p.Backoff {
+	case "", BackoffFixed, BackoffLinear, BackoffExponential:
+	default:
+		return fmt.Errorf("重试间隔策略 '%s' 无效，应为 fixed、linear 或 exponential", p.Backoff)
+	}
+	for _, failureType := range p.RetryOn {
+		switch failureType {
+		case ssh.FailureConnection, ssh.FailureExitCode, ssh.FailureTimeout:
+		default:
+			return fmt.Errorf("重试的失败类型 '%s' 无效，应为 connection、exit_code 或 timeout", failureType)
+		}
+	}
+	return nil
+}
+
+// Enabled 是否启用重试
+func (p RetryPolicy) Enabled() bool {
+	return p.MaxAttempts > 1
+}
+
+// ShouldRetry 判断该失败类型是否需要重试，取消永远不重试
+func (p RetryPolicy) ShouldRetry(failureType string) bool {
+	if failureType == ssh.FailureCancelled {
+		return false
+	}
+	if len(p.RetryOn) == 0 {
+		return failureType == ssh.FailureConnection
+	}
+	for _, t := range p.RetryOn {
+		if t == failureType {
+			return true
+		}
+	}
+	return false
+}
+
+// Delay 返回第attempt次执行失败后到下一次执行的等待时间
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	base := time.Duration(p.Interval) * time.Second
+	var delay time.Duration
+	switch p.Backoff {
+	case BackoffLinear:
+		delay = base * time.Duration(attempt)
+	case BackoffExponential:
+		delay = base
+		for i := 1; i < attempt && delay < maxRetryDelay; i++ {
+			delay *= 2
+		}
+	default:
+		delay = base
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay
+}
+
+// sleepContext 等待指定时长，ctx结束时提前返回false
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// recordAttempt 记录一次执行尝试的结果
+func (s *ExecutionService) recordAttempt(executionID uint, attempt int, startTime time.Time, output, errorOutput string, err error, timeout time.Duration) {
+	record := models.JobExecutionAttempt{
+		ExecutionID: executionID,
+		Attempt:     attempt,
+		Status:      "completed",
+		Output:      output,
+		Error:       errorOutput,
+		StartTime:   startTime,
+		EndTime:     time.Now(),
+	}
+	if err == nil {
+		zero := 0
+		record.ExitCode = &zero
+	} else {
+		record.FailureType = ssh.FailureTypeOf(err)
+		record.ExitCode = ssh.ExitCodeOf(err)
+		switch record.FailureType {
+		case ssh.FailureTimeout:
+			record.Status = "timeout"
+			if strings.TrimSpace(record.Error) == "" {
+				record.Error = fmt.Sprintf("执行超时（超过 %v），已终止远程进程", timeout)
+			}
+		case ssh.FailureCancelled:
+			record.Status = "cancelled"
+		default:
+			record.Status = "failed"
+			if strings.TrimSpace(record.Error) == "" {
+				record.Error = fmt.Sprintf("执行失败: %v", err)
+			}
+		}
+	}
+
+	if err := s.db.Create(&record).Error; err != nil {
+		logger.Logger.WithFields(map[string]interface{}{
+			"execution_id": executionID,
+			"attempt":      attempt,
+			"error":        err.Error(),
+		}).Error("保存执行尝试记录失败")
+	}
+}
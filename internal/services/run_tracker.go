@@ -0,0 +1,180 @@
+package services
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"go-devops/internal/logger"
+	"go-devops/internal/models"
+	"go-devops/internal/ssh"
+
+	"gorm.io/gorm"
+)
+
+// JobRunHook 作业运行结束后的回调，例如发送通知、触发依赖作业
+type JobRunHook func(run *models.JobRun)
+
+// runTracker 跟踪运行批次内尚未结束的执行，最后一台主机结束时触发汇总
+type runTracker struct {
+	mu        sync.Mutex
+	remaining map[uint]int // 运行批次ID -> 尚未结束的执行数
+	hooks     []JobRunHook
+}
+
+// 全局运行跟踪器，所有执行服务实例共享
+var jobRunTracker = &runTracker{remaining: make(map[uint]int)}
+
+// RegisterJobRunHook 注册作业运行结束回调
+func RegisterJobRunHook(hook JobRunHook) {
+	jobRunTracker.mu.Lock()
+	defer jobRunTracker.mu.Unlock()
+	jobRunTracker.hooks = append(jobRunTracker.hooks, hook)
+}
+
+// add 增加运行批次内待结束的执行数
+func (t *runTracker) add(runID uint, n int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.remaining[runID] += n
+}
+
+// done 标记一个执行结束，返回是否为该批次最后一个
+func (t *runTracker) done(runID uint) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	count, ok := t.remaining[runID]
+	if !ok {
+		return false
+	}
+	if count > 1 {
+		t.remaining[runID] = count - 1
+		return false
+	}
+	delete(t.remaining, runID)
+	return true
+}
+
+// snapshotHooks 返回已注册的回调
+func (t *runTracker) snapshotHooks() []JobRunHook {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]JobRunHook(nil), t.hooks...)
+}
+
+// reportRunExecutionDone 每台主机执行结束后调用：更新运行进度，最后一台结束时确定最终状态并触发回调
+func (s *ExecutionService) reportRunExecutionDone(runID uint) {
+	last := jobRunTracker.done(runID)
+	if err := s.UpdateJobRunStatus(runID); err != nil {
+		logger.Logger.WithFields(map[string]interface{}{
+			"run_id": runID,
+			"error":  err.Error(),
+		}).Error("更新作业运行状态失败")
+	}
+	if last {
+		artifactQuotas.release(artifactQuotaKey(runID, 0))
+		s.finishJobRun(runID)
+	}
+}
+
+// finishJobRun 运行批次结束后触发满足条件的下游作业，并依次执行回调
+func (s *ExecutionService) finishJobRun(runID uint) {
+	var run models.JobRun
+	if err := s.db.Preload("Job").First(&run, runID).Error; err != nil {
+		logger.Logger.WithFields(map[string]interface{}{
+			"run_id": runID,
+			"error":  err.Error(),
+		}).Error("获取作业运行记录失败")
+		return
+	}
+
+	logger.Logger.WithFields(map[string]interface{}{
+		"job_id":        run.JobID,
+		"run_id":        run.ID,
+		"status":        run.Status,
+		"success_count": run.SuccessCount,
+		"failed_count":  run.FailedCount,
+	}).Info("作业运行完成")
+
+	s.triggerDownstreamJobs(&run)
+
+	for _, hook := range jobRunTracker.snapshotHooks() {
+		runJobRunHook(hook, &run)
+	}
+}
+
+// runJobRunHook 执行单个回调，回调出错不影响其他回调
+func runJobRunHook(hook JobRunHook, run *models.JobRun) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Logger.WithFields(map[string]interface{}{
+				"run_id": run.ID,
+				"panic":  r,
+			}).Error("作业运行回调异常")
+		}
+	}()
+	hook(run)
+}
+
+// RegisterJobRunActivity 注册回调，作业运行结束时在触发者名下记录用户活动
+func RegisterJobRunActivity(db *gorm.DB) {
+	activityService := NewActivityService(db)
+	RegisterJobRunHook(func(run *models.JobRun) {
+		jobName := fmt.Sprintf("%d", run.JobID)
+		if run.Job != nil {
+			jobName = run.Job.Name
+		}
+		activityService.LogSystem(run.TriggeredBy, "run_finished", "job", &run.JobID,
+			fmt.Sprintf("作业 '%s' 运行 %d 结束（%s）：状态 %s，成功 %d 台，失败 %d 台",
+				jobName, run.ID, run.TriggerType, run.Status, run.SuccessCount, run.FailedCount))
+	})
+}
+
+// ReconcileInterruptedRuns 服务启动时调用：上次退出时仍未结束的执行已无法继续跟踪，
+// 标记为中断失败并重新汇总其所属运行批次的状态。中断的运行不触发下游作业和回调
+func (s *ExecutionService) ReconcileInterruptedRuns() error {
+	var executions []models.JobExecution
+	if err := s.db.Select("id, run_id").
+		Where("status IN ?", []string{"pending", "running"}).
+		Find(&executions).Error; err != nil {
+		return fmt.Errorf("获取未结束的执行失败: %v", err)
+	}
+
+	if len(executions) > 0 {
+		ids := make([]uint, 0, len(executions))
+		for _, execution := range executions {
+			ids = append(ids, execution.ID)
+		}
+		endTime := time.Now()
+		if err := s.db.Model(&models.JobExecution{}).
+			Where("id IN ? AND status IN ?", ids, []string{"pending", "running"}).
+			Updates(map[string]interface{}{
+				"status":       "failed",
+				"failure_type": ssh.FailureInterrupted,
+				"end_time":     &endTime,
+			}).Error; err != nil {
+			return fmt.Errorf("标记中断的执行失败: %v", err)
+		}
+	}
+
+	// 包括执行都已结束但汇总前服务退出的运行批次
+	var runIDs []uint
+	if err := s.db.Model(&models.JobRun{}).
+		Where("status IN ?", []string{"pending", "running"}).
+		Pluck("id", &runIDs).Error; err != nil {
+		return fmt.Errorf("获取未结束的运行批次失败: %v", err)
+	}
+	for _, runID := range runIDs {
+		if err := s.UpdateJobRunStatus(runID); err != nil {
+			return fmt.Errorf("更新运行批次 %d 状态失败: %v", runID, err)
+		}
+	}
+
+	if len(executions) > 0 || len(runIDs) > 0 {
+		logger.Logger.WithFields(map[string]interface{}{
+			"executions": len(executions),
+			"runs":       len(runIDs),
+		}).Warn("已处理服务重启前未结束的执行和运行批次")
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go-devops/internal/models"
+)
+
+// 参数名只允许字母、数字和下划线，且不能以数字开头（需要作为环境变量名的一部分）
+var parameterNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ValidateScriptParameters 校验脚本参数定义
+func ValidateScriptParameters(defs []models.ScriptParameter) error {
+	seen := make(map[string]bool)
+	for i, def := range defs {
+		if !parameterNamePattern.MatchString(def.Name) {
+			return fmt.Errorf("第%d个参数名 '%s' 无效，只能包含字母、数字和下划线且不能以数字开头", i+1, def.Name)
+		}
+		key := strings.ToUpper(def.Name)
+		if seen[key] {
+			return fmt.Errorf("参数名重复: %s", def.Name)
+		}
+		seen[key] = true
+
+		switch def.Type {
+		case "", "string", "int", "float", "bool":
+		default:
+			return fmt.Errorf("参数 %s 的类型 '%s' 不支持，支持的类型: string, int, float, bool", def.Name, def.Type)
+		}
+
+		if def.Default != "" {
+			if _, err := normalizeParameterValue(def, def.Default); err != nil {
+				return fmt.Errorf("参数 %s 的默认值无效: %v", def.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// EncodeScriptParameters 校验并序列化脚本参数定义
+func EncodeScriptParameters(defs []models.ScriptParameter) (string, error) {
+	if len(defs) == 0 {
+		return "", nil
+	}
+	if err := ValidateScriptParameters(defs); err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(defs)
+	if err != nil {
+		return "", fmt.Errorf("序列化参数定义失败: %v", err)
+	}
+	return string(data), nil
+}
+
+// ParseScriptParameters 解析脚本中存储的参数定义
+func ParseScriptParameters(raw string) ([]models.ScriptParameter, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	var defs []models.ScriptParameter
+	if err := json.Unmarshal([]byte(raw), &defs); err != nil {
+		return nil, fmt.Errorf("脚本参数定义格式错误: %v", err)
+	}
+	return defs, nil
+}
+
+// ParseParameterValues 解析作业中存储的参数值（JSON对象）
+func ParseParameterValues(raw string) (map[string]string, error) {
+	values := make(map[string]string)
+	if strings.TrimSpace(raw) == "" {
+		return values, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader([]byte(raw)))
+	decoder.UseNumber()
+	var parsed map[string]interface{}
+	if err := decoder.Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("参数格式错误，应为JSON对象: %v", err)
+	}
+	for name, value := range parsed {
+		values[name] = parameterValueToString(value)
+	}
+	return values, nil
+}
+
+// MergeParameterValues 合并参数值，后者覆盖前者
+func MergeParameterValues(base map[string]string, overrides map[string]interface{}) map[string]string {
+	merged := make(map[string]string, len(base)+len(overrides))
+	for name, value := range base {
+		merged[name] = value
+	}
+	for name, value := range overrides {
+		merged[name] = parameterValueToString(value)
+	}
+	return merged
+}
+
+// ResolveScriptParameters 按参数定义校验参数值，返回按声明顺序排列的最终参数
+// 未声明参数定义时，所有传入的值都作为字符串参数，并按名称排序
+func ResolveScriptParameters(defs []models.ScriptParameter, values map[string]string) ([]models.ScriptParameterValue, error) {
+	if len(defs) == 0 {
+		names := make([]string, 0, len(values))
+		for name := range values {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			defs = append(defs, models.ScriptParameter{Name: name, Type: "string"})
+		}
+		if err := ValidateScriptParameters(defs); err != nil {
+			return nil, err
+		}
+	}
+
+	declared := make(map[string]bool, len(defs))
+	for _, def := range defs {
+		declared[def.Name] = true
+	}
+	for name := range values {
+		if !declared[name] {
+			return nil, fmt.Errorf("未声明的参数: %s", name)
+		}
+	}
+
+	resolved := make([]models.ScriptParameterValue, 0, len(defs))
+	for _, def := range defs {
+		value, ok := values[def.Name]
+		if !ok || value == "" {
+			value = def.Default
+		}
+		if value == "" {
+			if def.Required {
+				return nil, fmt.Errorf("缺少必填参数: %s", def.Name)
+			}
+		} else {
+			normalized, err := normalizeParameterValue(def, value)
+			if err != nil {
+				return nil, fmt.Errorf("参数 %s 的值无效: %v", def.Name, err)
+			}
+			value = normalized
+		}
+		resolved = append(resolved, models.ScriptParameterValue{Name: def.Name, Value: value})
+	}
+	return resolved, nil
+}
+
+// normalizeParameterValue 按参数类型校验并规范化参数值
+func normalizeParameterValue(def models.ScriptParameter, value string) (string, error) {
+	switch def.Type {
+	case "int":
+		n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("'%s' 不是有效的整数", value)
+		}
+		return strconv.FormatInt(n, 10), nil
+	case "float":
+		f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return "", fmt.Errorf("'%s' 不是有效的数字", value)
+		}
+		return strconv.FormatFloat(f, 'f', -1, 64), nil
+	case "bool":
+		b, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return "", fmt.Errorf("'%s' 不是有效的布尔值", value)
+		}
+		return strconv.FormatBool(b), nil
+	default:
+		return value, nil
+	}
+}
+
+// parameterValueToString 将JSON中的参数值转换为字符串
+func parameterValueToString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
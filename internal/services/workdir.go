@@ -0,0 +1,57 @@
+package services
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+)
+
+// 远程执行工作目录的默认基础路径
+const defaultWorkdirBase = "/tmp/go-devops"
+
+// workdirConfig 远程执行工作目录配置，由ConfigureWorkdir在启动时设置
+var workdirConfig = struct {
+	sync.RWMutex
+	base          string
+	keepOnFailure bool
+}{base: defaultWorkdirBase}
+
+// ConfigureWorkdir 设置远程执行工作目录的基础路径，以及失败时是否保留工作目录
+func ConfigureWorkdir(base string, keepOnFailure bool) error {
+	if base == "" {
+		base = defaultWorkdirBase
+	}
+	base = path.Clean(base)
+	if !path.IsAbs(base) || base == "/" {
+		return fmt.Errorf("工作目录基础路径 '%s' 无效，应为根目录以外的绝对路径", base)
+	}
+	if strings.ContainsAny(base, "\n\r") {
+		return fmt.Errorf("工作目录基础路径不能包含换行符")
+	}
+
+	workdirConfig.Lock()
+	defer workdirConfig.Unlock()
+	workdirConfig.base = base
+	workdirConfig.keepOnFailure = keepOnFailure
+	return nil
+}
+
+// executionWorkdir 返回执行的远程工作目录前缀：首次尝试为 <base>/<执行记录ID>，
+// 重试使用 <base>/<执行记录ID>.<次数>，实际目录由mktemp在前缀后追加随机后缀
+func executionWorkdir(executionID uint, attempt int) (string, bool) {
+	workdirConfig.RLock()
+	defer workdirConfig.RUnlock()
+	name := fmt.Sprintf("%d", executionID)
+	if attempt > 1 {
+		name = fmt.Sprintf("%d.%d", executionID, attempt)
+	}
+	return path.Join(workdirConfig.base, name), workdirConfig.keepOnFailure
+}
+
+// workdirBase 返回远程执行工作目录的基础路径
+func workdirBase() string {
+	workdirConfig.RLock()
+	defer workdirConfig.RUnlock()
+	return workdirConfig.base
+}
@@ -0,0 +1,641 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go-devops/internal/logger"
+	"go-devops/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// 工作流步骤类型
+const (
+	WorkflowStepScript           = "script"            // 执行脚本
+	WorkflowStepFileDistribution = "file_distribution" // 分发文件
+	WorkflowStepRollback         = "rollback"          // 步骤失败后的回滚（仅出现在运行记录中）
+)
+
+// 工作流步骤失败策略
+const (
+	OnFailureAbort    = "abort"    // 中止工作流，跳过剩余步骤
+	OnFailureContinue = "continue" // 继续执行后续步骤
+	OnFailureRollback = "rollback" // 执行回滚脚本后中止工作流
+)
+
+// ErrWorkflowNotFound 工作流不存在
+var ErrWorkflowNotFound = errors.New("工作流不存在")
+
+// WorkflowService 工作流服务，按顺序执行各步骤并在步骤间传递输出
+type WorkflowService struct {
+	db                  *gorm.DB
+	executionService    *ExecutionService
+	distributionService *FileDistributionService
+}
+
+// NewWorkflowService 创建工作流服务实例
+func NewWorkflowService(db *gorm.DB) *WorkflowService {
+	return &WorkflowService{
+		db:                  db,
+		executionService:    NewExecutionService(db),
+		distributionService: NewFileDistributionService(db),
+	}
+}
+
+// BuildWorkflowSteps 校验步骤请求并转换为步骤模型
+// 步骤名需唯一，参数中只能引用已声明的工作流参数和之前步骤的输出
+func (s *WorkflowService) BuildWorkflowSteps(paramDefs []models.ScriptParameter, reqs []models.WorkflowStepRequest) ([]models.WorkflowStep, error) {
+	if len(reqs) == 0 {
+		return nil, errors.New("工作流至少需要一个步骤")
+	}
+
+	declared := make(map[string]bool, len(paramDefs))
+	for _, def := range paramDefs {
+		declared[def.Name] = true
+	}
+	// 引用检查：工作流声明了参数定义时只能引用已声明的参数
+	checkRefs := func(stepName, text string, earlier map[string]bool) error {
+		refs, err := workflowRefs(text)
+		if err != nil {
+			return fmt.Errorf("步骤 '%s' 的%s", stepName, err.Error())
+		}
+		for _, ref := range refs {
+			if ref.scope == "params" && len(paramDefs) > 0 && !declared[ref.name] {
+				return fmt.Errorf("步骤 '%s' 引用了未声明的工作流参数 '%s'", stepName, ref.name)
+			}
+			if ref.scope == "steps" && !earlier[ref.step] {
+				return fmt.Errorf("步骤 '%s' 只能引用之前步骤的输出，'%s' 不是之前的步骤", stepName, ref.step)
+			}
+		}
+		return nil
+	}
+
+	earlier := make(map[string]bool, len(reqs))
+	steps := make([]models.WorkflowStep, 0, len(reqs))
+	for i, req := range reqs {
+		name := strings.TrimSpace(req.Name)
+		if !workflowStepNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("步骤名 '%s' 无效，只能包含字母、数字、下划线和中划线", req.Name)
+		}
+		if earlier[name] {
+			return nil, fmt.Errorf("步骤名 '%s' 重复", name)
+		}
+		if len(req.HostIDs) == 0 {
+			return nil, fmt.Errorf("步骤 '%s' 未配置执行主机", name)
+		}
+		if err := s.checkHostsExist(req.HostIDs); err != nil {
+			return nil, fmt.Errorf("步骤 '%s' %s", name, err.Error())
+		}
+
+		step := models.WorkflowStep{
+			StepOrder: i + 1,
+			Name:      name,
+			Type:      req.Type,
+			Timeout:   req.Timeout,
+			OnFailure: req.OnFailure,
+		}
+		if step.OnFailure == "" {
+			step.OnFailure = OnFailureAbort
+		}
+
+		switch req.Type {
+		case WorkflowStepScript:
+			if req.ScriptID == 0 {
+				return nil, fmt.Errorf("脚本步骤 '%s' 未指定脚本", name)
+			}
+			if err := s.db.First(&models.Script{}, req.ScriptID).Error; err != nil {
+				return nil, fmt.Errorf("步骤 '%s' 的脚本不存在", name)
+			}
+			scriptID := req.ScriptID
+			step.ScriptID = &scriptID
+		case WorkflowStepFileDistribution:
+			if req.FileID == 0 || strings.TrimSpace(req.TargetPath) == "" {
+				return nil, fmt.Errorf("文件分发步骤 '%s' 需要指定文件和目标路径", name)
+			}
+			if err := s.db.First(&models.File{}, req.FileID).Error; err != nil {
+				return nil, fmt.Errorf("步骤 '%s' 的文件不存在", name)
+			}
+			if err := checkRefs(name, req.TargetPath, earlier); err != nil {
+				return nil, err
+			}
+			fileID := req.FileID
+			step.FileID = &fileID
+			step.TargetPath = req.TargetPath
+		default:
+			return nil, fmt.Errorf("步骤 '%s' 的类型 '%s' 无效，应为 script 或 file_distribution", name, req.Type)
+		}
+
+		switch step.OnFailure {
+		case OnFailureAbort, OnFailureContinue:
+		case OnFailureRollback:
+			if req.RollbackScriptID == 0 {
+				return nil, fmt.Errorf("步骤 '%s' 的失败策略为rollback，需要指定回滚脚本", name)
+			}
+			if err := s.db.First(&models.Script{}, req.RollbackScriptID).Error; err != nil {
+				return nil, fmt.Errorf("步骤 '%s' 的回滚脚本不存在", name)
+			}
+			rollbackID := req.RollbackScriptID
+			step.RollbackScriptID = &rollbackID
+		default:
+			return nil, fmt.Errorf("步骤 '%s' 的失败策略 '%s' 无效，应为 abort、continue 或 rollback", name, req.OnFailure)
+		}
+
+		for _, value := range req.Parameters {
+			if err := checkRefs(name, parameterValueToString(value), earlier); err != nil {
+				return nil, err
+			}
+		}
+		if len(req.Parameters) > 0 {
+			data, err := json.Marshal(req.Parameters)
+			if err != nil {
+				return nil, fmt.Errorf("步骤 '%s' 的参数格式错误", name)
+			}
+			step.Parameters = string(data)
+		}
+		hostIDs, _ := json.Marshal(req.HostIDs)
+		step.HostIDs = string(hostIDs)
+
+		earlier[name] = true
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// checkHostsExist 检查主机是否都存在
+func (s *WorkflowService) checkHostsExist(hostIDs []uint) error {
+	var count int64
+	if err := s.db.Model(&models.Host{}).Where("id IN ?", hostIDs).Count(&count).Error; err != nil {
+		return fmt.Errorf("获取主机信息失败: %v", err)
+	}
+	if int(count) != len(uniqueIDs(hostIDs)) {
+		return errors.New("部分主机不存在")
+	}
+	return nil
+}
+
+// uniqueIDs 去除重复ID
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	result := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+// LoadWorkflow 加载工作流及按顺序排列的步骤
+func (s *WorkflowService) LoadWorkflow(id uint) (*models.Workflow, error) {
+	var workflow models.Workflow
+	err := s.db.Preload("Steps", func(db *gorm.DB) *gorm.DB {
+		return db.Order("step_order ASC")
+	}).Preload("Steps.Script").Preload("Steps.File").Preload("Steps.RollbackScript").
+		Preload("User").First(&workflow, id).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrWorkflowNotFound
+		}
+		return nil, fmt.Errorf("获取工作流信息失败: %v", err)
+	}
+	return &workflow, nil
+}
+
+// StartWorkflowRun 校验运行参数，创建运行记录和各步骤的运行记录后异步执行
+func (s *WorkflowService) StartWorkflowRun(workflow *models.Workflow, overrides map[string]interface{}, userID uint) (*models.WorkflowRun, error) {
+	if len(workflow.Steps) == 0 {
+		return nil, errors.New("工作流没有步骤")
+	}
+
+	defs, err := ParseScriptParameters(workflow.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	resolved, err := ResolveScriptParameters(defs, MergeParameterValues(nil, overrides))
+	if err != nil {
+		return nil, err
+	}
+	params := make(map[string]string, len(resolved))
+	for _, p := range resolved {
+		params[p.Name] = p.Value
+	}
+	paramsJSON, _ := json.Marshal(params)
+
+	if err := s.checkStepApproval(workflowScriptHostIDs(workflow), userID); err != nil {
+		return nil, err
+	}
+
+	run := &models.WorkflowRun{
+		WorkflowID:  workflow.ID,
+		Status:      "running",
+		Parameters:  string(paramsJSON),
+		TriggeredBy: userID,
+		StartTime:   time.Now(),
+	}
+	for _, step := range workflow.Steps {
+		run.Steps = append(run.Steps, models.WorkflowStepRun{
+			StepID:    step.ID,
+			StepOrder: step.StepOrder,
+			Name:      step.Name,
+			Type:      step.Type,
+			Status:    "pending",
+		})
+	}
+	if err := s.db.Create(run).Error; err != nil {
+		return nil, fmt.Errorf("创建工作流运行记录失败: %v", err)
+	}
+
+	logger.Logger.WithFields(map[string]interface{}{
+		"workflow_id": workflow.ID,
+		"run_id":      run.ID,
+		"step_count":  len(workflow.Steps),
+	}).Info("工作流运行已启动")
+
+	go s.runWorkflow(workflow, run, params)
+	return run, nil
+}
+
+// runWorkflow 按顺序执行各步骤，根据失败策略决定是否继续
+func (s *WorkflowService) runWorkflow(workflow *models.Workflow, run *models.WorkflowRun, params map[string]string) {
+	outputs := make(map[string]map[string]string, len(workflow.Steps))
+	status := "completed"
+	runError := ""
+	aborted := false
+
+	for i := range workflow.Steps {
+		step := &workflow.Steps[i]
+		stepRun := &run.Steps[i]
+
+		if aborted {
+			s.finishStepRun(stepRun, "skipped", nil, "前序步骤失败，已跳过")
+			continue
+		}
+
+		s.db.Model(run).Update("current_step", step.StepOrder)
+		stepOutputs, err := s.runStep(workflow, run, step, stepRun, params, outputs)
+		outputs[step.Name] = stepOutputs
+		if err == nil {
+			continue
+		}
+
+		logger.Logger.WithFields(map[string]interface{}{
+			"workflow_id": workflow.ID,
+			"run_id":      run.ID,
+			"step":        step.Name,
+			"on_failure":  step.OnFailure,
+			"error":       err.Error(),
+		}).Warn("工作流步骤失败")
+
+		switch step.OnFailure {
+		case OnFailureContinue:
+			status = "partial_failed"
+		case OnFailureRollback:
+			runError = fmt.Sprintf("步骤 '%s' 失败: %v", step.Name, err)
+			if rollbackErr := s.runRollback(workflow, run, step, stepRun); rollbackErr != nil {
+				runError = fmt.Sprintf("%s；回滚失败: %v", runError, rollbackErr)
+			}
+			status, aborted = "failed", true
+		default:
+			runError = fmt.Sprintf("步骤 '%s' 失败: %v", step.Name, err)
+			status, aborted = "failed", true
+		}
+	}
+
+	endTime := time.Now()
+	s.db.Model(run).Updates(map[string]interface{}{
+		"status":   status,
+		"error":    runError,
+		"end_time": &endTime,
+	})
+
+	logger.Logger.WithFields(map[string]interface{}{
+		"workflow_id": workflow.ID,
+		"run_id":      run.ID,
+		"status":      status,
+	}).Info("工作流运行结束")
+}
+
+// runStep 执行单个步骤，返回步骤输出；步骤失败时同样返回已有的输出
+func (s *WorkflowService) runStep(workflow *models.Workflow, run *models.WorkflowRun, step *models.WorkflowStep, stepRun *models.WorkflowStepRun, params map[string]string, outputs map[string]map[string]string) (map[string]string, error) {
+	startTime := time.Now()
+	stepRun.StartTime = &startTime
+	s.db.Model(stepRun).Updates(map[string]interface{}{"status": "running", "start_time": &startTime})
+
+	var stepOutputs map[string]string
+	var err error
+	switch step.Type {
+	case WorkflowStepScript:
+		stepOutputs, err = s.runScriptStep(workflow, run, step, stepRun, params, outputs)
+	case WorkflowStepFileDistribution:
+		stepOutputs, err = s.runFileStep(run, step, stepRun, params, outputs)
+	default:
+		err = fmt.Errorf("未知的步骤类型: %s", step.Type)
+	}
+
+	if err != nil {
+		s.finishStepRun(stepRun, "failed", stepOutputs, err.Error())
+		return stepOutputs, err
+	}
+	s.finishStepRun(stepRun, "completed", stepOutputs, "")
+	return stepOutputs, nil
+}
+
+// runScriptStep 替换参数中的引用后在步骤的主机上执行脚本
+func (s *WorkflowService) runScriptStep(workflow *models.Workflow, run *models.WorkflowRun, step *models.WorkflowStep, stepRun *models.WorkflowStepRun, params map[string]string, outputs map[string]map[string]string) (map[string]string, error) {
+	if step.Script == nil {
+		return nil, errors.New("步骤的脚本不存在")
+	}
+
+	// 替换引用并按脚本参数定义校验
+	rendered, err := renderStepParameters(step.Parameters, params, outputs)
+	if err != nil {
+		return nil, err
+	}
+	if data, err := json.Marshal(rendered); err == nil {
+		s.db.Model(stepRun).Update("parameters", string(data))
+	}
+	defs, err := ParseScriptParameters(step.Script.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	values, err := ResolveScriptParameters(defs, rendered)
+	if err != nil {
+		return nil, err
+	}
+
+	hosts, err := s.loadStepHosts(step)
+	if err != nil {
+		return nil, err
+	}
+
+	targets, err := s.createStepExecutions(workflow, run, stepRun, step.Script, hosts)
+	if err != nil {
+		return nil, err
+	}
+	s.executionService.dispatchExecutions(targets, step.Script, ExecutionOptions{
+		Timeout:    step.Timeout,
+		Parameters: values,
+	}, DispatchOptions{})
+
+	return scriptStepOutputs(targets)
+}
+
+// runFileStep 将文件分发到步骤的主机，目标路径中可以使用引用
+func (s *WorkflowService) runFileStep(run *models.WorkflowRun, step *models.WorkflowStep, stepRun *models.WorkflowStepRun, params map[string]string, outputs map[string]map[string]string) (map[string]string, error) {
+	if step.File == nil {
+		return nil, errors.New("步骤的文件不存在")
+	}
+	targetPath, err := renderWorkflowTemplate(step.TargetPath, params, outputs)
+	if err != nil {
+		return nil, err
+	}
+
+	hosts, err := s.loadStepHosts(step)
+	if err != nil {
+		return nil, err
+	}
+	hostIDs := make([]uint, 0, len(hosts))
+	for _, host := range hosts {
+		hostIDs = append(hostIDs, host.ID)
+	}
+
+	distribution, err := s.distributionService.CreateDistribution(step.File, hostIDs, targetPath, run.TriggeredBy)
+	if err != nil {
+		return nil, fmt.Errorf("创建分发任务失败: %v", err)
+	}
+	stepRun.DistributionID = &distribution.ID
+	s.db.Model(stepRun).Update("distribution_id", distribution.ID)
+
+	status := s.distributionService.ExecuteDistribution(distribution, step.File, hosts)
+	stepOutputs := map[string]string{
+		"status":          status,
+		"distribution_id": fmt.Sprintf("%d", distribution.ID),
+		"target_path":     targetPath,
+	}
+	if status != "completed" {
+		return stepOutputs, fmt.Errorf("文件分发未全部成功（状态: %s）", status)
+	}
+	return stepOutputs, nil
+}
+
+// runRollback 在失败步骤的主机上执行回滚脚本，回滚记录为一条类型为rollback的步骤运行
+func (s *WorkflowService) runRollback(workflow *models.Workflow, run *models.WorkflowRun, step *models.WorkflowStep, failed *models.WorkflowStepRun) error {
+	startTime := time.Now()
+	rollbackRun := &models.WorkflowStepRun{
+		WorkflowRunID: run.ID,
+		StepID:        step.ID,
+		StepOrder:     step.StepOrder,
+		Name:          step.Name,
+		Type:          WorkflowStepRollback,
+		Status:        "running",
+		StartTime:     &startTime,
+	}
+	if err := s.db.Create(rollbackRun).Error; err != nil {
+		return fmt.Errorf("创建回滚记录失败: %v", err)
+	}
+
+	err := func() error {
+		if step.RollbackScript == nil {
+			return errors.New("回滚脚本不存在")
+		}
+		// 回滚脚本只接收其声明过的参数，值取自失败步骤替换引用后的参数
+		defs, err := ParseScriptParameters(step.RollbackScript.Parameters)
+		if err != nil {
+			return err
+		}
+		stepValues, _ := ParseParameterValues(failed.Parameters)
+		values := make(map[string]string)
+		for _, def := range defs {
+			if value, ok := stepValues[def.Name]; ok {
+				values[def.Name] = value
+			}
+		}
+		params, err := ResolveScriptParameters(defs, values)
+		if err != nil {
+			return err
+		}
+
+		hosts, err := s.loadStepHosts(step)
+		if err != nil {
+			return err
+		}
+		targets, err := s.createStepExecutions(workflow, run, rollbackRun, step.RollbackScript, hosts)
+		if err != nil {
+			return err
+		}
+		s.executionService.dispatchExecutions(targets, step.RollbackScript, ExecutionOptions{
+			Timeout:    step.Timeout,
+			Parameters: params,
+		}, DispatchOptions{})
+
+		_, err = scriptStepOutputs(targets)
+		return err
+	}()
+
+	if err != nil {
+		s.finishStepRun(rollbackRun, "failed", nil, err.Error())
+		return err
+	}
+	s.finishStepRun(rollbackRun, "completed", nil, "")
+	return nil
+}
+
+// renderStepParameters 替换步骤参数中的引用
+func renderStepParameters(raw string, params map[string]string, outputs map[string]map[string]string) (map[string]string, error) {
+	values, err := ParseParameterValues(raw)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range values {
+		rendered, err := renderWorkflowTemplate(value, params, outputs)
+		if err != nil {
+			return nil, fmt.Errorf("参数 %s: %v", name, err)
+		}
+		values[name] = rendered
+	}
+	return values, nil
+}
+
+// loadStepHosts 加载步骤的目标主机
+func (s *WorkflowService) loadStepHosts(step *models.WorkflowStep) ([]models.Host, error) {
+	var hostIDs []uint
+	if err := json.Unmarshal([]byte(step.HostIDs), &hostIDs); err != nil {
+		return nil, errors.New("主机配置错误")
+	}
+	hostIDs = uniqueIDs(hostIDs)
+	if len(hostIDs) == 0 {
+		return nil, errors.New("未配置执行主机")
+	}
+
+	var hosts []models.Host
+	if err := s.db.Where("id IN ?", hostIDs).Find(&hosts).Error; err != nil {
+		return nil, fmt.Errorf("获取主机信息失败: %v", err)
+	}
+	if len(hosts) != len(hostIDs) {
+		return nil, errors.New("部分主机不存在")
+	}
+	return hosts, nil
+}
+
+// workflowScriptHostIDs 返回脚本步骤（含回滚脚本）的目标主机ID
+func workflowScriptHostIDs(workflow *models.Workflow) []uint {
+	var ids []uint
+	for _, step := range workflow.Steps {
+		if step.Type != WorkflowStepScript {
+			continue
+		}
+		var hostIDs []uint
+		if err := json.Unmarshal([]byte(step.HostIDs), &hostIDs); err == nil {
+			ids = append(ids, hostIDs...)
+		}
+	}
+	return uniqueIDs(ids)
+}
+
+// checkStepApproval 工作流没有审批流程：脚本步骤的主机位于要求审批的环境中时，
+// 与快速执行一致只允许管理员运行。启动运行时检查全部步骤，执行每个步骤前再次检查
+func (s *WorkflowService) checkStepApproval(hostIDs []uint, userID uint) error {
+	reasons, err := s.executionService.approvalReasons(nil, hostIDs)
+	if err != nil {
+		return err
+	}
+	if len(reasons) == 0 {
+		return nil
+	}
+	var user models.User
+	if err := s.db.Select("id", "role").First(&user, userID).Error; err == nil && user.Role == "admin" {
+		return nil
+	}
+	return fmt.Errorf("%w；工作流不支持执行审批，只有管理员可以运行", &ApprovalRequiredError{Reasons: reasons})
+}
+
+// createStepExecutions 为步骤的每台主机创建执行记录
+func (s *WorkflowService) createStepExecutions(workflow *models.Workflow, run *models.WorkflowRun, stepRun *models.WorkflowStepRun, script *models.Script, hosts []models.Host) ([]ExecutionTarget, error) {
+	if err := s.checkStepApproval(hostIDsOf(hosts), run.TriggeredBy); err != nil {
+		return nil, err
+	}
+
+	targets := make([]ExecutionTarget, 0, len(hosts))
+	for _, host := range hosts {
+		stepRunID := stepRun.ID
+		execution := &models.JobExecution{
+			WorkflowStepRunID: &stepRunID,
+			HostID:            host.ID,
+			Status:            "pending",
+			ScriptContent:     script.Content,
+			ScriptType:        script.Type,
+			ExecutedBy:        run.TriggeredBy,
+			JobName:           workflow.Name,
+			ScriptName:        script.Name,
+		}
+		if err := s.db.Create(execution).Error; err != nil {
+			return nil, fmt.Errorf("创建执行记录失败: %v", err)
+		}
+		targets = append(targets, ExecutionTarget{Execution: execution, Host: host})
+	}
+	return targets, nil
+}
+
+// scriptStepOutputs 汇总脚本步骤的输出：status、exit_code、stdout（取第一台主机）、hosts_succeeded、hosts_failed，
+// 以及第一台主机的结构化结果
+func scriptStepOutputs(targets []ExecutionTarget) (map[string]string, error) {
+	succeeded, failed := 0, 0
+	for _, target := range targets {
+		if target.Execution.Status == "completed" {
+			succeeded++
+		} else {
+			failed++
+		}
+	}
+
+	first := targets[0].Execution
+	outputs := map[string]string{
+		"status":          first.Status,
+		"stdout":          strings.TrimSpace(first.Output),
+		"exit_code":       "",
+		"hosts_succeeded": fmt.Sprintf("%d", succeeded),
+		"hosts_failed":    fmt.Sprintf("%d", failed),
+	}
+	if first.ExitCode != nil {
+		outputs["exit_code"] = fmt.Sprintf("%d", *first.ExitCode)
+	}
+	// 第一台主机的结构化结果作为额外输出，不覆盖上面的内置输出
+	if first.Results != "" {
+		var results map[string]interface{}
+		if err := json.Unmarshal([]byte(first.Results), &results); err == nil {
+			for name, value := range results {
+				if _, builtin := outputs[name]; !builtin && resultNamePattern.MatchString(name) {
+					outputs[name] = resultString(value)
+				}
+			}
+		}
+	}
+	if failed > 0 {
+		outputs["status"] = "failed"
+		return outputs, fmt.Errorf("%d/%d 台主机执行失败", failed, len(targets))
+	}
+	return outputs, nil
+}
+
+// finishStepRun 记录步骤的最终状态和输出
+func (s *WorkflowService) finishStepRun(stepRun *models.WorkflowStepRun, status string, outputs map[string]string, errorMsg string) {
+	updates := map[string]interface{}{
+		"status": status,
+		"error":  errorMsg,
+	}
+	if outputs != nil {
+		if data, err := json.Marshal(outputs); err == nil {
+			updates["outputs"] = string(data)
+		}
+	}
+	if stepRun.StartTime != nil {
+		endTime := time.Now()
+		updates["end_time"] = &endTime
+	}
+	s.db.Model(stepRun).Updates(updates)
+}
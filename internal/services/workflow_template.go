@@ -0,0 +1,81 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// 工作流参数中的引用：${params.名称} 引用工作流参数，${steps.步骤名.输出名} 引用之前步骤的输出
+var workflowRefPattern = regexp.MustCompile(`\$\{\s*([a-zA-Z0-9_.-]+)\s*\}`)
+
+// 步骤名格式，需能出现在引用表达式中
+var workflowStepNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// workflowRef 解析后的引用
+type workflowRef struct {
+	scope string // params 或 steps
+	step  string // 引用的步骤名，scope为steps时有效
+	name  string // 参数名或输出名
+}
+
+// parseWorkflowRef 解析引用表达式（不含 ${}）
+func parseWorkflowRef(expr string) (workflowRef, error) {
+	parts := strings.Split(expr, ".")
+	switch {
+	case parts[0] == "params" && len(parts) == 2 && parts[1] != "":
+		return workflowRef{scope: "params", name: parts[1]}, nil
+	case parts[0] == "steps" && len(parts) == 3 && parts[1] != "" && parts[2] != "":
+		return workflowRef{scope: "steps", step: parts[1], name: parts[2]}, nil
+	}
+	return workflowRef{}, fmt.Errorf("引用 '${%s}' 无效，应为 ${params.名称} 或 ${steps.步骤名.输出名}", expr)
+}
+
+// workflowRefs 返回文本中的所有引用
+func workflowRefs(text string) ([]workflowRef, error) {
+	var refs []workflowRef
+	for _, match := range workflowRefPattern.FindAllStringSubmatch(text, -1) {
+		ref, err := parseWorkflowRef(match[1])
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, ref)
+	}
+	return refs, nil
+}
+
+// renderWorkflowTemplate 将文本中的引用替换为工作流参数或步骤输出，引用不存在时返回错误
+func renderWorkflowTemplate(text string, params map[string]string, outputs map[string]map[string]string) (string, error) {
+	var renderErr error
+	rendered := workflowRefPattern.ReplaceAllStringFunc(text, func(match string) string {
+		if renderErr != nil {
+			return match
+		}
+		ref, err := parseWorkflowRef(workflowRefPattern.FindStringSubmatch(match)[1])
+		if err != nil {
+			renderErr = err
+			return match
+		}
+		if ref.scope == "params" {
+			value, ok := params[ref.name]
+			if !ok {
+				renderErr = fmt.Errorf("工作流参数 '%s' 不存在", ref.name)
+			}
+			return value
+		}
+		stepOutputs, ok := outputs[ref.step]
+		if !ok {
+			renderErr = fmt.Errorf("步骤 '%s' 没有输出", ref.step)
+			return match
+		}
+		value, ok := stepOutputs[ref.name]
+		if !ok {
+			renderErr = fmt.Errorf("步骤 '%s' 没有输出 '%s'", ref.step, ref.name)
+		}
+		return value
+	})
+	if renderErr != nil {
+		return "", renderErr
+	}
+	return rendered, nil
+}
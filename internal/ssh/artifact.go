@@ -0,0 +1,107 @@
+package ssh
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"go-devops/internal/logger"
+)
+
+// 单次执行最多收集的产物文件数
+const maxArtifactFiles = 200
+
+// ArtifactRequest 执行结束后从工作目录收集产物文件
+type ArtifactRequest struct {
+	Patterns []string                                      // 相对工作目录的glob模式，如 reports/*.html
+	LocalDir string                                        // 本地保存目录，保留相对路径结构
+	Reserve  func(size int64) error                        // 下载前申请大小配额，返回错误时跳过该文件；下载失败时以负数归还
+	Done     func(artifacts []Artifact, problems []string) // 收集结束后回调，problems为跳过或失败的原因
+}
+
+// Artifact 已下载到本地的产物文件
+type Artifact struct {
+	Name      string // 相对工作目录的路径
+	LocalPath string // 本地路径
+	Size      int64  // 文件大小（字节）
+}
+
+// ValidateArtifactPattern 校验产物模式：必须是工作目录内的相对路径
+func ValidateArtifactPattern(pattern string) error {
+	if pattern == "" || path.IsAbs(pattern) {
+		return fmt.Errorf("产物模式 '%s' 无效，应为相对工作目录的路径", pattern)
+	}
+	for _, segment := range strings.Split(pattern, "/") {
+		if segment == ".." {
+			return fmt.Errorf("产物模式 '%s' 不能包含 ..", pattern)
+		}
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("产物模式 '%s' 格式错误: %v", pattern, err)
+	}
+	return nil
+}
+
+// collectArtifacts 按模式匹配工作目录中的普通文件（不跟随符号链接）并下载到本地
+func (c *SSHClient) collectArtifacts(workdir string, req *ArtifactRequest) ([]Artifact, []string) {
+	var artifacts []Artifact
+	var problems []string
+
+	sftpClient, release, err := c.sftpClient()
+	if err != nil {
+		return nil, []string{fmt.Sprintf("获取SFTP客户端失败: %v", err)}
+	}
+	defer release()
+
+	seen := make(map[string]bool)
+	for _, pattern := range req.Patterns {
+		matches, err := sftpClient.Glob(path.Join(workdir, pattern))
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("匹配 %s 失败: %v", pattern, err))
+			continue
+		}
+		if len(matches) == 0 {
+			problems = append(problems, fmt.Sprintf("%s 没有匹配的文件", pattern))
+			continue
+		}
+
+		for _, remotePath := range matches {
+			name := strings.TrimPrefix(remotePath, workdir+"/")
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+
+			info, err := sftpClient.Lstat(remotePath)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", name, err))
+				continue
+			}
+			if !info.Mode().IsRegular() {
+				continue
+			}
+			if len(artifacts) >= maxArtifactFiles {
+				problems = append(problems, fmt.Sprintf("产物文件超过 %d 个，其余文件未收集", maxArtifactFiles))
+				return artifacts, problems
+			}
+			if err := req.Reserve(info.Size()); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", name, err))
+				continue
+			}
+
+			localPath := filepath.Join(req.LocalDir, filepath.FromSlash(name))
+			if err := c.DownloadFile(remotePath, localPath); err != nil {
+				os.Remove(localPath)
+				req.Reserve(-info.Size())
+				problems = append(problems, fmt.Sprintf("%s: %v", name, err))
+				continue
+			}
+			artifacts = append(artifacts, Artifact{Name: name, LocalPath: localPath, Size: info.Size()})
+		}
+	}
+
+	logger.Infof("从主机 %s 收集产物 %d 个，跳过 %d 项", c.host.IP, len(artifacts), len(problems))
+	return artifacts, problems
+}
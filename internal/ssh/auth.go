@@ -0,0 +1,305 @@
+package ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go-devops/internal/logger"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// SSH认证方式
+const (
+	AuthTypePassword            = "password"             // 密码
+	AuthTypeKey                 = "key"                  // 私钥
+	AuthTypeAgent               = "agent"                // 服务器上的 ssh-agent
+	AuthTypeCertificate         = "certificate"          // OpenSSH 用户证书
+	AuthTypeKeyboardInteractive = "keyboard-interactive" // 键盘交互，以密码应答提示
+)
+
+// 签发用户证书的默认有效期
+const defaultCertValidity = 5 * time.Minute
+
+// 签发证书的生效时间提前量，容忍与目标主机的时钟偏差
+const certClockSkew = time.Minute
+
+// AuthConfig 服务器侧的SSH认证配置
+type AuthConfig struct {
+	AgentSocket     string        // ssh-agent 套接字路径，为空时使用环境变量 SSH_AUTH_SOCK
+	CAKeyFile       string        // 签发用户证书的CA私钥文件，为空时certificate认证只能使用主机上配置的证书
+	CAKeyPassphrase string        // CA私钥的密码短语
+	CertValidity    time.Duration // 签发证书的有效期，为0时使用默认值
+}
+
+var authSettings = struct {
+	sync.RWMutex
+	agentSocket  string
+	ca           ssh.Signer
+	certValidity time.Duration
+}{certValidity: defaultCertValidity}
+
+// ConfigureAuth 设置ssh-agent套接字和用户证书CA，CA私钥无法读取或解析时返回错误
+func ConfigureAuth(cfg AuthConfig) error {
+	var ca ssh.Signer
+	if cfg.CAKeyFile != "" {
+		data, err := os.ReadFile(cfg.CAKeyFile)
+		if err != nil {
+			return fmt.Errorf("读取CA私钥失败: %v", err)
+		}
+		if cfg.CAKeyPassphrase != "" {
+			ca, err = ssh.ParsePrivateKeyWithPassphrase(data, []byte(cfg.CAKeyPassphrase))
+		} else {
+			ca, err = ssh.ParsePrivateKey(data)
+		}
+		if err != nil {
+			return fmt.Errorf("解析CA私钥失败: %v", err)
+		}
+		logger.Infof("已加载用户证书CA: %s", ssh.FingerprintSHA256(ca.PublicKey()))
+	}
+	if cfg.CertValidity <= 0 {
+		cfg.CertValidity = defaultCertValidity
+	}
+
+	authSettings.Lock()
+	defer authSettings.Unlock()
+	authSettings.agentSocket = cfg.AgentSocket
+	authSettings.ca = ca
+	authSettings.certValidity = cfg.CertValidity
+	return nil
+}
+
+// AuthTypes 返回支持的认证方式
+func AuthTypes() []string {
+	return []string{AuthTypePassword, AuthTypeKey, AuthTypeAgent, AuthTypeCertificate, AuthTypeKeyboardInteractive}
+}
+
+// ValidateAuth 检查认证方式受支持且所需的凭据完整
+func ValidateAuth(authType, password, privateKey, certificate string) error {
+	switch authType {
+	case AuthTypePassword:
+		if password == "" {
+			return fmt.Errorf("密码认证需要提供密码")
+		}
+	case AuthTypeKey:
+		if privateKey == "" {
+			return fmt.Errorf("密钥认证需要提供私钥")
+		}
+	case AuthTypeAgent:
+	case AuthTypeCertificate:
+		if certificate != "" {
+			if privateKey == "" {
+				return fmt.Errorf("证书认证需要提供证书对应的私钥")
+			}
+			if _, err := parseUserCertificate(certificate); err != nil {
+				return err
+			}
+		} else if caSigner() == nil {
+			return fmt.Errorf("证书认证需要提供证书，或在配置中设置签发证书的CA私钥（ssh.ca_key_file）")
+		}
+	case AuthTypeKeyboardInteractive:
+		if password == "" {
+			return fmt.Errorf("键盘交互认证需要提供密码")
+		}
+	default:
+		return fmt.Errorf("不支持的认证类型: %s，支持的类型: %s", authType, strings.Join(AuthTypes(), ", "))
+	}
+	return nil
+}
+
+func caSigner() ssh.Signer {
+	authSettings.RLock()
+	defer authSettings.RUnlock()
+	return authSettings.ca
+}
+
+// authMethods 按端点的认证方式构建认证方法，返回的cleanup在握手完成后调用
+func authMethods(e endpoint) ([]ssh.AuthMethod, func(), error) {
+	noop := func() {}
+	if e.credentialErr != "" {
+		return nil, nil, fmt.Errorf("%s 的凭据不可用，请重新设置: %s", e.hostKey.label, e.credentialErr)
+	}
+	if err := ValidateAuth(e.AuthType, e.Password, e.PrivateKey, e.Certificate); err != nil {
+		return nil, nil, err
+	}
+
+	switch e.AuthType {
+	case AuthTypePassword:
+		logger.Infof("使用密码认证连接%s: %s@%s", e.hostKey.kindName(), e.Username, e.IP)
+		return []ssh.AuthMethod{ssh.Password(e.Password)}, noop, nil
+
+	case AuthTypeKey:
+		signer, err := parsePrivateKey(e.PrivateKey, e.Passphrase)
+		if err != nil {
+			return nil, nil, err
+		}
+		logger.Infof("使用密钥认证连接%s: %s@%s", e.hostKey.kindName(), e.Username, e.IP)
+		return []ssh.AuthMethod{ssh.PublicKeys(signer)}, noop, nil
+
+	case AuthTypeAgent:
+		authSettings.RLock()
+		socket := authSettings.agentSocket
+		authSettings.RUnlock()
+		if socket == "" {
+			socket = os.Getenv("SSH_AUTH_SOCK")
+		}
+		if socket == "" {
+			return nil, nil, fmt.Errorf("agent认证需要配置ssh-agent套接字（ssh.agent_socket 或环境变量 SSH_AUTH_SOCK）")
+		}
+		conn, err := net.Dial("unix", socket)
+		if err != nil {
+			return nil, nil, fmt.Errorf("连接ssh-agent失败: %v", err)
+		}
+		logger.Infof("使用ssh-agent认证连接%s: %s@%s", e.hostKey.kindName(), e.Username, e.IP)
+		return []ssh.AuthMethod{ssh.PublicKeysCallback(agent.NewClient(conn).Signers)}, func() { conn.Close() }, nil
+
+	case AuthTypeCertificate:
+		signer, err := certificateSigner(e)
+		if err != nil {
+			return nil, nil, err
+		}
+		logger.Infof("使用证书认证连接%s: %s@%s", e.hostKey.kindName(), e.Username, e.IP)
+		return []ssh.AuthMethod{ssh.PublicKeys(signer)}, noop, nil
+
+	case AuthTypeKeyboardInteractive:
+		logger.Infof("使用键盘交互认证连接%s: %s@%s", e.hostKey.kindName(), e.Username, e.IP)
+		return []ssh.AuthMethod{
+			ssh.KeyboardInteractive(keyboardInteractiveChallenge(e.Password)),
+			// 部分服务端同时接受密码认证
+			ssh.Password(e.Password),
+		}, noop, nil
+	}
+	return nil, nil, fmt.Errorf("不支持的认证类型: %s", e.AuthType)
+}
+
+// parsePrivateKey 解析私钥，passphrase不为空时用于解密
+func parsePrivateKey(privateKey, passphrase string) (ssh.Signer, error) {
+	var signer ssh.Signer
+	var err error
+	if passphrase != "" {
+		// 带密码短语的私钥
+		signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(privateKey), []byte(passphrase))
+	} else {
+		// 无密码短语的私钥
+		signer, err = ssh.ParsePrivateKey([]byte(privateKey))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解析私钥失败: %v", err)
+	}
+	return signer, nil
+}
+
+// parseUserCertificate 解析 OpenSSH 用户证书（*-cert.pub 文件内容）
+func parseUserCertificate(text string) (*ssh.Certificate, error) {
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(text))
+	if err != nil {
+		return nil, fmt.Errorf("解析证书失败: %v", err)
+	}
+	cert, ok := key.(*ssh.Certificate)
+	if !ok || cert.CertType != ssh.UserCert {
+		return nil, fmt.Errorf("解析证书失败: 不是OpenSSH用户证书")
+	}
+	return cert, nil
+}
+
+// certificateSigner 返回带用户证书的签名器：配置了证书时使用该证书和私钥，
+// 否则由CA为端点的私钥（未配置私钥时为临时生成的密钥）签发短期证书
+func certificateSigner(e endpoint) (ssh.Signer, error) {
+	var key ssh.Signer
+	var err error
+	if e.PrivateKey != "" {
+		if key, err = parsePrivateKey(e.PrivateKey, e.Passphrase); err != nil {
+			return nil, err
+		}
+	}
+
+	if e.Certificate != "" {
+		cert, err := parseUserCertificate(e.Certificate)
+		if err != nil {
+			return nil, err
+		}
+		if before := cert.ValidBefore; before != ssh.CertTimeInfinity && time.Now().Unix() >= int64(before) {
+			return nil, fmt.Errorf("证书已于 %s 过期", time.Unix(int64(before), 0).Format("2006-01-02 15:04:05"))
+		}
+		signer, err := ssh.NewCertSigner(cert, key)
+		if err != nil {
+			return nil, fmt.Errorf("证书与私钥不匹配: %v", err)
+		}
+		return signer, nil
+	}
+
+	if key == nil {
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("生成临时密钥失败: %v", err)
+		}
+		if key, err = ssh.NewSignerFromKey(priv); err != nil {
+			return nil, fmt.Errorf("生成临时密钥失败: %v", err)
+		}
+	}
+	return signUserCertificate(key, e)
+}
+
+// signUserCertificate 由CA为key签发只允许以端点用户名登录的短期证书
+func signUserCertificate(key ssh.Signer, e endpoint) (ssh.Signer, error) {
+	authSettings.RLock()
+	ca, validity := authSettings.ca, authSettings.certValidity
+	authSettings.RUnlock()
+	if ca == nil {
+		return nil, fmt.Errorf("未配置签发证书的CA私钥（ssh.ca_key_file）")
+	}
+
+	var serial [8]byte
+	if _, err := rand.Read(serial[:]); err != nil {
+		return nil, fmt.Errorf("签发证书失败: %v", err)
+	}
+	now := time.Now()
+	cert := &ssh.Certificate{
+		Key:             key.PublicKey(),
+		Serial:          binary.BigEndian.Uint64(serial[:]),
+		CertType:        ssh.UserCert,
+		KeyId:           fmt.Sprintf("go-devops:%s-%d:%s", e.hostKey.kind, e.hostKey.id, e.Username),
+		ValidPrincipals: []string{e.Username},
+		ValidAfter:      uint64(now.Add(-certClockSkew).Unix()),
+		ValidBefore:     uint64(now.Add(validity).Unix()),
+		Permissions: ssh.Permissions{
+			Extensions: map[string]string{
+				"permit-pty": "",
+				// 经由跳板机转发到下一跳需要端口转发权限
+				"permit-port-forwarding": "",
+			},
+		},
+	}
+	if err := cert.SignCert(rand.Reader, ca); err != nil {
+		return nil, fmt.Errorf("签发证书失败: %v", err)
+	}
+	signer, err := ssh.NewCertSigner(cert, key)
+	if err != nil {
+		return nil, fmt.Errorf("签发证书失败: %v", err)
+	}
+	logger.Infof("为 %s@%s 签发用户证书，序列号 %d，有效期至 %s",
+		e.Username, e.IP, cert.Serial, now.Add(validity).Format("2006-01-02 15:04:05"))
+	return signer, nil
+}
+
+// keyboardInteractiveChallenge 以密码应答不回显的提示（如 Password:），
+// 需要回显的输入（如用户名、验证码）无法自动应答，返回错误
+func keyboardInteractiveChallenge(password string) ssh.KeyboardInteractiveChallenge {
+	return func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+		answers := make([]string, len(questions))
+		for i, question := range questions {
+			if echos[i] {
+				return nil, fmt.Errorf("无法自动应答键盘交互提示: %s", strings.TrimSpace(question))
+			}
+			answers[i] = password
+		}
+		return answers, nil
+	}
+}
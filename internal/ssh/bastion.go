@@ -0,0 +1,271 @@
+package ssh
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"go-devops/internal/logger"
+	"go-devops/internal/models"
+	"golang.org/x/crypto/ssh"
+)
+
+// BastionStore 跳板机查询，由服务层实现
+type BastionStore interface {
+	// BastionChain 返回到达跳板机经过的链路，从可直接连接的第一跳到该跳板机本身
+	BastionChain(bastionID uint) ([]models.Bastion, error)
+}
+
+var bastionStore = struct {
+	sync.RWMutex
+	store BastionStore
+}{}
+
+// ConfigureBastions 设置跳板机查询
+func ConfigureBastions(store BastionStore) {
+	bastionStore.Lock()
+	defer bastionStore.Unlock()
+	bastionStore.store = store
+}
+
+// bastionConn 到跳板机的共享连接，经由同一跳板机的主机连接共用，全部归还后关闭
+type bastionConn struct {
+	key    string
+	name   string
+	client *ssh.Client
+	parent *bastionConn // 经由的上一级跳板机连接
+	refs   int
+	closed bool
+}
+
+// bastionPool 按跳板机链路和凭据复用跳板机连接
+type bastionPool struct {
+	mu    sync.Mutex
+	conns map[string]*bastionConn
+}
+
+var bastions = &bastionPool{conns: make(map[string]*bastionConn)}
+
+func bastionEndpoint(bastion *models.Bastion) endpoint {
+	return endpoint{
+		IP:          bastion.IP,
+		Port:        bastion.Port,
+		Username:    bastion.Username,
+		AuthType:    bastion.AuthType,
+		Password:    bastion.Password,
+		PrivateKey:  bastion.PrivateKey,
+		Passphrase:  bastion.Passphrase,
+		Certificate: bastion.Certificate,
+		hostKey: hostKeyTarget{
+			kind:    HostKeyTargetBastion,
+			id:      bastion.ID,
+			label:   fmt.Sprintf("跳板机 %s (%s)", bastion.Name, bastion.IP),
+			trusted: bastion.HostKey,
+		},
+		credentialErr: bastion.CredentialError,
+	}
+}
+
+// bastionKey 跳板机连接的复用键，包含上一级连接，凭据修改后不复用旧连接
+func bastionKey(bastion *models.Bastion, parent *bastionConn) string {
+	h := sha256.New()
+	for _, field := range []string{
+		fmt.Sprintf("%d", bastion.ID), bastion.IP, fmt.Sprintf("%d", bastion.Port), bastion.Username,
+		bastion.AuthType, bastion.Password, bastion.PrivateKey, bastion.Passphrase, bastion.Certificate, bastion.HostKey,
+	} {
+		h.Write([]byte(field))
+		h.Write([]byte{0})
+	}
+	key := fmt.Sprintf("%s@%s:%d/%s", bastion.Username, bastion.IP, bastion.Port, hex.EncodeToString(h.Sum(nil))[:16])
+	if parent != nil {
+		key = parent.key + ">" + key
+	}
+	return key
+}
+
+// acquire 按链路逐级获取跳板机连接，返回最后一级的连接，使用完后需调用release
+func (p *bastionPool) acquire(bastionID uint) (*bastionConn, error) {
+	bastionStore.RLock()
+	store := bastionStore.store
+	bastionStore.RUnlock()
+	if store == nil {
+		return nil, fmt.Errorf("跳板机未配置")
+	}
+	chain, err := store.BastionChain(bastionID)
+	if err != nil {
+		return nil, err
+	}
+
+	var parent *bastionConn
+	for i := range chain {
+		conn, err := p.acquireHop(&chain[i], parent)
+		// 新建的连接自己持有对上一级的引用
+		if parent != nil {
+			p.release(parent)
+		}
+		if err != nil {
+			return nil, err
+		}
+		parent = conn
+	}
+	if parent == nil {
+		return nil, fmt.Errorf("跳板机 %d 不存在", bastionID)
+	}
+	return parent, nil
+}
+
+// acquireHop 获取经由parent到跳板机的连接，没有可用连接时建立
+func (p *bastionPool) acquireHop(bastion *models.Bastion, parent *bastionConn) (*bastionConn, error) {
+	key := bastionKey(bastion, parent)
+	p.mu.Lock()
+	if conn, ok := p.conns[key]; ok && !conn.closed {
+		conn.refs++
+		p.mu.Unlock()
+		return conn, nil
+	}
+	p.mu.Unlock()
+
+	e := bastionEndpoint(bastion)
+	config, cleanup, err := clientConfig(e)
+	if err != nil {
+		return nil, fmt.Errorf("跳板机 %s 配置错误: %v", bastion.Name, err)
+	}
+	defer cleanup()
+	var client *ssh.Client
+	if parent == nil {
+		client, err = ssh.Dial("tcp", e.addr(), config)
+	} else {
+		client, err = dialVia(parent.client, e.addr(), config)
+	}
+	if err != nil {
+		logger.Errorf("连接跳板机失败: %s@%s:%d, 错误: %v", bastion.Username, bastion.IP, bastion.Port, err)
+		return nil, fmt.Errorf("连接跳板机 %s 失败: %v", bastion.Name, err)
+	}
+	logger.Infof("跳板机连接成功: %s@%s:%d", bastion.Username, bastion.IP, bastion.Port)
+
+	p.mu.Lock()
+	if existing, ok := p.conns[key]; ok && !existing.closed {
+		// 并发建立了同一跳板机的连接，使用先建立的
+		existing.refs++
+		p.mu.Unlock()
+		client.Close()
+		return existing, nil
+	}
+	conn := &bastionConn{key: key, name: bastion.Name, client: client, parent: parent, refs: 1}
+	if parent != nil {
+		parent.refs++
+	}
+	p.conns[key] = conn
+	p.mu.Unlock()
+
+	// 连接断开后不再复用，经由它的连接随之失败并在关闭时归还
+	go func() {
+		client.Wait()
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.conns[key] == conn {
+			delete(p.conns, key)
+		}
+	}()
+	return conn, nil
+}
+
+// release 归还跳板机连接，没有引用时关闭并归还上一级
+func (p *bastionPool) release(conn *bastionConn) {
+	p.mu.Lock()
+	conn.refs--
+	if conn.refs > 0 || conn.closed {
+		p.mu.Unlock()
+		return
+	}
+	conn.closed = true
+	if p.conns[conn.key] == conn {
+		delete(p.conns, conn.key)
+	}
+	p.mu.Unlock()
+
+	conn.client.Close()
+	logger.Infof("关闭跳板机连接: %s", conn.name)
+	if conn.parent != nil {
+		p.release(conn.parent)
+	}
+}
+
+// dialVia 经由已建立的SSH连接转发到addr并完成SSH握手，超时时间与直接连接相同
+func dialVia(via *ssh.Client, addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	type result struct {
+		client *ssh.Client
+		err    error
+	}
+	var mu sync.Mutex
+	var tunnel net.Conn
+	timedOut := false
+	done := make(chan result, 1)
+
+	go func() {
+		conn, err := via.Dial("tcp", addr)
+		if err != nil {
+			done <- result{err: err}
+			return
+		}
+		mu.Lock()
+		if timedOut {
+			mu.Unlock()
+			conn.Close()
+			done <- result{err: fmt.Errorf("连接 %s 超时", addr)}
+			return
+		}
+		tunnel = conn
+		mu.Unlock()
+
+		c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+		if err != nil {
+			conn.Close()
+			done <- result{err: err}
+			return
+		}
+		done <- result{client: ssh.NewClient(c, chans, reqs)}
+	}()
+
+	select {
+	case r := <-done:
+		return r.client, r.err
+	case <-time.After(config.Timeout):
+		mu.Lock()
+		timedOut = true
+		if tunnel != nil {
+			// 关闭转发通道使握手失败
+			tunnel.Close()
+		}
+		mu.Unlock()
+		go func() {
+			if r := <-done; r.client != nil {
+				r.client.Close()
+			}
+		}()
+		return nil, fmt.Errorf("连接 %s 超时", addr)
+	}
+}
+
+// TestBastionConnection 测试经由跳板机链到该跳板机的连接
+func TestBastionConnection(bastionID uint) *models.SSHTestResponse {
+	start := time.Now()
+	conn, err := bastions.acquire(bastionID)
+	if err != nil {
+		return &models.SSHTestResponse{
+			Success: false,
+			Message: fmt.Sprintf("连接失败: %v", err),
+		}
+	}
+	defer bastions.release(conn)
+
+	latency := time.Since(start)
+	return &models.SSHTestResponse{
+		Success: true,
+		Message: fmt.Sprintf("跳板机连接成功，延迟: %v", latency),
+		Latency: latency.String(),
+	}
+}
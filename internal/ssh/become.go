@@ -0,0 +1,205 @@
+package ssh
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// 提权方式
+const (
+	BecomeSudo = "sudo"
+	BecomeSu   = "su"
+)
+
+// 提权目标用户的默认值
+const defaultBecomeUser = "root"
+
+// sudo使用固定的提示符，只有输出中出现该提示符时才写入密码，不受远程语言环境影响
+const sudoPrompt = "__DEVOPS_SUDO_PASSWORD__: "
+
+// su的密码提示无法自定义：匹配尚未换行的输出末尾，兼容本地化提示，只在提权阶段（命令的首个输出）匹配
+var suPromptPattern = regexp.MustCompile(`(?i)(password|口令|密码)[^\n]{0,64}[:：]\s*$`)
+
+// 当前行缓冲的上限，密码提示不会超过该长度
+const promptLineLimit = 256
+
+var becomeUserPattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]*$`)
+
+// Become 以其他用户身份执行命令（提权）
+type Become struct {
+	Method   string // sudo 或 su
+	User     string // 目标用户，为空时为root
+	Password string // 提权密码（明文），为空时不应答密码提示
+}
+
+// ValidateBecome 校验提权方式和目标用户
+func ValidateBecome(method, user string) error {
+	switch method {
+	case BecomeSudo, BecomeSu:
+	default:
+		return fmt.Errorf("提权方式 '%s' 无效，应为 sudo 或 su", method)
+	}
+	if user != "" && !becomeUserPattern.MatchString(user) {
+		return fmt.Errorf("提权目标用户 '%s' 无效", user)
+	}
+	return nil
+}
+
+func (b *Become) targetUser() string {
+	if b.User == "" {
+		return defaultBecomeUser
+	}
+	return b.User
+}
+
+// needsPTY su只从终端读取密码，需要分配伪终端
+func (b *Become) needsPTY() bool {
+	return b.Method == BecomeSu
+}
+
+// wrap 将完整命令（含参数导出和脚本内容）交给目标用户的 /bin/sh 执行，
+// 工作目录保持为登录用户的当前目录，以便访问已上传的输入文件
+func (b *Become) wrap(command string) string {
+	switch b.Method {
+	case BecomeSu:
+		return fmt.Sprintf("su -s /bin/sh %s -c %s", shellQuote(b.targetUser()), shellQuote(command))
+	default:
+		// 未配置密码时使用 -n，需要密码时sudo立即失败而不是等待输入
+		auth := "-n"
+		if b.Password != "" {
+			auth = "-S -p " + shellQuote(sudoPrompt)
+		}
+		return fmt.Sprintf("sudo %s -H -u %s -- /bin/sh -c %s", auth, shellQuote(b.targetUser()), shellQuote(command))
+	}
+}
+
+// attach 为会话准备提权所需的输入：su申请伪终端，返回应答密码提示的监视器
+func (b *Become) attach(session *ssh.Session) (*promptWatcher, error) {
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("创建SSH输入流失败: %v", err)
+	}
+	if b.needsPTY() {
+		// 伪终端下标准错误会合并到标准输出
+		modes := ssh.TerminalModes{ssh.ECHO: 0, ssh.TTY_OP_ISPEED: 14400, ssh.TTY_OP_OSPEED: 14400}
+		if err := session.RequestPty("xterm", 40, 200, modes); err != nil {
+			return nil, fmt.Errorf("申请伪终端失败: %v", err)
+		}
+	}
+	return newPromptWatcher(b, func(answer string) {
+		io.WriteString(stdin, answer)
+	}), nil
+}
+
+// promptWatcher 监视提权阶段的密码提示，提权密码最多写入一次：
+// sudo只识别固定提示符，首次出现时写入密码，再次出现（密码错误）时通过failed通知中止执行；
+// su只识别命令的首个输出，应答后或首个输出不是密码提示时停止监视（su密码错误时直接退出）。
+// 未配置密码时出现提示同样中止执行，避免命令一直等待输入
+type promptWatcher struct {
+	mu       sync.Mutex
+	method   string
+	password string
+	answer   func(string)
+	prompts  int
+	done     bool // 提权阶段已结束，不再监视
+	failed   chan error
+}
+
+func newPromptWatcher(b *Become, answer func(string)) *promptWatcher {
+	return &promptWatcher{
+		method:   b.Method,
+		password: b.Password,
+		answer:   answer,
+		failed:   make(chan error, 1),
+	}
+}
+
+// writer 返回监视指定输出流的Writer
+func (w *promptWatcher) writer() *promptStream {
+	return &promptStream{watcher: w}
+}
+
+// promptStream 每个输出流单独记录当前行，共享应答状态
+type promptStream struct {
+	watcher *promptWatcher
+	line    []byte
+}
+
+func (s *promptStream) Write(p []byte) (int, error) {
+	if s.watcher.finished() {
+		s.line = nil
+		return len(p), nil
+	}
+	s.line = append(s.line, p...)
+	if i := bytes.LastIndexByte(s.line, '\n'); i >= 0 {
+		s.line = s.line[i+1:]
+		// su的首个输出已完整输出一行且不是密码提示，说明无需密码
+		if s.watcher.method == BecomeSu {
+			s.watcher.finish()
+		}
+	}
+	if len(s.line) > promptLineLimit {
+		s.line = s.line[len(s.line)-promptLineLimit:]
+	}
+	if len(s.line) > 0 && s.watcher.isPrompt(s.line) {
+		// 同一提示只处理一次
+		s.line = nil
+		s.watcher.onPrompt()
+	}
+	return len(p), nil
+}
+
+func (w *promptWatcher) isPrompt(line []byte) bool {
+	if w.method == BecomeSu {
+		return suPromptPattern.Match(line)
+	}
+	return bytes.HasSuffix(line, []byte(sudoPrompt))
+}
+
+func (w *promptWatcher) finished() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.done
+}
+
+func (w *promptWatcher) finish() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.done = true
+}
+
+func (w *promptWatcher) onPrompt() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.done {
+		return
+	}
+
+	w.prompts++
+	switch {
+	case w.password == "":
+		w.done = true
+		w.fail(fmt.Errorf("提权需要密码，但未配置提权密码"))
+	case w.prompts > 1:
+		w.done = true
+		w.fail(fmt.Errorf("提权密码错误或目标用户不允许提权"))
+	default:
+		if w.method == BecomeSu {
+			w.done = true
+		}
+		// 在新协程中写入，避免阻塞输出读取
+		go w.answer(w.password + "\n")
+	}
+}
+
+func (w *promptWatcher) fail(err error) {
+	select {
+	case w.failed <- err:
+	default:
+	}
+}
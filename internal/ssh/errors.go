@@ -0,0 +1,89 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// 执行失败类型
+const (
+	FailureExitCode    = "exit_code"   // 脚本以非零退出码结束
+	FailureConnection  = "connection"  // 连接、认证、会话或文件传输失败
+	FailureTimeout     = "timeout"     // 超时后被终止
+	FailureCancelled   = "cancelled"   // 被用户取消后终止
+	FailureBecome      = "become"      // 提权失败：需要密码但未配置或密码错误
+	FailureInterrupted = "interrupted" // 服务重启时仍未结束，执行结果未知
+)
+
+// ExecError 远程执行错误，区分失败类型并携带退出码
+type ExecError struct {
+	Type     string // 失败类型
+	ExitCode *int   // 远程命令的退出码，未获取到时为nil
+	Err      error
+}
+
+func (e *ExecError) Error() string {
+	if e.Type == FailureExitCode && e.ExitCode != nil {
+		return fmt.Sprintf("脚本退出码: %d", *e.ExitCode)
+	}
+	return e.Err.Error()
+}
+
+func (e *ExecError) Unwrap() error {
+	return e.Err
+}
+
+// connectionError 包装连接类错误
+func connectionError(format string, args ...interface{}) error {
+	return &ExecError{Type: FailureConnection, Err: fmt.Errorf(format, args...)}
+}
+
+// classifyWaitError 根据session.Wait的返回值确定失败类型
+func classifyWaitError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var exitErr *ssh.ExitError
+	if errors.As(err, &exitErr) {
+		code := exitErr.ExitStatus()
+		return &ExecError{Type: FailureExitCode, ExitCode: &code, Err: err}
+	}
+	// ExitMissingError等情况说明连接在命令结束前中断
+	return &ExecError{Type: FailureConnection, Err: err}
+}
+
+// contextError 根据ctx结束原因包装错误
+func contextError(ctx context.Context) error {
+	err := fmt.Errorf("命令执行被中断: %w", ctx.Err())
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return &ExecError{Type: FailureTimeout, Err: err}
+	}
+	return &ExecError{Type: FailureCancelled, Err: err}
+}
+
+// FailureTypeOf 返回错误对应的失败类型，无法识别时按连接失败处理
+func FailureTypeOf(err error) string {
+	var execErr *ExecError
+	if errors.As(err, &execErr) {
+		return execErr.Type
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return FailureTimeout
+	}
+	if errors.Is(err, context.Canceled) {
+		return FailureCancelled
+	}
+	return FailureConnection
+}
+
+// ExitCodeOf 返回错误中携带的远程退出码
+func ExitCodeOf(err error) *int {
+	var execErr *ExecError
+	if errors.As(err, &execErr) {
+		return execErr.ExitCode
+	}
+	return nil
+}
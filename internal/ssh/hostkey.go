@@ -0,0 +1,168 @@
+package ssh
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"go-devops/internal/logger"
+	"golang.org/x/crypto/ssh"
+)
+
+// 主机密钥校验策略
+const (
+	HostKeyPolicyTOFU   = "tofu"   // 首次连接时记录并信任主机密钥
+	HostKeyPolicyStrict = "strict" // 只信任管理员确认或导入的主机密钥
+)
+
+// 主机密钥的归属
+const (
+	HostKeyTargetHost    = "host"    // 目标主机
+	HostKeyTargetBastion = "bastion" // 跳板机
+)
+
+// HostKeyStore 主机密钥的持久化，由服务层实现，target为 HostKeyTargetHost 或 HostKeyTargetBastion
+type HostKeyStore interface {
+	// TrustFirstKey 尚未记录密钥时记录并信任key，返回当前受信任的密钥
+	TrustFirstKey(target string, id uint, key, fingerprint string) (string, error)
+	// RecordPendingKey 记录未受信任的密钥，等待管理员确认
+	RecordPendingKey(target string, id uint, key, fingerprint string) error
+}
+
+// hostKeyTarget 需要校验密钥的连接端点
+type hostKeyTarget struct {
+	kind    string // HostKeyTargetHost, HostKeyTargetBastion
+	id      uint
+	label   string // 用于日志和错误信息，如"主机 10.0.0.1"
+	trusted string // 已信任的密钥
+}
+
+// kindName 端点类型的名称，用于日志
+func (t hostKeyTarget) kindName() string {
+	if t.kind == HostKeyTargetBastion {
+		return "跳板机"
+	}
+	return "主机"
+}
+
+var hostKeys = struct {
+	sync.RWMutex
+	policy string
+	store  HostKeyStore
+}{policy: HostKeyPolicyTOFU}
+
+// ConfigureHostKeys 设置主机密钥校验策略和存储，policy为空时使用tofu
+func ConfigureHostKeys(policy string, store HostKeyStore) error {
+	if policy == "" {
+		policy = HostKeyPolicyTOFU
+	}
+	if policy != HostKeyPolicyTOFU && policy != HostKeyPolicyStrict {
+		return fmt.Errorf("不支持的主机密钥策略: %s，支持的策略: tofu, strict", policy)
+	}
+	hostKeys.Lock()
+	defer hostKeys.Unlock()
+	hostKeys.policy = policy
+	hostKeys.store = store
+	return nil
+}
+
+// HostKeyPolicy 返回当前的主机密钥校验策略
+func HostKeyPolicy() string {
+	hostKeys.RLock()
+	defer hostKeys.RUnlock()
+	return hostKeys.policy
+}
+
+// MarshalHostKey 将公钥编码为 authorized_keys 格式（类型和Base64），不含换行
+func MarshalHostKey(key ssh.PublicKey) string {
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key)))
+}
+
+// ParseHostKey 解析 authorized_keys 格式的公钥
+func ParseHostKey(text string) (ssh.PublicKey, error) {
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(text))
+	if err != nil {
+		return nil, fmt.Errorf("无效的主机密钥: %v", err)
+	}
+	return key, nil
+}
+
+// HostKeyFingerprint 返回公钥的SHA256指纹，格式与 ssh-keygen -l 一致
+func HostKeyFingerprint(key ssh.PublicKey) string {
+	return ssh.FingerprintSHA256(key)
+}
+
+// hostKeyCallback 按策略校验端点提供的密钥：已记录密钥时必须一致，
+// 未记录时tofu策略记录并信任，strict策略拒绝连接。未受信任的密钥记录为待确认
+func hostKeyCallback(target hostKeyTarget) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		hostKeys.RLock()
+		policy, store := hostKeys.policy, hostKeys.store
+		hostKeys.RUnlock()
+
+		presented := MarshalHostKey(key)
+		fingerprint := HostKeyFingerprint(key)
+		trusted := target.trusted
+
+		if trusted == "" && target.id != 0 && store != nil {
+			if policy == HostKeyPolicyStrict {
+				if err := store.RecordPendingKey(target.kind, target.id, presented, fingerprint); err != nil {
+					logger.Errorf("记录%s的待确认密钥失败: %v", target.label, err)
+				}
+				return fmt.Errorf("%s的密钥（%s）未受信任，请管理员确认或导入 known_hosts 后再连接", target.label, fingerprint)
+			}
+			current, err := store.TrustFirstKey(target.kind, target.id, presented, fingerprint)
+			if err != nil {
+				return fmt.Errorf("记录主机密钥失败: %v", err)
+			}
+			if current == presented {
+				logger.Infof("首次连接%s，已记录主机密钥: %s", target.label, fingerprint)
+				return nil
+			}
+			// 并发的首次连接已记录了不同的密钥
+			trusted = current
+		}
+		if trusted == "" {
+			// 未保存的端点（如连接测试）无处记录密钥
+			if policy == HostKeyPolicyStrict {
+				return fmt.Errorf("%s的密钥（%s）未受信任", target.label, fingerprint)
+			}
+			return nil
+		}
+
+		expected, err := ParseHostKey(trusted)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(expected.Marshal(), key.Marshal()) {
+			return nil
+		}
+		if store != nil && target.id != 0 {
+			if err := store.RecordPendingKey(target.kind, target.id, presented, fingerprint); err != nil {
+				logger.Errorf("记录%s的待确认密钥失败: %v", target.label, err)
+			}
+		}
+		logger.Warnf("%s的密钥不匹配: 期望 %s，实际 %s", target.label, HostKeyFingerprint(expected), fingerprint)
+		return fmt.Errorf("%s的密钥不匹配（期望 %s，实际 %s），可能存在中间人攻击；如主机已重装，请管理员确认新密钥或重置主机密钥",
+			target.label, HostKeyFingerprint(expected), fingerprint)
+	}
+}
+
+// hostKeyAlgorithms 已记录密钥时只协商该密钥类型，避免服务端提供其他类型的密钥导致误报不匹配
+func hostKeyAlgorithms(trusted string) []string {
+	if trusted == "" {
+		return nil
+	}
+	key, err := ParseHostKey(trusted)
+	if err != nil {
+		return nil
+	}
+	switch key.Type() {
+	case ssh.KeyAlgoRSA:
+		return []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}
+	default:
+		return []string{key.Type()}
+	}
+}
@@ -0,0 +1,104 @@
+package ssh
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// 启动模板中的占位符
+const (
+	PlaceholderInterpreter = "{interpreter}" // 探测到的解释器命令
+	PlaceholderScript      = "{script}"      // 脚本文件路径
+	PlaceholderArgs        = "{args}"        // 按声明顺序排列的位置参数
+)
+
+// 写入脚本文件使用的heredoc结束标记
+const scriptDelimiter = "__DEVOPS_SCRIPT_EOF__"
+
+var (
+	interpreterTypePattern  = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,49}$`)
+	interpreterExtPattern   = regexp.MustCompile(`^(\.[A-Za-z0-9]{1,10})?$`)
+	interpreterProbePattern = regexp.MustCompile(`^[A-Za-z0-9_./+-]+$`)
+)
+
+// Interpreter 脚本类型的解释器定义：脚本内容写入带扩展名的文件，
+// 按顺序探测第一个可用的解释器，再按启动模板执行
+type Interpreter struct {
+	Type      string   `json:"type"`      // 脚本类型
+	Extension string   `json:"extension"` // 脚本文件扩展名，如 .py
+	Probes    []string `json:"probes"`    // 解释器探测顺序，命令名或绝对路径
+	Template  string   `json:"template"`  // 启动模板，如 {interpreter} -u {script} {args}
+}
+
+// Validate 校验解释器定义
+func (i Interpreter) Validate() error {
+	if !interpreterTypePattern.MatchString(i.Type) {
+		return fmt.Errorf("脚本类型 '%s' 无效，只能包含小写字母、数字、下划线和连字符，且以字母开头", i.Type)
+	}
+	if !interpreterExtPattern.MatchString(i.Extension) {
+		return fmt.Errorf("文件扩展名 '%s' 无效，应为以点开头的字母或数字，如 .py", i.Extension)
+	}
+	if len(i.Probes) == 0 {
+		return fmt.Errorf("至少需要一个解释器探测命令")
+	}
+	for _, probe := range i.Probes {
+		if !interpreterProbePattern.MatchString(probe) {
+			return fmt.Errorf("解释器探测命令 '%s' 无效，只能是命令名或路径", probe)
+		}
+	}
+	if !strings.Contains(i.Template, PlaceholderInterpreter) || !strings.Contains(i.Template, PlaceholderScript) {
+		return fmt.Errorf("启动模板必须包含 %s 和 %s", PlaceholderInterpreter, PlaceholderScript)
+	}
+	if strings.ContainsAny(i.Template, "\n\r") {
+		return fmt.Errorf("启动模板不能包含换行符")
+	}
+	return nil
+}
+
+// scriptFileName 返回脚本文件名，未使用独立工作目录时加上时间戳避免并发执行互相覆盖
+func (i Interpreter) scriptFileName(inWorkdir bool) string {
+	if inWorkdir {
+		return "devops_script" + i.Extension
+	}
+	return fmt.Sprintf("devops_script_%d%s", time.Now().UnixNano(), i.Extension)
+}
+
+// buildCommand 生成执行脚本的命令：写入脚本文件、探测解释器、按模板启动，并以脚本的退出码结束
+// 解释器的标准错误原样保留
+func (i Interpreter) buildCommand(content, args string, inWorkdir bool) string {
+	file := shellQuote(i.scriptFileName(inWorkdir))
+
+	// 结束标记不能与脚本中的某一行相同
+	delimiter := scriptDelimiter
+	for n := 1; strings.Contains("\n"+content+"\n", "\n"+delimiter+"\n"); n++ {
+		delimiter = fmt.Sprintf("%s_%d", scriptDelimiter, n)
+	}
+
+	probes := make([]string, 0, len(i.Probes))
+	for _, probe := range i.Probes {
+		probes = append(probes, shellQuote(probe))
+	}
+
+	launch := strings.NewReplacer(
+		PlaceholderInterpreter, `"$__devops_interpreter"`,
+		PlaceholderScript, file,
+		PlaceholderArgs, args,
+	).Replace(i.Template)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "cat > %s << '%s'\n%s\n%s\n", file, delimiter, content, delimiter)
+	b.WriteString("__devops_interpreter=\"\"\n")
+	fmt.Fprintf(&b, "for __devops_candidate in %s; do\n", strings.Join(probes, " "))
+	b.WriteString("    if command -v \"$__devops_candidate\" >/dev/null 2>&1; then __devops_interpreter=\"$__devops_candidate\"; break; fi\n")
+	b.WriteString("done\n")
+	b.WriteString("if [ -z \"$__devops_interpreter\" ]; then\n")
+	fmt.Fprintf(&b, "    echo %s >&2\n", shellQuote(fmt.Sprintf("未找到可用的 %s 解释器（%s）", i.Type, strings.Join(i.Probes, ", "))))
+	fmt.Fprintf(&b, "    rm -f %s\n    exit 127\nfi\n", file)
+	fmt.Fprintf(&b, "%s\n", launch)
+	b.WriteString("__devops_status=$?\n")
+	fmt.Fprintf(&b, "rm -f %s\n", file)
+	b.WriteString("exit $__devops_status")
+	return b.String()
+}
@@ -0,0 +1,106 @@
+package ssh
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// KnownHostsEntry known_hosts 文件中的一条主机密钥记录
+type KnownHostsEntry struct {
+	Line        int      // 行号，从1开始
+	Hosts       []string // 主机字段，可能是哈希形式
+	Key         string   // authorized_keys 格式的公钥
+	KeyType     string
+	Fingerprint string
+}
+
+// ParseKnownHosts 逐行解析 OpenSSH known_hosts 内容。带 @cert-authority、@revoked 标记的行
+// 和无法解析的行不导入，在problems中说明
+func ParseKnownHosts(content string) ([]KnownHostsEntry, []string) {
+	var entries []KnownHostsEntry
+	var problems []string
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		marker, hosts, key, _, _, err := ssh.ParseKnownHosts([]byte(line))
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("第%d行解析失败: %v", lineNo, err))
+			continue
+		}
+		if marker != "" {
+			problems = append(problems, fmt.Sprintf("第%d行带有 @%s 标记，不导入", lineNo, marker))
+			continue
+		}
+		entries = append(entries, KnownHostsEntry{
+			Line:        lineNo,
+			Hosts:       hosts,
+			Key:         MarshalHostKey(key),
+			KeyType:     key.Type(),
+			Fingerprint: HostKeyFingerprint(key),
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		problems = append(problems, fmt.Sprintf("读取内容失败: %v", err))
+	}
+	return entries, problems
+}
+
+// KnownHostsPatternSupported 主机字段是否为可精确匹配的形式，通配符和否定模式不支持
+func KnownHostsPatternSupported(pattern string) bool {
+	return !strings.ContainsAny(pattern, "*?!")
+}
+
+// KnownHostsPatternMatches 判断 known_hosts 的主机字段是否对应ip和port，支持 |1|salt|hash 哈希形式
+func KnownHostsPatternMatches(pattern, ip string, port int) bool {
+	address := knownhosts.Normalize(net.JoinHostPort(ip, strconv.Itoa(port)))
+	if !strings.HasPrefix(pattern, "|1|") {
+		return pattern == address
+	}
+	parts := strings.Split(pattern[len("|1|"):], "|")
+	if len(parts) != 2 {
+		return false
+	}
+	salt, err := base64.StdEncoding.DecodeString(parts[0])
+	if err != nil {
+		return false
+	}
+	hash, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha1.New, salt)
+	mac.Write([]byte(address))
+	return hmac.Equal(mac.Sum(nil), hash)
+}
+
+// hostKeyTypePreference 同一主机有多个密钥时的选择顺序，与OpenSSH客户端的默认偏好一致
+var hostKeyTypePreference = []string{
+	ssh.KeyAlgoED25519,
+	ssh.KeyAlgoECDSA256,
+	ssh.KeyAlgoECDSA384,
+	ssh.KeyAlgoECDSA521,
+	ssh.KeyAlgoRSA,
+}
+
+// HostKeyTypeRank 密钥类型的偏好顺序，越小越优先
+func HostKeyTypeRank(keyType string) int {
+	for i, t := range hostKeyTypePreference {
+		if t == keyType {
+			return i
+		}
+	}
+	return len(hostKeyTypePreference)
+}
@@ -0,0 +1,42 @@
+package ssh
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go-devops/internal/models"
+)
+
+// 脚本参数对应的环境变量前缀
+const paramEnvPrefix = "DEVOPS_PARAM_"
+
+var envNameInvalidChars = regexp.MustCompile(`[^A-Z0-9_]`)
+
+// shellQuote 使用单引号转义，保证参数值原样传递给远程shell
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'"'"'`) + "'"
+}
+
+// ParamEnvName 返回参数对应的环境变量名，例如 db_name -> DEVOPS_PARAM_DB_NAME
+func ParamEnvName(name string) string {
+	return paramEnvPrefix + envNameInvalidChars.ReplaceAllString(strings.ToUpper(name), "_")
+}
+
+// buildParamExports 生成导出参数环境变量的命令
+func buildParamExports(params []models.ScriptParameterValue) string {
+	var b strings.Builder
+	for _, param := range params {
+		b.WriteString(fmt.Sprintf("export %s=%s\n", ParamEnvName(param.Name), shellQuote(param.Value)))
+	}
+	return b.String()
+}
+
+// buildPositionalArgs 生成按声明顺序排列的位置参数
+func buildPositionalArgs(params []models.ScriptParameterValue) string {
+	args := make([]string, 0, len(params))
+	for _, param := range params {
+		args = append(args, shellQuote(param.Value))
+	}
+	return strings.Join(args, " ")
+}
@@ -0,0 +1,430 @@
+package ssh
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/sftp"
+	"go-devops/internal/logger"
+	"go-devops/internal/models"
+	"golang.org/x/crypto/ssh"
+)
+
+// 连接池默认参数
+const (
+	defaultMaxSessions       = 8                // 单个连接同时打开的最大会话数，OpenSSH默认 MaxSessions 为10
+	defaultIdleTimeout       = 5 * time.Minute  // 没有会话的连接空闲超过该时间后关闭
+	defaultKeepAliveInterval = 30 * time.Second // 保活请求间隔，超过一个间隔未响应则关闭连接
+)
+
+// PoolConfig SSH连接池配置
+type PoolConfig struct {
+	MaxSessions       int           // 单个连接的最大会话数（含共享的SFTP），<=0 使用默认值
+	IdleTimeout       time.Duration // 空闲连接的关闭时间，<=0 使用默认值
+	KeepAliveInterval time.Duration // 保活间隔，<=0 使用默认值
+}
+
+// pooledConn 连接池中的一个SSH连接
+type pooledConn struct {
+	key       string
+	hostID    uint
+	client    *ssh.Client
+	onClose   func()       // 连接关闭后调用，归还占用的跳板机连接
+	sessions  int          // 已打开的会话数，包含共享SFTP客户端占用的一个
+	sftp      *sftp.Client // 共享的SFTP客户端，首次使用时创建
+	sftpUsers int          // 正在使用共享SFTP客户端的调用方数
+	lastUsed  time.Time
+	full      bool // 服务端拒绝打开新会话（如 sshd 的 MaxSessions 小于池的上限），有会话归还前不再分配
+	closed    bool
+	stop      chan struct{}
+	dead      chan struct{} // 传输层断开（client.Wait 返回）后关闭
+}
+
+// busy 连接上是否有正在使用的会话或SFTP客户端
+func (c *pooledConn) busy() bool {
+	idleSessions := 0
+	if c.sftp != nil {
+		idleSessions = 1
+	}
+	return c.sessions > idleSessions || c.sftpUsers > 0
+}
+
+// connPool 按主机和凭据指纹复用SSH连接：每个连接的会话数达到上限时建立新连接，
+// 同一主机正在建立的连接足够容纳时等待而不重复建立
+type connPool struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	conns   map[string][]*pooledConn
+	dialing map[string]int // 正在建立的连接数
+	waiting map[string]int // 等待正在建立的连接的调用方数
+	config  PoolConfig
+	janitor sync.Once
+}
+
+var defaultPool = newConnPool()
+
+func newConnPool() *connPool {
+	p := &connPool{
+		conns:   make(map[string][]*pooledConn),
+		dialing: make(map[string]int),
+		waiting: make(map[string]int),
+		config: PoolConfig{
+			MaxSessions:       defaultMaxSessions,
+			IdleTimeout:       defaultIdleTimeout,
+			KeepAliveInterval: defaultKeepAliveInterval,
+		},
+	}
+	p.cond = sync.NewCond(&p.mu)
+	return p
+}
+
+// ConfigurePool 设置连接池参数，只影响之后建立的连接和之后的空闲检查
+func ConfigurePool(config PoolConfig) {
+	if config.MaxSessions <= 0 {
+		config.MaxSessions = defaultMaxSessions
+	}
+	if config.IdleTimeout <= 0 {
+		config.IdleTimeout = defaultIdleTimeout
+	}
+	if config.KeepAliveInterval <= 0 {
+		config.KeepAliveInterval = defaultKeepAliveInterval
+	}
+	defaultPool.mu.Lock()
+	defer defaultPool.mu.Unlock()
+	defaultPool.config = config
+}
+
+// ClosePool 关闭连接池中的所有连接，用于服务退出
+func ClosePool() {
+	defaultPool.mu.Lock()
+	var all []*pooledConn
+	for _, conns := range defaultPool.conns {
+		all = append(all, conns...)
+	}
+	defaultPool.mu.Unlock()
+	for _, conn := range all {
+		defaultPool.discard(conn, "服务关闭")
+	}
+}
+
+// CloseHostConnections 关闭主机在池中的所有连接，主机密钥变更后使之后的连接重新校验
+func CloseHostConnections(hostID uint) {
+	defaultPool.mu.Lock()
+	var matched []*pooledConn
+	for _, conns := range defaultPool.conns {
+		for _, conn := range conns {
+			if conn.hostID == hostID {
+				matched = append(matched, conn)
+			}
+		}
+	}
+	defaultPool.mu.Unlock()
+	for _, conn := range matched {
+		defaultPool.discard(conn, "主机密钥变更")
+	}
+}
+
+// poolKey 连接的复用键：主机地址、登录用户、凭据和跳板机的指纹，凭据修改后不会复用旧连接
+func poolKey(host *models.Host) string {
+	h := sha256.New()
+	for _, field := range []string{
+		fmt.Sprintf("%d", host.ID), host.IP, fmt.Sprintf("%d", host.Port), host.Username,
+		host.AuthType, host.Password, host.PrivateKey, host.Passphrase, host.Certificate, bastionField(host.BastionID),
+	} {
+		h.Write([]byte(field))
+		h.Write([]byte{0})
+	}
+	return fmt.Sprintf("%s@%s:%d/%s", host.Username, host.IP, host.Port, hex.EncodeToString(h.Sum(nil))[:16])
+}
+
+func bastionField(id *uint) string {
+	if id == nil {
+		return ""
+	}
+	return fmt.Sprintf("%d", *id)
+}
+
+// ensure 确保主机至少有一个可用连接，连接或认证失败时返回错误
+func (p *connPool) ensure(key string, host *models.Host) error {
+	p.mu.Lock()
+	for _, conn := range p.conns[key] {
+		if !conn.closed {
+			p.mu.Unlock()
+			return nil
+		}
+	}
+	p.mu.Unlock()
+
+	conn, err := p.acquire(key, host)
+	if err != nil {
+		return err
+	}
+	p.release(conn)
+	return nil
+}
+
+// acquire 占用一个有空闲会话名额的连接，没有时建立新连接
+func (p *connPool) acquire(key string, host *models.Host) (*pooledConn, error) {
+	p.janitor.Do(func() { go p.evictIdle() })
+
+	p.mu.Lock()
+	for {
+		if conn := p.available(key); conn != nil {
+			conn.sessions++
+			conn.lastUsed = time.Now()
+			p.mu.Unlock()
+			return conn, nil
+		}
+		// 正在建立的连接可以容纳时等待，避免并发执行时对同一主机同时建立大量连接
+		if p.dialing[key] > 0 && p.waiting[key] < p.dialing[key]*p.config.MaxSessions {
+			p.waiting[key]++
+			p.cond.Wait()
+			p.waiting[key]--
+			continue
+		}
+		break
+	}
+	p.dialing[key]++
+	keepAlive := p.config.KeepAliveInterval
+	p.mu.Unlock()
+
+	client, onClose, err := dial(host)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.dialing[key]--
+	p.cond.Broadcast()
+	if err != nil {
+		return nil, err
+	}
+	conn := &pooledConn{
+		key:      key,
+		hostID:   host.ID,
+		client:   client,
+		onClose:  onClose,
+		sessions: 1,
+		lastUsed: time.Now(),
+		stop:     make(chan struct{}),
+		dead:     make(chan struct{}),
+	}
+	p.conns[key] = append(p.conns[key], conn)
+	go p.keepAlive(conn, keepAlive)
+	go p.watch(conn)
+	return conn, nil
+}
+
+// available 返回有空闲会话名额的连接，需持有锁
+func (p *connPool) available(key string) *pooledConn {
+	for _, conn := range p.conns[key] {
+		if !conn.closed && !conn.full && conn.sessions < p.config.MaxSessions {
+			return conn
+		}
+	}
+	return nil
+}
+
+// release 归还会话名额，连接上有会话结束后服务端可以再接受新会话
+func (p *connPool) release(conn *pooledConn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	conn.sessions--
+	conn.full = false
+	conn.lastUsed = time.Now()
+	p.cond.Broadcast()
+}
+
+// releaseFull 归还未能打开会话的名额，并将连接标记为已满
+func (p *connPool) releaseFull(conn *pooledConn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	conn.sessions--
+	conn.full = true
+	p.cond.Broadcast()
+}
+
+// 打开会话失败时的最大尝试次数
+const maxSessionAttempts = 3
+
+// newSession 在池中的连接上打开会话，返回的release在会话关闭后调用。
+// 传输层已断开时丢弃连接；连接仍可用但服务端拒绝打开会话时将其标记为已满，
+// 两种情况都改用池中其他连接或建立新连接重试
+func (p *connPool) newSession(key string, host *models.Host) (*ssh.Session, func(), error) {
+	var lastErr error
+	for attempt := 0; attempt < maxSessionAttempts; attempt++ {
+		conn, err := p.acquire(key, host)
+		if err != nil {
+			return nil, nil, err
+		}
+		session, err := conn.client.NewSession()
+		if err == nil {
+			return session, func() { p.release(conn) }, nil
+		}
+		lastErr = err
+
+		var rejected *ssh.OpenChannelError
+		if !errors.As(err, &rejected) && conn.transportDead() {
+			p.release(conn)
+			p.discard(conn, fmt.Sprintf("创建会话失败: %v", err))
+			continue
+		}
+		p.releaseFull(conn)
+		logger.Warnf("SSH连接 %s 无法打开新会话，改用其他连接: %v", conn.key, err)
+	}
+	return nil, nil, lastErr
+}
+
+// transportDead 连接的传输层是否已断开
+func (c *pooledConn) transportDead() bool {
+	select {
+	case <-c.dead:
+		return true
+	default:
+		return false
+	}
+}
+
+// watch 等待连接的传输层断开，断开后丢弃连接
+func (p *connPool) watch(conn *pooledConn) {
+	err := conn.client.Wait()
+	close(conn.dead)
+	p.discard(conn, fmt.Sprintf("连接已断开: %v", err))
+}
+
+// sftpClient 返回主机的共享SFTP客户端，返回的release在本次使用结束后调用
+func (p *connPool) sftpClient(key string, host *models.Host) (*sftp.Client, func(), error) {
+	p.mu.Lock()
+	for _, conn := range p.conns[key] {
+		if !conn.closed && conn.sftp != nil {
+			conn.sftpUsers++
+			p.mu.Unlock()
+			return conn.sftp, func() { p.releaseSFTP(conn) }, nil
+		}
+	}
+	p.mu.Unlock()
+
+	// SFTP子系统占用连接的一个会话名额，直到连接关闭
+	conn, err := p.acquire(key, host)
+	if err != nil {
+		return nil, nil, err
+	}
+	client, err := sftp.NewClient(conn.client)
+	if err != nil {
+		p.release(conn)
+		return nil, nil, fmt.Errorf("创建SFTP客户端失败: %v", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if conn.closed || conn.sftp != nil {
+		// 连接已关闭，或并发调用已在该连接上创建了SFTP客户端
+		conn.sessions--
+		if conn.closed {
+			client.Close()
+			return nil, nil, fmt.Errorf("创建SFTP客户端失败: 连接已关闭")
+		}
+		client.Close()
+	} else {
+		conn.sftp = client
+	}
+	conn.sftpUsers++
+	return conn.sftp, func() { p.releaseSFTP(conn) }, nil
+}
+
+func (p *connPool) releaseSFTP(conn *pooledConn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	conn.sftpUsers--
+	conn.lastUsed = time.Now()
+}
+
+// discard 关闭并移出连接，连接上正在进行的会话随之失败
+func (p *connPool) discard(conn *pooledConn, reason string) {
+	p.mu.Lock()
+	if conn.closed {
+		p.mu.Unlock()
+		return
+	}
+	conn.closed = true
+	close(conn.stop)
+	conns := p.conns[conn.key]
+	for i, c := range conns {
+		if c == conn {
+			p.conns[conn.key] = append(conns[:i:i], conns[i+1:]...)
+			break
+		}
+	}
+	if len(p.conns[conn.key]) == 0 {
+		delete(p.conns, conn.key)
+	}
+	p.cond.Broadcast()
+	p.mu.Unlock()
+
+	if conn.sftp != nil {
+		conn.sftp.Close()
+	}
+	conn.client.Close()
+	conn.onClose()
+	logger.Infof("关闭SSH连接 %s: %s", conn.key, reason)
+}
+
+// keepAlive 定期发送保活请求，超过一个间隔未响应时关闭连接
+func (p *connPool) keepAlive(conn *pooledConn, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-conn.stop:
+			return
+		case <-ticker.C:
+		}
+
+		replied := make(chan error, 1)
+		go func() {
+			_, _, err := conn.client.SendRequest("keepalive@openssh.com", true, nil)
+			replied <- err
+		}()
+		select {
+		case err := <-replied:
+			if err != nil {
+				p.discard(conn, fmt.Sprintf("保活失败: %v", err))
+				return
+			}
+		case <-time.After(interval):
+			p.discard(conn, "保活请求超时")
+			return
+		case <-conn.stop:
+			return
+		}
+	}
+}
+
+// evictIdle 定期关闭空闲超时的连接
+func (p *connPool) evictIdle() {
+	for {
+		p.mu.Lock()
+		idleTimeout := p.config.IdleTimeout
+		var idle []*pooledConn
+		for _, conns := range p.conns {
+			for _, conn := range conns {
+				if !conn.busy() && time.Since(conn.lastUsed) > idleTimeout {
+					idle = append(idle, conn)
+				}
+			}
+		}
+		p.mu.Unlock()
+
+		for _, conn := range idle {
+			p.discard(conn, "空闲超时")
+		}
+
+		interval := idleTimeout / 2
+		if interval > time.Minute {
+			interval = time.Minute
+		}
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,156 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go-devops/internal/models"
+)
+
+// 执行前检查项
+const (
+	PreflightConnection  = "connection"  // SSH连接和认证
+	PreflightBecome      = "become"      // 提权
+	PreflightInterpreter = "interpreter" // 远程解释器
+	PreflightWorkdir     = "workdir"     // 工作目录可写
+)
+
+// 单项远程检查的超时时间
+const preflightCheckTimeout = 30 * time.Second
+
+// PreflightOptions 执行前检查的选项，与实际执行使用相同的提权和解释器设置
+type PreflightOptions struct {
+	Become      *Become      // 提权设置，为空时不检查提权
+	Interpreter *Interpreter // 脚本类型对应的解释器，为空时不检查
+	WorkdirBase string       // 工作目录基础路径，为空时不检查
+}
+
+// PreflightCheck 单项检查结果
+type PreflightCheck struct {
+	Name    string `json:"name"`
+	Passed  bool   `json:"passed"`
+	Message string `json:"message"`
+}
+
+// Preflight 在主机上执行只读检查：连接和认证、提权、解释器是否存在、工作目录是否可写，
+// 不上传文件也不执行脚本。连接失败时不再进行后续检查
+func Preflight(host *models.Host, opts PreflightOptions) []PreflightCheck {
+	start := time.Now()
+	client, err := NewSSHClient(host)
+	if err == nil {
+		err = client.TestConnection()
+		defer client.Close()
+	}
+	if err != nil {
+		return []PreflightCheck{{Name: PreflightConnection, Message: err.Error()}}
+	}
+	checks := []PreflightCheck{{
+		Name:    PreflightConnection,
+		Passed:  true,
+		Message: fmt.Sprintf("连接成功，耗时 %v", time.Since(start).Round(time.Millisecond)),
+	}}
+
+	if opts.Become != nil {
+		checks = append(checks, client.checkBecome(opts.Become))
+	}
+	if opts.Interpreter != nil {
+		checks = append(checks, client.checkInterpreter(opts.Interpreter, opts.Become))
+	}
+	if opts.WorkdirBase != "" {
+		checks = append(checks, client.checkWorkdir(opts.WorkdirBase))
+	}
+	return checks
+}
+
+// checkBecome 以目标用户身份执行 id -un，验证提权方式和密码
+func (c *SSHClient) checkBecome(become *Become) PreflightCheck {
+	ctx, cancel := context.WithTimeout(context.Background(), preflightCheckTimeout)
+	defer cancel()
+	check := PreflightCheck{Name: PreflightBecome}
+	stdout, stderr, err := c.executeStream(ctx, "id -un", nil, become)
+	if err != nil {
+		check.Message = fmt.Sprintf("%s 提权为 %s 失败: %v %s", become.Method, become.targetUser(), err, strings.TrimSpace(stderr))
+		return check
+	}
+	user := lastLine(stdout)
+	if user != become.targetUser() {
+		check.Message = fmt.Sprintf("%s 提权后的用户为 %s，期望为 %s", become.Method, user, become.targetUser())
+		return check
+	}
+	check.Passed = true
+	check.Message = fmt.Sprintf("%s 提权为 %s 成功", become.Method, user)
+	return check
+}
+
+// checkInterpreter 按探测顺序查找解释器，与执行时一样以提权后的身份查找
+func (c *SSHClient) checkInterpreter(interpreter *Interpreter, become *Become) PreflightCheck {
+	ctx, cancel := context.WithTimeout(context.Background(), preflightCheckTimeout)
+	defer cancel()
+	probes := make([]string, 0, len(interpreter.Probes))
+	for _, probe := range interpreter.Probes {
+		probes = append(probes, shellQuote(probe))
+	}
+	command := fmt.Sprintf("for c in %s; do command -v \"$c\" 2>/dev/null && exit 0; done; exit 127", strings.Join(probes, " "))
+
+	check := PreflightCheck{Name: PreflightInterpreter}
+	stdout, _, err := c.executeStream(ctx, command, nil, become)
+	if err != nil {
+		if code := ExitCodeOf(err); code != nil && *code == 127 {
+			check.Message = fmt.Sprintf("未找到可用的 %s 解释器（%s）", interpreter.Type, strings.Join(interpreter.Probes, ", "))
+		} else {
+			check.Message = fmt.Sprintf("检查 %s 解释器失败: %v", interpreter.Type, err)
+		}
+		return check
+	}
+	check.Passed = true
+	check.Message = fmt.Sprintf("%s 使用 %s", interpreter.Type, lastLine(stdout))
+	return check
+}
+
+// checkWorkdir 检查登录用户能否创建工作目录：基础路径存在时需可写且属于root或登录用户（见prepareWorkdir），
+// 不存在时需要最近的已有上级目录可写。只检查权限，不创建目录
+func (c *SSHClient) checkWorkdir(base string) PreflightCheck {
+	ctx, cancel := context.WithTimeout(context.Background(), preflightCheckTimeout)
+	defer cancel()
+	command := fmt.Sprintf(`d=%s
+while [ ! -e "$d" ] && [ ! -L "$d" ]; do d=$(dirname "$d"); done
+echo "$d"
+[ -d "$d" ] && [ ! -L "$d" ] || exit 2
+[ -w "$d" ] && [ -x "$d" ] || exit 3
+if [ "$d" = %[1]s ]; then
+	set -- $(ls -ldn "$d")
+	[ "$3" = 0 ] || [ "$3" = "$(id -u)" ] || exit 4
+	case "$1" in d???????w[!tT]*) exit 4;; esac
+fi
+true`, shellQuote(base))
+
+	check := PreflightCheck{Name: PreflightWorkdir}
+	stdout, _, err := c.ExecuteCommandContext(ctx, command)
+	dir := lastLine(stdout)
+	code := ExitCodeOf(err)
+	switch {
+	case err == nil && dir == base:
+		check.Passed = true
+		check.Message = fmt.Sprintf("工作目录基础路径 %s 可写", base)
+	case err == nil:
+		check.Passed = true
+		check.Message = fmt.Sprintf("工作目录基础路径 %s 不存在，将在可写的上级目录 %s 中创建", base, dir)
+	case code != nil && *code == 2:
+		check.Message = fmt.Sprintf("%s 已存在但不是目录（或是符号链接），无法创建工作目录", dir)
+	case code != nil && *code == 3:
+		check.Message = fmt.Sprintf("登录用户 %s 对 %s 没有写权限，无法创建工作目录", c.host.Username, dir)
+	case code != nil && *code == 4:
+		check.Message = fmt.Sprintf("工作目录基础路径 %s 属于其他用户，或对所有用户可写但未设置粘滞位", dir)
+	default:
+		check.Message = fmt.Sprintf("检查工作目录失败: %v", err)
+	}
+	return check
+}
+
+// lastLine 返回输出的最后一个非空行，su分配的伪终端会在输出前混入提示信息
+func lastLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
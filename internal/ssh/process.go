@@ -0,0 +1,231 @@
+package ssh
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go-devops/internal/logger"
+)
+
+// 远程进程组标记，命令启动时由远程shell输出，用于超时或取消时终止整个进程组
+const pgidMarker = "__DEVOPS_PGID__:"
+
+// 发送SIGTERM后等待进程退出的时间，超过后发送SIGKILL
+const killGracePeriod = 3 * time.Second
+
+// wrapWithPGIDMarker 在命令前输出远程shell的PID
+// sshd会为每个会话调用setsid，因此该PID同时也是命令所在进程组的ID
+func wrapWithPGIDMarker(command string) string {
+	return fmt.Sprintf("echo \"%s$$\"\n%s", pgidMarker, command)
+}
+
+// syncBuffer 线程安全的输出缓冲区
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// OutputHandler 接收远程命令的实时输出，stream 为 stdout 或 stderr
+type OutputHandler func(stream string, data []byte)
+
+// streamWriter 将写入的数据转发给OutputHandler
+type streamWriter struct {
+	stream  string
+	handler OutputHandler
+}
+
+func newStreamWriter(stream string, handler OutputHandler) io.Writer {
+	if handler == nil {
+		return io.Discard
+	}
+	return &streamWriter{stream: stream, handler: handler}
+}
+
+func (w *streamWriter) Write(p []byte) (int, error) {
+	// 回调方可能异步持有数据，这里复制一份
+	data := make([]byte, len(p))
+	copy(data, p)
+	w.handler(w.stream, data)
+	return len(p), nil
+}
+
+// 在标准输出开头查找进程组标记的最大行数，登录shell的启动脚本可能先于标记输出内容
+const pgidScanLines = 50
+
+// 标记行的最大长度（标记加PID），超过时不再视为标记行
+const pgidMaxLineLength = len(pgidMarker) + 20
+
+// pgidWriter 从标准输出开头的若干行中查找标记并解析进程组ID，标记行被移除，其余内容原样写入下游
+type pgidWriter struct {
+	mu     sync.Mutex
+	out    io.Writer
+	head   []byte // 尚未判断是否为标记行的内容
+	inLine bool   // 当前行已确定不是标记行，只差剩余部分
+	lines  int    // 已放行的行数
+	parsed bool   // 已找到标记或超过查找范围
+	pgid   int
+}
+
+func newPGIDWriter(out io.Writer) *pgidWriter {
+	return &pgidWriter{out: out}
+}
+
+func (w *pgidWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.parsed {
+		return w.out.Write(p)
+	}
+
+	w.head = append(w.head, p...)
+	for !w.parsed && len(w.head) > 0 {
+		idx := bytes.IndexByte(w.head, '\n')
+		if idx < 0 {
+			if !w.inLine && maybePGIDMarker(w.head) {
+				// 可能是尚未完整的标记行，等待后续内容
+				break
+			}
+			// 不完整的普通行直接放行，避免延迟实时输出
+			w.inLine = true
+			if _, err := w.out.Write(w.head); err != nil {
+				return 0, err
+			}
+			w.head = nil
+			break
+		}
+
+		line := w.head[:idx+1]
+		if !w.inLine {
+			if pgid, ok := parsePGIDMarker(line); ok {
+				w.pgid = pgid
+				w.parsed = true
+				w.head = w.head[idx+1:]
+				break
+			}
+		}
+		if _, err := w.out.Write(line); err != nil {
+			return 0, err
+		}
+		w.head = w.head[idx+1:]
+		w.inLine = false
+		w.lines++
+		if w.lines >= pgidScanLines {
+			w.parsed = true
+		}
+	}
+
+	if w.parsed && len(w.head) > 0 {
+		if _, err := w.out.Write(w.head); err != nil {
+			return 0, err
+		}
+		w.head = nil
+	}
+	return len(p), nil
+}
+
+// maybePGIDMarker 判断不完整的行是否可能是标记行
+func maybePGIDMarker(partial []byte) bool {
+	if len(partial) <= len(pgidMarker) {
+		return bytes.HasPrefix([]byte(pgidMarker), partial)
+	}
+	return bytes.HasPrefix(partial, []byte(pgidMarker)) && len(partial) <= pgidMaxLineLength
+}
+
+// parsePGIDMarker 解析标记行中的进程组ID
+func parsePGIDMarker(line []byte) (int, bool) {
+	text := strings.TrimSpace(string(line))
+	if !strings.HasPrefix(text, pgidMarker) {
+		return 0, false
+	}
+	pgid, err := strconv.Atoi(strings.TrimPrefix(text, pgidMarker))
+	if err != nil || pgid <= 0 {
+		return 0, false
+	}
+	return pgid, true
+}
+
+// flush 输出尚未处理的缓冲内容（命令输出不足一行时）
+func (w *pgidWriter) flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.parsed && len(w.head) > 0 {
+		if _, ok := parsePGIDMarker(w.head); !ok {
+			w.out.Write(w.head)
+		}
+	}
+	w.head = nil
+	w.parsed = true
+}
+
+// PGID 返回解析到的远程进程组ID，未解析到时返回0
+func (w *pgidWriter) PGID() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.pgid
+}
+
+// killProcessGroup 通过新的会话向远程进程组发送SIGTERM，宽限期后发送SIGKILL。
+// become不为空时以同样的提权方式执行kill，登录用户无权向提权后的进程发送信号
+func (c *SSHClient) killProcessGroup(pgid int, become *Become) error {
+	if pgid <= 1 {
+		return fmt.Errorf("无效的远程进程组ID: %d", pgid)
+	}
+
+	command := fmt.Sprintf(
+		"kill -TERM -- -%[1]d 2>/dev/null || kill -TERM %[1]d 2>/dev/null; "+
+			"sleep %[2]d; "+
+			"kill -KILL -- -%[1]d 2>/dev/null || kill -KILL %[1]d 2>/dev/null; true",
+		pgid, int(killGracePeriod/time.Second))
+
+	session, release, err := c.newSession()
+	if err != nil {
+		return fmt.Errorf("创建终止会话失败: %v", err)
+	}
+	defer release()
+	defer session.Close()
+
+	var promptFailed <-chan error
+	if become != nil {
+		watcher, err := become.attach(session)
+		if err != nil {
+			return fmt.Errorf("创建终止会话失败: %v", err)
+		}
+		session.Stdout = watcher.writer()
+		session.Stderr = watcher.writer()
+		promptFailed = watcher.failed
+		command = become.wrap(command)
+	}
+
+	logger.Warnf("终止主机 %s 上的远程进程组: %d", c.host.IP, pgid)
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Run(command)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("终止远程进程组失败: %v", err)
+		}
+	case err := <-promptFailed:
+		return fmt.Errorf("终止远程进程组失败: %v", err)
+	}
+	return nil
+}
@@ -1,9 +1,11 @@
 package ssh
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"time"
 
@@ -13,110 +15,233 @@ import (
 	"go-devops/internal/models"
 )
 
-// SSHClient SSH客户端结构
+// SSHClient SSH客户端结构，底层连接由连接池管理，会话和SFTP客户端按需从池中借用
 type SSHClient struct {
-	client *ssh.Client
-	host   *models.Host
+	key  string
+	host *models.Host
 }
 
-// NewSSHClient 创建SSH客户端
+// NewSSHClient 创建SSH客户端，复用连接池中同一主机和凭据的连接，没有可用连接时建立新连接
 func NewSSHClient(host *models.Host) (*SSHClient, error) {
-	config := &ssh.ClientConfig{
-		User:            host.Username,
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(), // 生产环境应该验证主机密钥
-		Timeout:         30 * time.Second,
+	key := poolKey(host)
+	if err := defaultPool.ensure(key, host); err != nil {
+		return nil, err
 	}
+	return &SSHClient{key: key, host: host}, nil
+}
 
-	// 根据认证类型配置认证方法
-	switch host.AuthType {
-	case "password":
-		if host.Password == "" {
-			return nil, fmt.Errorf("密码认证需要提供密码")
-		}
-		config.Auth = []ssh.AuthMethod{
-			ssh.Password(host.Password),
-		}
-		logger.Infof("使用密码认证连接主机: %s@%s", host.Username, host.IP)
+// Close 归还SSH客户端，连接保留在池中供后续复用，空闲超时后关闭
+func (c *SSHClient) Close() error {
+	return nil
+}
 
-	case "key":
-		if host.PrivateKey == "" {
-			return nil, fmt.Errorf("密钥认证需要提供私钥")
-		}
-		
-		var signer ssh.Signer
-		var err error
-		
-		if host.Passphrase != "" {
-			// 带密码短语的私钥
-			signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(host.PrivateKey), []byte(host.Passphrase))
-		} else {
-			// 无密码短语的私钥
-			signer, err = ssh.ParsePrivateKey([]byte(host.PrivateKey))
-		}
-		
-		if err != nil {
-			return nil, fmt.Errorf("解析私钥失败: %v", err)
-		}
-		
-		config.Auth = []ssh.AuthMethod{
-			ssh.PublicKeys(signer),
-		}
-		logger.Infof("使用密钥认证连接主机: %s@%s", host.Username, host.IP)
+// newSession 从连接池借用一个会话，调用方关闭会话后需调用release
+func (c *SSHClient) newSession() (*ssh.Session, func(), error) {
+	return defaultPool.newSession(c.key, c.host)
+}
+
+// sftpClient 获取连接上共享的SFTP客户端，调用方使用完后需调用release，不能关闭返回的客户端
+func (c *SSHClient) sftpClient() (*sftp.Client, func(), error) {
+	return defaultPool.sftpClient(c.key, c.host)
+}
+
+// endpoint SSH连接端点：目标主机或跳板机
+type endpoint struct {
+	IP          string
+	Port        int
+	Username    string
+	AuthType    string
+	Password    string
+	PrivateKey  string
+	Passphrase  string
+	Certificate string
+	hostKey     hostKeyTarget
+	// 凭据无法解密时的原因，不为空时拒绝连接
+	credentialErr string
+}
 
-	default:
-		return nil, fmt.Errorf("不支持的认证类型: %s，支持的类型: password, key", host.AuthType)
+func hostEndpoint(host *models.Host) endpoint {
+	return endpoint{
+		IP:          host.IP,
+		Port:        host.Port,
+		Username:    host.Username,
+		AuthType:    host.AuthType,
+		Password:    host.Password,
+		PrivateKey:  host.PrivateKey,
+		Passphrase:  host.Passphrase,
+		Certificate: host.Certificate,
+		hostKey: hostKeyTarget{
+			kind:    HostKeyTargetHost,
+			id:      host.ID,
+			label:   "主机 " + host.IP,
+			trusted: host.HostKey,
+		},
+		credentialErr: host.CredentialError,
 	}
+}
+
+func (e endpoint) addr() string {
+	return fmt.Sprintf("%s:%d", e.IP, e.Port)
+}
 
-	// 建立SSH连接
-	addr := fmt.Sprintf("%s:%d", host.IP, host.Port)
-	client, err := ssh.Dial("tcp", addr, config)
+// clientConfig 按端点的认证方式和主机密钥构建客户端配置，返回的cleanup在握手完成后调用
+func clientConfig(e endpoint) (*ssh.ClientConfig, func(), error) {
+	auth, cleanup, err := authMethods(e)
 	if err != nil {
-		logger.Errorf("SSH连接失败: %s@%s:%d, 错误: %v", host.Username, host.IP, host.Port, err)
-		return nil, fmt.Errorf("SSH连接失败: %v", err)
+		return nil, nil, err
 	}
-
-	logger.Infof("SSH连接成功: %s@%s:%d", host.Username, host.IP, host.Port)
-	return &SSHClient{
-		client: client,
-		host:   host,
-	}, nil
+	config := &ssh.ClientConfig{
+		User:              e.Username,
+		Auth:              auth,
+		HostKeyCallback:   hostKeyCallback(e.hostKey),
+		HostKeyAlgorithms: hostKeyAlgorithms(e.hostKey.trusted),
+		Timeout:           30 * time.Second,
+	}
+	return config, cleanup, nil
 }
 
-// Close 关闭SSH连接
-func (c *SSHClient) Close() error {
-	if c.client != nil {
-		return c.client.Close()
+// dial 建立到主机的SSH连接并完成认证，主机配置了跳板机时经由跳板机链连接。
+// 返回的release在连接关闭后调用，归还占用的跳板机连接
+func dial(host *models.Host) (*ssh.Client, func(), error) {
+	e := hostEndpoint(host)
+	config, cleanup, err := clientConfig(e)
+	if err != nil {
+		return nil, nil, err
 	}
-	return nil
+	defer cleanup()
+
+	if host.BastionID == nil {
+		client, err := ssh.Dial("tcp", e.addr(), config)
+		if err != nil {
+			logger.Errorf("SSH连接失败: %s@%s:%d, 错误: %v", host.Username, host.IP, host.Port, err)
+			return nil, nil, fmt.Errorf("SSH连接失败: %v", err)
+		}
+		logger.Infof("SSH连接成功: %s@%s:%d", host.Username, host.IP, host.Port)
+		return client, func() {}, nil
+	}
+
+	jump, err := bastions.acquire(*host.BastionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	client, err := dialVia(jump.client, e.addr(), config)
+	if err != nil {
+		bastions.release(jump)
+		logger.Errorf("经由跳板机 %s 连接失败: %s@%s:%d, 错误: %v", jump.name, host.Username, host.IP, host.Port, err)
+		return nil, nil, fmt.Errorf("SSH连接失败（经由跳板机 %s）: %v", jump.name, err)
+	}
+	logger.Infof("SSH连接成功: %s@%s:%d（经由跳板机 %s）", host.Username, host.IP, host.Port, jump.name)
+	return client, func() { bastions.release(jump) }, nil
 }
 
 // ExecuteCommand 执行命令
 func (c *SSHClient) ExecuteCommand(command string) (string, string, error) {
-	session, err := c.client.NewSession()
+	return c.ExecuteCommandContext(context.Background(), command)
+}
+
+// ExecuteCommandContext 执行命令，ctx结束时终止远程进程组并返回已捕获的部分输出
+func (c *SSHClient) ExecuteCommandContext(ctx context.Context, command string) (string, string, error) {
+	return c.ExecuteCommandStream(ctx, command, nil)
+}
+
+// ExecuteCommandStream 执行命令，并在输出到达时通过onOutput实时回调
+// 返回分离的标准输出和标准错误；出错时返回 *ExecError，可据此区分非零退出、连接失败、超时和取消
+func (c *SSHClient) ExecuteCommandStream(ctx context.Context, command string, onOutput OutputHandler) (string, string, error) {
+	return c.executeStream(ctx, command, onOutput, nil)
+}
+
+// executeStream 执行命令，become不为空时以目标用户身份执行，并应答或上报密码提示
+func (c *SSHClient) executeStream(ctx context.Context, command string, onOutput OutputHandler, become *Become) (string, string, error) {
+	// 启动前已超时或被取消时不再执行
+	if ctx.Err() != nil {
+		return "", "", contextError(ctx)
+	}
+
+	session, release, err := c.newSession()
 	if err != nil {
-		return "", "", fmt.Errorf("创建SSH会话失败: %v", err)
+		return "", "", connectionError("创建SSH会话失败: %v", err)
 	}
+	defer release()
 	defer session.Close()
 
 	logger.Infof("在主机 %s 上执行命令: %s", c.host.IP, command)
 
-	// 执行命令并获取输出
-	output, err := session.CombinedOutput(command)
+	var stdoutBuf, stderrBuf syncBuffer
+	stdoutWriters := []io.Writer{&stdoutBuf, newStreamWriter("stdout", onOutput)}
+	stderrWriters := []io.Writer{&stderrBuf, newStreamWriter("stderr", onOutput)}
+
+	var promptFailed <-chan error
+	if become != nil {
+		watcher, err := become.attach(session)
+		if err != nil {
+			return "", "", connectionError("%v", err)
+		}
+		stdoutWriters = append(stdoutWriters, watcher.writer())
+		stderrWriters = append(stderrWriters, watcher.writer())
+		promptFailed = watcher.failed
+		command = become.wrap(command)
+		logger.Infof("在主机 %s 上以用户 %s 身份执行（%s）", c.host.IP, become.targetUser(), become.Method)
+	}
+
+	stdout := newPGIDWriter(io.MultiWriter(stdoutWriters...))
+	session.Stdout = stdout
+	session.Stderr = io.MultiWriter(stderrWriters...)
+
+	if err := session.Start(wrapWithPGIDMarker(command)); err != nil {
+		return "", "", connectionError("启动远程命令失败: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Wait()
+	}()
+
+	// 被中断（超时、取消或提权失败）时终止远程进程组，再关闭会话，避免脚本在远端继续运行
+	interrupt := func() {
+		if pgid := stdout.PGID(); pgid > 0 {
+			if killErr := c.killProcessGroup(pgid, become); killErr != nil {
+				logger.Errorf("终止远程进程失败: %v", killErr)
+			}
+		} else {
+			session.Signal(ssh.SIGKILL)
+		}
+		session.Close()
+		select {
+		case <-done:
+		case <-time.After(killGracePeriod):
+		}
+		stdout.flush()
+	}
+
+	select {
+	case err = <-done:
+		stdout.flush()
+	case <-ctx.Done():
+		interrupt()
+		logger.Warnf("主机 %s 上的命令被中断: %v", c.host.IP, ctx.Err())
+		return stdoutBuf.String(), stderrBuf.String(), contextError(ctx)
+	case promptErr := <-promptFailed:
+		interrupt()
+		logger.Warnf("主机 %s 上的提权失败: %v", c.host.IP, promptErr)
+		return stdoutBuf.String(), stderrBuf.String(), &ExecError{Type: FailureBecome, Err: promptErr}
+	}
+
 	if err != nil {
 		logger.Errorf("命令执行失败: %v", err)
-		return "", string(output), err
+		return stdoutBuf.String(), stderrBuf.String(), classifyWaitError(err)
 	}
 
-	logger.Infof("命令执行成功，输出长度: %d 字节", len(output))
-	return string(output), "", nil
+	logger.Infof("命令执行成功，输出长度: %d 字节", len(stdoutBuf.String()))
+	return stdoutBuf.String(), stderrBuf.String(), nil
 }
 
 // TestConnection 测试SSH连接
 func (c *SSHClient) TestConnection() error {
-	session, err := c.client.NewSession()
+	session, release, err := c.newSession()
 	if err != nil {
 		return fmt.Errorf("创建测试会话失败: %v", err)
 	}
+	defer release()
 	defer session.Close()
 
 	// 执行简单的测试命令
@@ -159,53 +284,45 @@ func TestSSHConnection(host *models.Host) (*models.SSHTestResponse, error) {
 	}, nil
 }
 
-// ExecuteScript 执行脚本
-func ExecuteScript(host *models.Host, script *models.Script) (string, string, error) {
-	client, err := NewSSHClient(host)
-	if err != nil {
-		return "", "", fmt.Errorf("建立SSH连接失败: %v", err)
-	}
-	defer client.Close()
-
-	logger.Infof("开始在主机 %s 上执行脚本: %s", host.IP, script.Name)
-
-	var command string
-	switch script.Type {
-	case "shell", "bash":
-		// 对于shell脚本，直接执行
-		command = script.Content
-	case "python2":
-		// 对于Python2脚本，尝试多种执行方式绕过安全软件
-		command = fmt.Sprintf("cat > /tmp/temp_py_script.py << 'EOF'\n%s\nEOF\n# 尝试多种Python执行方式\nif command -v python2 >/dev/null 2>&1; then\n    python2 /tmp/temp_py_script.py 2>/dev/null || /usr/bin/python2 /tmp/temp_py_script.py 2>/dev/null || python /tmp/temp_py_script.py\nelse\n    python /tmp/temp_py_script.py\nfi\nrm -f /tmp/temp_py_script.py", script.Content)
-	case "python3":
-		// 对于Python3脚本，尝试多种执行方式绕过安全软件
-		command = fmt.Sprintf("cat > /tmp/temp_py_script.py << 'EOF'\n%s\nEOF\n# 尝试多种Python执行方式\nif command -v python3 >/dev/null 2>&1; then\n    python3 /tmp/temp_py_script.py 2>/dev/null || /usr/bin/python3 /tmp/temp_py_script.py 2>/dev/null || python /tmp/temp_py_script.py\nelse\n    python /tmp/temp_py_script.py\nfi\nrm -f /tmp/temp_py_script.py", script.Content)
-	default:
-		// 默认作为shell脚本处理
-		command = script.Content
-	}
-
-	output, stderr, err := client.ExecuteCommand(command)
-	
-	if err != nil {
-		logger.Errorf("脚本执行失败: %v, 输出: %s", err, stderr)
-		return output, stderr, err
-	}
-
-	logger.Infof("脚本执行成功: %s", script.Name)
-	return output, stderr, nil
+// ScriptOptions 脚本执行选项
+type ScriptOptions struct {
+	InputFiles []models.File                 // 输入文件，执行前上传到远程主机
+	Parameters []models.ScriptParameterValue // 按声明顺序作为位置参数 $1..$n 传入，同时导出为 DEVOPS_PARAM_<NAME> 环境变量
+	OnOutput   OutputHandler                 // 实时输出回调，可为空
+	Become     *Become                       // 提权设置，为空时以登录用户身份执行
+	// 远程工作目录的路径前缀（绝对路径），执行前以 mktemp -d <Workdir>.XXXXXX 创建，
+	// 输入文件和临时脚本放在其中并导出为 DEVOPS_WORKDIR，执行后删除；为空时使用登录用户的主目录
+	Workdir     string
+	OnWorkdir   func(dir string) // 工作目录创建后回调实际路径，可为空
+	KeepWorkdir bool             // 执行失败（含超时和取消）时保留工作目录，便于排查
+	Interpreter *Interpreter // 脚本类型对应的解释器，为空时脚本内容直接交给登录shell执行
+	// 执行结束（成功或非零退出）后从工作目录收集的产物，需要设置Workdir
+	Artifacts *ArtifactRequest
 }
 
-// ExecuteScriptWithFiles 执行脚本并传递输入文件
-func ExecuteScriptWithFiles(host *models.Host, script *models.Script, inputFiles []models.File) (string, string, error) {
+// ExecuteScriptWithFiles 执行脚本并传递输入文件和参数
+func ExecuteScriptWithFiles(ctx context.Context, host *models.Host, script *models.Script, opts ScriptOptions) (string, string, error) {
+	inputFiles, params := opts.InputFiles, opts.Parameters
+
 	client, err := NewSSHClient(host)
 	if err != nil {
-		return "", "", fmt.Errorf("建立SSH连接失败: %v", err)
+		return "", "", connectionError("建立SSH连接失败: %v", err)
 	}
 	defer client.Close()
 
 	logger.Infof("开始在主机 %s 上执行脚本: %s，输入文件数量: %d", host.IP, script.Name, len(inputFiles))
 
+	var workdir string
+	if opts.Workdir != "" {
+		workdir, err = client.prepareWorkdir(opts.Workdir, opts.Become)
+		if err != nil {
+			return "", "", connectionError("%v", err)
+		}
+		if opts.OnWorkdir != nil {
+			opts.OnWorkdir(workdir)
+		}
+	}
+
 	// 上传输入文件到远程主机
 	for i, file := range inputFiles {
 		logger.Infof("准备上传第 %d/%d 个文件: %s (ID: %d, 路径: %s, 大小: %d)", 
@@ -214,38 +331,59 @@ func ExecuteScriptWithFiles(host *models.Host, script *models.Script, inputFiles
 		// 检查本地文件是否存在
 		if _, err := os.Stat(file.Path); os.IsNotExist(err) {
 			logger.Errorf("本地文件不存在: %s", file.Path)
-			return "", "", fmt.Errorf("本地文件不存在: %s", file.Path)
+			return "", "", connectionError("本地文件不存在: %s", file.Path)
 		}
 		
-		err := client.UploadFile(file.Path, file.OriginalName)
+		remotePath := file.OriginalName
+		if workdir != "" {
+			remotePath = path.Join(workdir, file.OriginalName)
+		}
+		err := client.UploadFile(file.Path, remotePath)
 		if err != nil {
 			logger.Errorf("上传文件失败: %s (路径: %s), 错误: %v", file.OriginalName, file.Path, err)
-			return "", "", fmt.Errorf("上传文件失败: %s", file.OriginalName)
+			if workdir != "" && !opts.KeepWorkdir {
+				client.cleanupWorkdir(workdir, opts.Become)
+			}
+			return "", "", connectionError("上传文件失败: %s", file.OriginalName)
 		}
-		logger.Infof("文件上传成功: %s -> %s@%s:%s", file.Path, host.Username, host.IP, file.OriginalName)
+		logger.Infof("文件上传成功: %s -> %s@%s:%s", file.Path, host.Username, host.IP, remotePath)
 	}
 
 	var command string
-	switch script.Type {
-	case "shell", "bash":
-		command = script.Content
-	case "python2":
-		command = fmt.Sprintf("cat > temp_py_script.py << 'EOF'\n%s\nEOF\nif command -v python2 >/dev/null 2>&1; then\n    python2 temp_py_script.py 2>/dev/null || /usr/bin/python2 temp_py_script.py 2>/dev/null || python temp_py_script.py\nelse\n    python temp_py_script.py\nfi\nrm -f temp_py_script.py", script.Content)
-	case "python3":
-		command = fmt.Sprintf("cat > temp_py_script.py << 'EOF'\n%s\nEOF\nif command -v python3 >/dev/null 2>&1; then\n    python3 temp_py_script.py 2>/dev/null || /usr/bin/python3 temp_py_script.py 2>/dev/null || python temp_py_script.py\nelse\n    python temp_py_script.py\nfi\nrm -f temp_py_script.py", script.Content)
-	default:
+	if opts.Interpreter != nil {
+		command = opts.Interpreter.buildCommand(script.Content, buildPositionalArgs(params), workdir != "")
+	} else {
 		command = script.Content
+		if len(params) > 0 {
+			command = fmt.Sprintf("set -- %s\n%s", buildPositionalArgs(params), script.Content)
+		}
+	}
+	// 参数同时以环境变量形式导出
+	command = buildParamExports(params) + command
+	if workdir != "" {
+		command = workdirPrefix(workdir) + command
 	}
 
-	output, stderr, err := client.ExecuteCommand(command)
+	output, stderr, err := client.executeStream(ctx, command, opts.OnOutput, opts.Become)
 	
-	// 清理上传的文件和临时脚本文件
-	for _, file := range inputFiles {
-		client.ExecuteCommand(fmt.Sprintf("rm -f %s", file.OriginalName))
+	// 收集产物需在删除工作目录之前
+	if workdir != "" && opts.Artifacts != nil && (err == nil || FailureTypeOf(err) == FailureExitCode) {
+		artifacts, problems := client.collectArtifacts(workdir, opts.Artifacts)
+		opts.Artifacts.Done(artifacts, problems)
 	}
-	// 清理Python临时脚本文件（防止执行失败时未清理）
-	if script.Type == "python2" || script.Type == "python3" {
-		client.ExecuteCommand("rm -f temp_py_script.py")
+
+	if workdir != "" {
+		// 输入文件和临时脚本都在工作目录中，整体删除
+		if err == nil || !opts.KeepWorkdir {
+			client.cleanupWorkdir(workdir, opts.Become)
+		} else {
+			logger.Warnf("执行失败，保留主机 %s 上的工作目录: %s", host.IP, workdir)
+		}
+	} else {
+		// 清理上传的文件，脚本文件由命令自身删除
+		for _, file := range inputFiles {
+			client.ExecuteCommand(fmt.Sprintf("rm -f %s", shellQuote(file.OriginalName)))
+		}
 	}
 	
 	if err != nil {
@@ -292,12 +430,12 @@ func GetSystemInfo(host *models.Host) (map[string]interface{}, error) {
 
 // UploadFile 上传文件到远程主机
 func (c *SSHClient) UploadFile(localPath, remotePath string) error {
-	// 创建SFTP客户端
-	sftpClient, err := sftp.NewClient(c.client)
+	// 获取共享的SFTP客户端
+	sftpClient, release, err := c.sftpClient()
 	if err != nil {
-		return fmt.Errorf("创建SFTP客户端失败: %v", err)
+		return fmt.Errorf("获取SFTP客户端失败: %v", err)
 	}
-	defer sftpClient.Close()
+	defer release()
 
 	// 打开本地文件
 	localFile, err := os.Open(localPath)
@@ -332,12 +470,12 @@ func (c *SSHClient) UploadFile(localPath, remotePath string) error {
 
 // DownloadFile 从远程主机下载文件
 func (c *SSHClient) DownloadFile(remotePath, localPath string) error {
-	// 创建SFTP客户端
-	sftpClient, err := sftp.NewClient(c.client)
+	// 获取共享的SFTP客户端
+	sftpClient, release, err := c.sftpClient()
 	if err != nil {
-		return fmt.Errorf("创建SFTP客户端失败: %v", err)
+		return fmt.Errorf("获取SFTP客户端失败: %v", err)
 	}
-	defer sftpClient.Close()
+	defer release()
 
 	// 打开远程文件
 	remoteFile, err := sftpClient.Open(remotePath)
@@ -372,11 +510,11 @@ func (c *SSHClient) DownloadFile(remotePath, localPath string) error {
 
 // FileExists 检查远程文件是否存在
 func (c *SSHClient) FileExists(remotePath string) (bool, error) {
-	sftpClient, err := sftp.NewClient(c.client)
+	sftpClient, release, err := c.sftpClient()
 	if err != nil {
-		return false, fmt.Errorf("创建SFTP客户端失败: %v", err)
+		return false, fmt.Errorf("获取SFTP客户端失败: %v", err)
 	}
-	defer sftpClient.Close()
+	defer release()
 
 	_, err = sftpClient.Stat(remotePath)
 	if err != nil {
@@ -390,22 +528,22 @@ func (c *SSHClient) FileExists(remotePath string) (bool, error) {
 
 // GetFileInfo 获取远程文件信息
 func (c *SSHClient) GetFileInfo(remotePath string) (os.FileInfo, error) {
-	sftpClient, err := sftp.NewClient(c.client)
+	sftpClient, release, err := c.sftpClient()
 	if err != nil {
-		return nil, fmt.Errorf("创建SFTP客户端失败: %v", err)
+		return nil, fmt.Errorf("获取SFTP客户端失败: %v", err)
 	}
-	defer sftpClient.Close()
+	defer release()
 
 	return sftpClient.Stat(remotePath)
 }
 
 // RemoveFile 删除远程文件
 func (c *SSHClient) RemoveFile(remotePath string) error {
-	sftpClient, err := sftp.NewClient(c.client)
+	sftpClient, release, err := c.sftpClient()
 	if err != nil {
-		return fmt.Errorf("创建SFTP客户端失败: %v", err)
+		return fmt.Errorf("获取SFTP客户端失败: %v", err)
 	}
-	defer sftpClient.Close()
+	defer release()
 
 	err = sftpClient.Remove(remotePath)
 	if err != nil {
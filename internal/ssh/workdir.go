@@ -0,0 +1,69 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"time"
+
+	"go-devops/internal/logger"
+)
+
+// WorkdirEnv 执行工作目录对应的环境变量
+const WorkdirEnv = "DEVOPS_WORKDIR"
+
+// 清理工作目录的超时时间，执行被取消或超时后仍需完成清理
+const workdirCleanupTimeout = 30 * time.Second
+
+// prepareWorkdir 以 mktemp -d 在基础目录下创建名称随机、仅登录用户可访问的执行工作目录，返回其路径。
+// 基础目录不存在时创建为711（其他用户只能进入已知路径）；已存在时必须是属于root或登录用户的目录（非符号链接），
+// 对所有用户可写时必须设置粘滞位，防止其他用户替换工作目录。
+// 提权为其他普通用户时通过ACL授权目标用户读写，无法设置ACL时仅在登录用户为root时改为目标用户所有
+func (c *SSHClient) prepareWorkdir(prefix string, become *Become) (string, error) {
+	base := path.Dir(prefix)
+	grant := ""
+	if become != nil && become.targetUser() != defaultBecomeUser && become.targetUser() != c.host.Username {
+		user := shellQuote(become.targetUser())
+		grant = fmt.Sprintf(`setfacl -m u:%[1]s:rwx,d:u:%[1]s:rwx,d:u:"$(id -un)":rwx "$dir" 2>/dev/null ||
+	{ [ "$(id -u)" = 0 ] && chown %[1]s "$dir"; } ||
+	{ rmdir "$dir"; echo "无法授权提权用户 %[1]s 访问工作目录：远程主机需要支持 setfacl，或登录用户为root" >&2; exit 1; }
+`, user)
+	}
+	command := fmt.Sprintf(`base=%s
+[ -e "$base" ] || [ -L "$base" ] || mkdir -p -m 711 "$base" || exit 1
+if [ -L "$base" ] || [ ! -d "$base" ]; then echo "工作目录基础路径 $base 不是目录" >&2; exit 1; fi
+set -- $(ls -ldn "$base")
+if [ "$3" != 0 ] && [ "$3" != "$(id -u)" ]; then echo "工作目录基础路径 $base 属于其他用户" >&2; exit 1; fi
+case "$1" in d???????w[!tT]*) echo "工作目录基础路径 $base 对所有用户可写但未设置粘滞位" >&2; exit 1;; esac
+dir=$(mktemp -d %s) || exit 1
+%secho "$dir"`, shellQuote(base), shellQuote(prefix+".XXXXXX"), grant)
+	stdout, stderr, err := c.ExecuteCommand(command)
+	if err != nil {
+		return "", fmt.Errorf("创建工作目录失败: %v %s", err, stderr)
+	}
+	dir := lastLine(stdout)
+	if path.Dir(dir) != base {
+		return "", fmt.Errorf("创建工作目录失败: 无法识别的路径 %q", dir)
+	}
+	return dir, nil
+}
+
+// cleanupWorkdir 删除执行工作目录：先使用与执行相同的提权设置删除目标用户创建的文件，
+// 再由登录用户删除工作目录本身（目标用户对基础目录没有写权限）
+func (c *SSHClient) cleanupWorkdir(dir string, become *Become) {
+	ctx, cancel := context.WithTimeout(context.Background(), workdirCleanupTimeout)
+	defer cancel()
+	command := "rm -rf -- " + shellQuote(dir)
+	if become != nil {
+		// 目标用户无权删除工作目录本身，此处的失败由随后登录用户的删除结果体现
+		c.executeStream(ctx, command, nil, become)
+	}
+	if _, stderr, err := c.ExecuteCommandContext(ctx, command); err != nil {
+		logger.Warnf("清理主机 %s 上的工作目录 %s 失败: %v %s", c.host.IP, dir, err, stderr)
+	}
+}
+
+// workdirPrefix 导出工作目录环境变量并切换到工作目录
+func workdirPrefix(dir string) string {
+	return fmt.Sprintf("export %[1]s=%[2]s\ncd \"$%[1]s\" || exit 1\n", WorkdirEnv, shellQuote(dir))
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"go-devops/internal/api"
 	"go-devops/internal/config"
@@ -13,8 +14,12 @@ import (
 	"go-devops/internal/logger"
 	"go-devops/internal/middleware"
 	"go-devops/internal/scheduler"
+	"go-devops/internal/secret"
+	"go-devops/internal/services"
+	"go-devops/internal/ssh"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 func main() {
@@ -28,6 +33,40 @@ func main() {
 	logger.Init()
 	logger.Info("应用程序启动")
 
+	// 初始化敏感字段加密密钥：必须单独配置，不能与JWT密钥相同
+	if cfg.Security.SecretKey == "" {
+		logger.Fatal("未配置敏感字段加密密钥，请设置 security.secret_key 或环境变量 DEVOPS_SECRET_KEY")
+	}
+	if cfg.Security.SecretKey == cfg.JWT.Secret {
+		logger.Fatal("security.secret_key 不能与JWT密钥相同")
+	}
+	if err := secret.Init(cfg.Security.SecretKey, cfg.Security.PreviousSecretKeys...); err != nil {
+		logger.Fatal("加密密钥初始化失败:", err)
+	}
+
+	// 远程执行工作目录
+	if err := services.ConfigureWorkdir(cfg.Execution.WorkdirBase, cfg.Execution.KeepWorkdirOnFailure); err != nil {
+		logger.Fatal("执行工作目录配置错误:", err)
+	}
+
+	// SSH连接池
+	ssh.ConfigurePool(ssh.PoolConfig{
+		MaxSessions:       cfg.SSH.MaxSessions,
+		IdleTimeout:       parseDurationOrZero("ssh.idle_timeout", cfg.SSH.IdleTimeout),
+		KeepAliveInterval: parseDurationOrZero("ssh.keep_alive_interval", cfg.SSH.KeepAliveInterval),
+	})
+	if err := ssh.ConfigureAuth(ssh.AuthConfig{
+		AgentSocket:     cfg.SSH.AgentSocket,
+		CAKeyFile:       cfg.SSH.CAKeyFile,
+		CAKeyPassphrase: cfg.SSH.CAKeyPassphrase,
+		CertValidity:    parseDurationOrZero("ssh.cert_validity", cfg.SSH.CertValidity),
+	}); err != nil {
+		logger.Fatal("SSH认证配置错误:", err)
+	}
+
+	// 执行申请有效期
+	services.ConfigureApproval(cfg.Approval.ExpireHours)
+
 	// 初始化数据库
 	db, err := database.Init(cfg)
 	if err != nil {
@@ -35,6 +74,34 @@ func main() {
 	}
 	logger.Info("数据库初始化成功")
 
+	// 主密钥轮换：go-devops rotate-keys
+	if len(os.Args) > 1 && os.Args[1] == "rotate-keys" {
+		rotateKeys(db)
+		return
+	}
+
+	// 加密历史数据中的明文凭据
+	if _, err := services.NewCredentialService(db).EncryptPlaintext(); err != nil {
+		logger.Fatal("加密历史凭据失败:", err)
+	}
+
+	// SSH主机密钥校验
+	if err := ssh.ConfigureHostKeys(cfg.SSH.HostKeyPolicy, services.NewHostKeyService(db)); err != nil {
+		logger.Fatal("主机密钥配置错误:", err)
+	}
+	ssh.ConfigureBastions(services.NewBastionService(db))
+
+	// 处理上次退出时未结束的执行，注册作业运行结束回调
+	if err := services.NewExecutionService(db).ReconcileInterruptedRuns(); err != nil {
+		logger.Errorf("处理未结束的作业运行失败: %v", err)
+	}
+	services.RegisterJobRunActivity(db)
+
+	// 注册自定义脚本解释器
+	if err := services.NewInterpreterService(db).LoadCustomInterpreters(); err != nil {
+		logger.Errorf("加载自定义脚本解释器失败: %v", err)
+	}
+
 	// 设置Gin模式
 	setGinMode(cfg.App.Environment)
 
@@ -66,6 +133,7 @@ func main() {
 		<-c
 		logger.Info("接收到关闭信号，正在关闭服务...")
 		taskScheduler.Stop()
+		ssh.ClosePool()
 		os.Exit(0)
 	}()
 
@@ -90,3 +158,28 @@ func setGinMode(environment string) {
 		logger.Info("Gin模式设置为: debug (开发环境)")
 	}
 }
+
+// rotateKeys 使用当前主密钥重新加密数据库中的凭据
+func rotateKeys(db *gorm.DB) {
+	report, err := services.NewCredentialService(db).RotateKeys()
+	if err != nil {
+		logger.Fatal("主密钥轮换失败:", err)
+	}
+	fmt.Printf("主密钥轮换完成，当前主密钥ID: %s，重新加密 %d 个值\n", report.KeyID, report.Values)
+	for table, rows := range report.Rows {
+		fmt.Printf("  %s: %d 行\n", table, rows)
+	}
+}
+
+// parseDurationOrZero 解析时长配置，为空或格式错误时返回0以使用默认值
+func parseDurationOrZero(name, value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		logger.Warnf("配置项 %s 格式错误（%s），使用默认值", name, value)
+		return 0
+	}
+	return d
+}